@@ -17,17 +17,68 @@ type Config struct {
 	DbName                 string
 	DbPort                 string
 	JWTSecretKey           string
+	JWTSecretKeyID         string
+	JWTKeysDir             string
+	JWTSigningKeyID        string
+	JWTIssuer              string
+	JWTAudience            string
+	EncryptionKey          string
+	TOTPIssuer             string
+	OTPStore               string
+	OTPSweepInterval       string
+	OTPFormats             string
+	PasswordHistory        string
+	PasswordMinLength      string
+	PasswordMinClasses     string
+	PasswordMinScore       string
+	BreachedPasswordsFile  string
+	PasswordHasher         string
+	BcryptCost             string
+	Argon2Memory           string
+	Argon2Iterations       string
+	Argon2Threads          string
+	GeoIPProvider          string
+	GeoIPDatabase          string
+	GeoIPCacheSize         string
+	IPInfoToken            string
+	TrustedProxies         string
+	TrustedPlatform        string
+	RiskChallengeScore     string
+	RiskApprovalScore      string
+	RiskWeights            string
+	RiskIPBlocklist        string
+	RiskQuietHours         string
+	RiskTimezone           string
+	RiskMaxTravelSpeed     string
+	WebAuthnRPID           string
+	WebAuthnRPName         string
+	WebAuthnRPOrigins      string
 	GoogleClientID         string
 	GoogleClientSecret     string
 	GithubClientID         string
 	GithubClientSecret     string
 	OAuthRedirectBaseURL   string
 	ClientOauthRedirectURL string
+	GoogleAuthURL          string
+	GoogleTokenURL         string
+	GoogleUserInfoURL      string
+	GithubAuthURL          string
+	GithubTokenURL         string
+	GithubAPIURL           string
 	ResendApiKey           string
+	MailTransport          string
+	MailSinkDir            string
+	MailSinkViewer         string
+	SMTPHost               string
+	SMTPPort               string
+	SMTPUsername           string
+	SMTPPassword           string
+	SMTPTLS                string
 	CloudinaryAPIKey       string
 	CloudinaryApiSecret    string
 	CloudinaryName         string
 	ClientUrl              string
+	PublicBaseURL          string
 	APIToolkitKey          string
 	SendFromEmail          string
 	SendFromName           string
@@ -52,26 +103,84 @@ func New() *Config {
 	log.Println("app port env =>", getEnv("PORT", "8000"))
 
 	return &Config{
-		DbHost:              getEnv("POSTGRES_HOST", ""),
-		DbUser:              getEnv("POSTGRES_USER", ""),
-		DbPassword:          getEnv("POSTGRES_PASSWORD", ""),
-		DbName:              getEnv("POSTGRES_NAME", ""),
-		DbPort:              getEnv("POSTGRES_PORT", ""),
-		Port:                getEnv("PORT", "8000"),
-		JWTSecretKey:        getEnv("JWT_SCECRET", ""),
-		ResendApiKey:        getEnv("RESEND_API_KEY", ""),
-		CloudinaryAPIKey:    getEnv("CLOUDINARY_API_KEY", ""),
-		CloudinaryApiSecret: getEnv("CLOUDINARY_API_SECRET", ""),
-		CloudinaryName:      getEnv("CLOUDINARY_NAME", ""),
-		ClientUrl:           getEnv("CLIENT_WEBAPP_URL", ""),
-		APIToolkitKey:       getEnv("API_TOOLKIT_KEY", ""),
-		SendFromEmail:       getEnv("SEND_FROM_EMAIL", ""),
-		SendFromName:        getEnv("SEND_FROM_NAME", ""),
-		SSLMode:             getEnv("SSL_MODE", "disable"),
-		KafkaBrokers:        getEnv("KAFKA_BROKERS", ""),
-		KafkaVersion:        getEnv("KAFKA_VERSION", ""),
-		KafkaClientID:       getEnv("KAFKA_CLIENT_ID", ""),
-		KafkaConsumerGroup:  getEnv("KAFKA_CONSUMER_GROUP", ""),
+		DbHost:                 getEnv("POSTGRES_HOST", ""),
+		DbUser:                 getEnv("POSTGRES_USER", ""),
+		DbPassword:             getEnv("POSTGRES_PASSWORD", ""),
+		DbName:                 getEnv("POSTGRES_NAME", ""),
+		DbPort:                 getEnv("POSTGRES_PORT", ""),
+		Port:                   getEnv("PORT", "8000"),
+		Env:                    getEnv("APP_ENV", "development"),
+		JWTSecretKey:           jwtSecret(),
+		JWTSecretKeyID:         getEnv("JWT_SECRET_KEY_ID", "default"),
+		JWTKeysDir:             getEnv("JWT_KEYS_DIR", ""),
+		JWTSigningKeyID:        getEnv("JWT_SIGNING_KEY_ID", ""),
+		JWTIssuer:              getEnv("JWT_ISSUER", "golang-backend-template"),
+		JWTAudience:            getEnv("JWT_AUDIENCE", "golang-backend-template-api"),
+		EncryptionKey:          getEnv("ENCRYPTION_KEY", ""),
+		TOTPIssuer:             getEnv("TOTP_ISSUER", "Golang Template"),
+		OTPStore:               getEnv("OTP_STORE", "postgres"),
+		OTPSweepInterval:       getEnv("OTP_SWEEP_INTERVAL", "5m"),
+		OTPFormats:             getEnv("OTP_FORMATS", ""),
+		PasswordHistory:        getEnv("PASSWORD_HISTORY", "5"),
+		PasswordMinLength:      getEnv("PASSWORD_MIN_LENGTH", "8"),
+		PasswordMinClasses:     getEnv("PASSWORD_MIN_CLASSES", "0"),
+		PasswordMinScore:       getEnv("PASSWORD_MIN_SCORE", "2"),
+		BreachedPasswordsFile:  getEnv("BREACHED_PASSWORDS_FILE", "data/breached_passwords.txt"),
+		PasswordHasher:         getEnv("PASSWORD_HASH_ALGORITHM", "argon2id"),
+		BcryptCost:             getEnv("PASSWORD_BCRYPT_COST", "12"),
+		Argon2Memory:           getEnv("PASSWORD_ARGON2_MEMORY", "65536"),
+		Argon2Iterations:       getEnv("PASSWORD_ARGON2_ITERATIONS", "3"),
+		Argon2Threads:          getEnv("PASSWORD_ARGON2_PARALLELISM", "2"),
+		GeoIPProvider:          getEnv("GEOIP_PROVIDER", "mmdb"),
+		GeoIPDatabase:          getEnv("GEOIP_DATABASE", "data/GeoLite2-City.mmdb"),
+		GeoIPCacheSize:         getEnv("GEOIP_CACHE_SIZE", "10000"),
+		IPInfoToken:            getEnv("IPINFO_TOKEN", ""),
+		TrustedProxies:         getEnv("TRUSTED_PROXIES", ""),
+		TrustedPlatform:        getEnv("TRUSTED_PLATFORM", ""),
+		RiskChallengeScore:     getEnv("RISK_CHALLENGE_SCORE", "30"),
+		RiskApprovalScore:      getEnv("RISK_APPROVAL_SCORE", "70"),
+		RiskWeights:            getEnv("RISK_WEIGHTS", ""),
+		RiskIPBlocklist:        getEnv("RISK_IP_BLOCKLIST", ""),
+		RiskQuietHours:         getEnv("RISK_QUIET_HOURS", "1-5"),
+		RiskTimezone:           getEnv("RISK_TIMEZONE", "UTC"),
+		RiskMaxTravelSpeed:     getEnv("RISK_MAX_TRAVEL_SPEED", "1000"),
+		WebAuthnRPID:           getEnv("WEBAUTHN_RP_ID", "localhost"),
+		WebAuthnRPName:         getEnv("WEBAUTHN_RP_NAME", "Golang Template"),
+		WebAuthnRPOrigins:      getEnv("WEBAUTHN_RP_ORIGINS", "http://localhost:3000"),
+		GoogleClientID:         getEnv("GOOGLE_CLIENT_ID", ""),
+		GoogleClientSecret:     getEnv("GOOGLE_CLIENT_SECRET", ""),
+		GithubClientID:         getEnv("GITHUB_CLIENT_ID", ""),
+		GithubClientSecret:     getEnv("GITHUB_CLIENT_SECRET", ""),
+		OAuthRedirectBaseURL:   getEnv("OAUTH_REDIRECT_BASE_URL", "http://localhost:8000"),
+		ClientOauthRedirectURL: getEnv("CLIENT_OAUTH_REDIRECT_URL", ""),
+		GoogleAuthURL:          getEnv("GOOGLE_AUTH_URL", "https://accounts.google.com/o/oauth2/auth"),
+		GoogleTokenURL:         getEnv("GOOGLE_TOKEN_URL", "https://oauth2.googleapis.com/token"),
+		GoogleUserInfoURL:      getEnv("GOOGLE_USERINFO_URL", "https://openidconnect.googleapis.com/v1/userinfo"),
+		GithubAuthURL:          getEnv("GITHUB_AUTH_URL", "https://github.com/login/oauth/authorize"),
+		GithubTokenURL:         getEnv("GITHUB_TOKEN_URL", "https://github.com/login/oauth/access_token"),
+		GithubAPIURL:           getEnv("GITHUB_API_URL", "https://api.github.com"),
+		ResendApiKey:           getEnv("RESEND_API_KEY", ""),
+		MailTransport:          getEnv("MAIL_TRANSPORT", "resend"),
+		MailSinkDir:            getEnv("MAIL_SINK_DIR", ""),
+		MailSinkViewer:         getEnv("MAIL_SINK_VIEWER", "false"),
+		SMTPHost:               getEnv("SMTP_HOST", ""),
+		SMTPPort:               getEnv("SMTP_PORT", "587"),
+		SMTPUsername:           getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:           getEnv("SMTP_PASSWORD", ""),
+		SMTPTLS:                getEnv("SMTP_TLS", "starttls"),
+		CloudinaryAPIKey:       getEnv("CLOUDINARY_API_KEY", ""),
+		CloudinaryApiSecret:    getEnv("CLOUDINARY_API_SECRET", ""),
+		CloudinaryName:         getEnv("CLOUDINARY_NAME", ""),
+		ClientUrl:              getEnv("CLIENT_WEBAPP_URL", ""),
+		PublicBaseURL:          getEnv("PUBLIC_BASE_URL", getEnv("OAUTH_REDIRECT_BASE_URL", "http://localhost:8000")),
+		APIToolkitKey:          getEnv("API_TOOLKIT_KEY", ""),
+		SendFromEmail:          getEnv("SEND_FROM_EMAIL", ""),
+		SendFromName:           getEnv("SEND_FROM_NAME", ""),
+		SSLMode:                getEnv("SSL_MODE", "disable"),
+		KafkaBrokers:           getEnv("KAFKA_BROKERS", ""),
+		KafkaVersion:           getEnv("KAFKA_VERSION", ""),
+		KafkaClientID:          getEnv("KAFKA_CLIENT_ID", ""),
+		KafkaConsumerGroup:     getEnv("KAFKA_CONSUMER_GROUP", ""),
 	}
 }
 
@@ -83,3 +192,17 @@ func getEnv(key string, defaultVal string) string {
 
 	return defaultVal
 }
+
+// jwtSecret reads JWT_SECRET. Deployments that still set the old misspelled
+// JWT_SCECRET keep working until they are updated.
+func jwtSecret() string {
+	if secret := getEnv("JWT_SECRET", ""); secret != "" {
+		return secret
+	}
+
+	secret := getEnv("JWT_SCECRET", "")
+	if secret != "" {
+		log.Println("JWT_SCECRET is deprecated, set JWT_SECRET instead")
+	}
+	return secret
+}
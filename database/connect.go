@@ -33,7 +33,7 @@ func Connect(config *Config) {
 		DisableForeignKeyConstraintWhenMigrating: true,
 	})
 
-	DB.AutoMigrate(&models.User{}, &models.GeoLocation{}, &models.UserAgent{})
+	DB.AutoMigrate(&models.User{}, &models.GeoLocation{}, &models.UserAgent{}, &models.RefreshToken{}, &models.Session{}, &models.Identity{}, &models.RecoveryCode{}, &models.WebAuthnCredential{}, &models.OneTimePassword{}, &models.LoginThrottle{}, &models.UsedToken{}, &models.APIToken{}, &models.Role{}, &models.RoleAssignment{}, &models.AuditLog{}, &models.EmailChange{}, &models.PasswordHistory{}, &models.DeviceApproval{}, &models.LoginEvent{})
 
 	DB.Logger.LogMode(logger.Silent)
 
@@ -44,6 +44,13 @@ func Connect(config *Config) {
 		panic("failed to connect database")
 	}
 
+	if err := SeedRoles(DB); err != nil {
+		fmt.Println(
+			err.Error(),
+		)
+		panic("failed to seed roles")
+	}
+
 	fmt.Println("Connection Opened to Database")
 }
 
@@ -0,0 +1,38 @@
+package database
+
+import (
+	"time"
+
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+	"github.com/bjorndonald/golang-backend-template/internal/rbac"
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SeedRoles creates the default roles if they do not exist yet. Roles that
+// are already there are left alone so that edits made by admins survive a
+// restart.
+func SeedRoles(db *gorm.DB) error {
+	for name, permissions := range rbac.DefaultRoles {
+		id, err := uuid.NewV7()
+		if err != nil {
+			return err
+		}
+
+		role := &models.Role{
+			ID:          id,
+			Name:        name,
+			Description: "Built in " + name + " role",
+			Permissions: permissions,
+			CreatedAt:   time.Now(),
+			UpdatedAt:   time.Now(),
+		}
+
+		err = db.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "name"}}, DoNothing: true}).Create(role).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package apitoken
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Kind tells personal access tokens apart from service API keys. The kind is
+// part of the secret's prefix so a bearer token can be routed without a
+// database lookup.
+type Kind string
+
+const (
+	Personal Kind = "personal"
+	Service  Kind = "service"
+)
+
+const (
+	// ReadScope allows safe requests such as GET.
+	ReadScope = "read"
+	// WriteScope allows every other method and implies ReadScope.
+	WriteScope = "write"
+	// AdminScope allows the admin routes. Only service keys can carry it.
+	AdminScope = "admin"
+)
+
+const (
+	secretBytes = 32
+	hintLength  = 6
+)
+
+var prefixes = map[Kind]string{
+	Personal: "pat_",
+	Service:  "sk_",
+}
+
+// Generate returns a new secret of the given kind and the hint stored next
+// to its hash so the owner can recognise it in a listing.
+func Generate(kind Kind) (secret, hint string, err error) {
+	raw := make([]byte, secretBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+
+	secret = prefixes[kind] + base64.RawURLEncoding.EncodeToString(raw)
+	return secret, secret[:len(prefixes[kind])+hintLength], nil
+}
+
+// KindOf reports which kind of API token a bearer token is. It returns false
+// for anything else, such as a JWT.
+func KindOf(token string) (Kind, bool) {
+	for kind, prefix := range prefixes {
+		if strings.HasPrefix(token, prefix) {
+			return kind, true
+		}
+	}
+	return "", false
+}
+
+// Hash returns the stored form of a secret.
+func Hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// JoinScopes renders scopes in the sorted, space separated form they are
+// stored in.
+func JoinScopes(scopes []string) string {
+	seen := map[string]bool{}
+	var unique []string
+	for _, scope := range scopes {
+		if scope != "" && !seen[scope] {
+			seen[scope] = true
+			unique = append(unique, scope)
+		}
+	}
+	sort.Strings(unique)
+	return strings.Join(unique, " ")
+}
+
+// SplitScopes is the inverse of JoinScopes.
+func SplitScopes(scopes string) []string {
+	return strings.Fields(scopes)
+}
+
+// Allows reports whether granted covers scope.
+func Allows(granted []string, scope string) bool {
+	for _, g := range granted {
+		if g == scope || (g == WriteScope && scope == ReadScope) {
+			return true
+		}
+	}
+	return false
+}
+
+// ScopeForMethod returns the scope a request with method needs.
+func ScopeForMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return ReadScope
+	default:
+		return WriteScope
+	}
+}
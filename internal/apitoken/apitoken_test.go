@@ -0,0 +1,65 @@
+package apitoken
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	for _, kind := range []Kind{Personal, Service} {
+		secret, hint, err := Generate(kind)
+		if err != nil {
+			t.Fatalf("Failed to generate %s token: %v", kind, err)
+		}
+
+		if !strings.HasPrefix(secret, hint) {
+			t.Errorf("Expected hint %q to start secret %q", hint, secret)
+		}
+
+		if got, ok := KindOf(secret); !ok || got != kind {
+			t.Errorf("KindOf(%q) = %q, %v, expected %q", secret, got, ok, kind)
+		}
+
+		other, _, err := Generate(kind)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if other == secret || Hash(other) == Hash(secret) {
+			t.Errorf("Expected two %s tokens to differ", kind)
+		}
+	}
+}
+
+func TestKindOfRejectsJWT(t *testing.T) {
+	if _, ok := KindOf("eyJhbGciOiJIUzI1NiJ9.e30.sig"); ok {
+		t.Error("Expected a JWT not to be taken for an API token")
+	}
+}
+
+func TestScopes(t *testing.T) {
+	joined := JoinScopes([]string{"write", "read", "write", ""})
+	if joined != "read write" {
+		t.Errorf("JoinScopes = %q, expected %q", joined, "read write")
+	}
+
+	tests := []struct {
+		granted string
+		method  string
+		allowed bool
+	}{
+		{granted: "read", method: http.MethodGet, allowed: true},
+		{granted: "read", method: http.MethodPost, allowed: false},
+		{granted: "write", method: http.MethodGet, allowed: true},
+		{granted: "write", method: http.MethodDelete, allowed: true},
+		{granted: "admin", method: http.MethodGet, allowed: false},
+		{granted: "", method: http.MethodGet, allowed: false},
+	}
+
+	for _, test := range tests {
+		allowed := Allows(SplitScopes(test.granted), ScopeForMethod(test.method))
+		if allowed != test.allowed {
+			t.Errorf("Allows(%q, %s) = %v, expected %v", test.granted, test.method, allowed, test.allowed)
+		}
+	}
+}
@@ -1,27 +1,245 @@
 package bootstrap
 
 import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/bjorndonald/golang-backend-template/constants"
+	"github.com/bjorndonald/golang-backend-template/internal/geoip"
+	"github.com/bjorndonald/golang-backend-template/internal/helpers"
+	"github.com/bjorndonald/golang-backend-template/internal/keys"
+	"github.com/bjorndonald/golang-backend-template/internal/mail"
+	"github.com/bjorndonald/golang-backend-template/internal/manager"
+	"github.com/bjorndonald/golang-backend-template/internal/otp"
 	"github.com/bjorndonald/golang-backend-template/internal/repository"
+	"github.com/bjorndonald/golang-backend-template/internal/risk"
 	"github.com/bjorndonald/golang-backend-template/internal/service"
 	"github.com/bjorndonald/golang-backend-template/internal/service/streaming"
+	"github.com/bjorndonald/golang-backend-template/resend"
 	"gorm.io/gorm"
 )
 
+// mailboxSize is how many messages the development mail sink keeps.
+const mailboxSize = 100
+
 type AppDependencies struct {
-	EmailService    service.EmailServicer
-	UserRepo        repository.UserRepositoryInterface
-	LocationRepo    repository.LocationRepositoryInterface
-	AgentRepo       repository.AgentRepositoryInterface
-	EventProducer   streaming.EventProducer
-	DatabaseService *gorm.DB
+	EmailService        service.EmailServicer
+	Mailbox             *mail.Sink
+	UserRepo            repository.UserRepositoryInterface
+	LocationRepo        repository.LocationRepositoryInterface
+	AgentRepo           repository.AgentRepositoryInterface
+	RefreshTokenRepo    repository.RefreshTokenRepositoryInterface
+	SessionRepo         repository.SessionRepositoryInterface
+	IdentityRepo        repository.IdentityRepositoryInterface
+	RecoveryCodeRepo    repository.RecoveryCodeRepositoryInterface
+	PasskeyRepo         repository.WebAuthnCredentialRepositoryInterface
+	ThrottleRepo        repository.LoginThrottleRepositoryInterface
+	UsedTokenRepo       repository.UsedTokenRepositoryInterface
+	APITokenRepo        repository.APITokenRepositoryInterface
+	RoleRepo            repository.RoleRepositoryInterface
+	AuditLogRepo        repository.AuditLogRepositoryInterface
+	EmailChangeRepo     repository.EmailChangeRepositoryInterface
+	PasswordHistoryRepo repository.PasswordHistoryRepositoryInterface
+	OTPStore            otp.OTPStore
+	GeoResolver         geoip.GeoResolver
+	DeviceApprovalRepo  repository.DeviceApprovalRepositoryInterface
+	LoginEventRepo      repository.LoginEventRepositoryInterface
+	RiskPolicy          risk.Policy
+	OTPManager          *otp.OTPManager
+	Keys                *keys.Manager
+	StreamManager       *manager.Manager
+	EventProducer       streaming.EventProducer
+	DatabaseService     *gorm.DB
 }
 
 func InitializeDependencies(db *gorm.DB) *AppDependencies {
+	env := constants.New()
+	otpStore := newOTPStore(env.OTPStore, db)
+	otpManager := otp.NewOTPManager(otpStore)
+	if err := otpManager.SetFormats(env.OTPFormats); err != nil {
+		log.Printf("invalid OTP_FORMATS: %v", err)
+	}
+	mailer, mailbox := newMailer(env)
+
 	return &AppDependencies{
-		UserRepo:        repository.NewUserRepository(db),
-		LocationRepo:    repository.NewLocationRepository(db),
-		AgentRepo:       repository.NewAgentRepository(db),
-		EmailService:    service.NewEmailService(),
-		DatabaseService: db,
+		UserRepo:            repository.NewUserRepository(db),
+		LocationRepo:        repository.NewLocationRepository(db),
+		AgentRepo:           repository.NewAgentRepository(db),
+		RefreshTokenRepo:    repository.NewRefreshTokenRepository(db),
+		SessionRepo:         repository.NewSessionRepository(db),
+		IdentityRepo:        repository.NewIdentityRepository(db),
+		RecoveryCodeRepo:    repository.NewRecoveryCodeRepository(db),
+		PasskeyRepo:         repository.NewWebAuthnCredentialRepository(db),
+		ThrottleRepo:        repository.NewLoginThrottleRepository(db),
+		UsedTokenRepo:       repository.NewUsedTokenRepository(db),
+		APITokenRepo:        repository.NewAPITokenRepository(db),
+		RoleRepo:            repository.NewRoleRepository(db),
+		AuditLogRepo:        repository.NewAuditLogRepository(db),
+		EmailChangeRepo:     repository.NewEmailChangeRepository(db),
+		PasswordHistoryRepo: repository.NewPasswordHistoryRepository(db, passwordHistoryDepth(env.PasswordHistory)),
+		StreamManager:       manager.NewGameManager(),
+		OTPStore:            otpStore,
+		GeoResolver:         newGeoResolver(env),
+		DeviceApprovalRepo:  repository.NewDeviceApprovalRepository(db),
+		LoginEventRepo:      repository.NewLoginEventRepository(db),
+		RiskPolicy:          newRiskPolicy(env),
+		OTPManager:          otpManager,
+		Keys:                helpers.KeyManager(),
+		EmailService:        service.NewEmailService(mailer),
+		Mailbox:             mailbox,
+		DatabaseService:     db,
+	}
+}
+
+// newOTPStore returns the OTP store named by OTP_STORE. Codes are kept in
+// Postgres unless the in-memory store is asked for.
+func newOTPStore(kind string, db *gorm.DB) otp.OTPStore {
+	switch kind {
+	case "memory":
+		return otp.NewMemoryStore()
+	case "postgres", "":
+		return repository.NewOTPRepository(db)
+	default:
+		log.Printf("unknown OTP_STORE %q, using postgres", kind)
+		return repository.NewOTPRepository(db)
+	}
+}
+
+// newMailer returns the email transport named by MAIL_TRANSPORT. The sink is
+// returned as well when it is the transport, so its mailbox can be viewed.
+func newMailer(env *constants.Config) (mail.Mailer, *mail.Sink) {
+	switch env.MailTransport {
+	case "resend", "":
+		return mail.NewResendMailer(resend.NewClient(env.ResendApiKey)), nil
+	case "smtp":
+		port, err := strconv.Atoi(env.SMTPPort)
+		if err != nil || port < 1 {
+			log.Printf("invalid SMTP_PORT %q, using 587", env.SMTPPort)
+			port = 587
+		}
+
+		config := mail.SMTPConfig{
+			Host:     env.SMTPHost,
+			Port:     port,
+			Username: env.SMTPUsername,
+			Password: env.SMTPPassword,
+			TLS:      mail.TLSMode(env.SMTPTLS),
+		}
+
+		mailer, err := mail.NewSMTPMailer(config)
+		if err != nil {
+			log.Printf("invalid SMTP_TLS %q, using starttls", env.SMTPTLS)
+			config.TLS = mail.StartTLS
+			mailer, _ = mail.NewSMTPMailer(config)
+		}
+		return mailer, nil
+	case "sink":
+		if env.Env == "production" {
+			log.Fatalf("MAIL_TRANSPORT=sink does not deliver email and cannot be used in production")
+		}
+
+		sink, err := mail.NewSink(env.MailSinkDir, mailboxSize)
+		if err != nil {
+			log.Printf("could not use MAIL_SINK_DIR, keeping email in memory only: %v", err)
+			sink, _ = mail.NewSink("", mailboxSize)
+		}
+		return sink, sink
+	default:
+		log.Printf("unknown MAIL_TRANSPORT %q, using resend", env.MailTransport)
+		return mail.NewResendMailer(resend.NewClient(env.ResendApiKey)), nil
+	}
+}
+
+// passwordHistoryDepth reads PASSWORD_HISTORY, the number of replaced
+// passwords a user cannot go back to.
+func passwordHistoryDepth(value string) int {
+	depth, err := strconv.Atoi(value)
+	if err != nil || depth < 0 {
+		log.Printf("invalid PASSWORD_HISTORY %q, keeping the last 5 passwords", value)
+		return 5
+	}
+	return depth
+}
+
+// newGeoResolver returns the GeoIP source named by GEOIP_PROVIDER, cached in
+// memory. Without a usable source, addresses are recorded without a place.
+func newGeoResolver(env *constants.Config) geoip.GeoResolver {
+	size, err := strconv.Atoi(env.GeoIPCacheSize)
+	if err != nil || size < 0 {
+		log.Printf("invalid GEOIP_CACHE_SIZE %q, caching 10000 addresses", env.GeoIPCacheSize)
+		size = 10000
 	}
+
+	switch env.GeoIPProvider {
+	case "mmdb", "":
+		resolver, err := geoip.OpenMMDB(env.GeoIPDatabase)
+		if err != nil {
+			log.Printf("could not open GEOIP_DATABASE, locations will not be resolved: %v", err)
+			return geoip.NoopResolver{}
+		}
+		return geoip.NewCachedResolver(resolver, size, 24*time.Hour)
+	case "ipinfo":
+		return geoip.NewCachedResolver(geoip.NewIPInfoResolver(env.IPInfoToken), size, 24*time.Hour)
+	case "none":
+		return geoip.NoopResolver{}
+	default:
+		log.Printf("unknown GEOIP_PROVIDER %q, locations will not be resolved", env.GeoIPProvider)
+		return geoip.NoopResolver{}
+	}
+}
+
+// newRiskPolicy builds the login risk policy from the RISK_* settings. A bad
+// setting is logged and left at its default, so logins keep being scored.
+func newRiskPolicy(env *constants.Config) risk.Policy {
+	policy := risk.DefaultPolicy
+
+	if score, err := strconv.Atoi(env.RiskChallengeScore); err == nil {
+		policy.ChallengeAt = score
+	} else {
+		log.Printf("invalid RISK_CHALLENGE_SCORE %q, using %d", env.RiskChallengeScore, policy.ChallengeAt)
+	}
+
+	if score, err := strconv.Atoi(env.RiskApprovalScore); err == nil {
+		policy.ApproveAt = score
+	} else {
+		log.Printf("invalid RISK_APPROVAL_SCORE %q, using %d", env.RiskApprovalScore, policy.ApproveAt)
+	}
+
+	if err := policy.SetWeights(env.RiskWeights); err != nil {
+		log.Printf("invalid RISK_WEIGHTS: %v", err)
+	}
+
+	if speed, err := strconv.ParseFloat(env.RiskMaxTravelSpeed, 64); err == nil && speed >= 0 {
+		policy.MaxSpeed = speed
+	} else {
+		log.Printf("invalid RISK_MAX_TRAVEL_SPEED %q, using %.0f km/h", env.RiskMaxTravelSpeed, policy.MaxSpeed)
+	}
+
+	var start, end int
+	if _, err := fmt.Sscanf(env.RiskQuietHours, "%d-%d", &start, &end); err == nil && start >= 0 && start < 24 && end >= 0 && end < 24 {
+		policy.QuietStart, policy.QuietEnd = start, end
+	} else if env.RiskQuietHours == "" {
+		policy.QuietStart, policy.QuietEnd = 0, 0
+	} else {
+		log.Printf("invalid RISK_QUIET_HOURS %q, using %d-%d", env.RiskQuietHours, policy.QuietStart, policy.QuietEnd)
+	}
+
+	if location, err := time.LoadLocation(env.RiskTimezone); err == nil {
+		policy.Location = location
+	} else {
+		log.Printf("invalid RISK_TIMEZONE %q, using UTC", env.RiskTimezone)
+	}
+
+	if env.RiskIPBlocklist != "" {
+		blocklist, err := risk.LoadIPListFile(env.RiskIPBlocklist)
+		if err != nil {
+			log.Printf("could not load RISK_IP_BLOCKLIST, no addresses are listed: %v", err)
+		} else {
+			policy.Blocklist = blocklist
+		}
+	}
+
+	return policy
 }
@@ -0,0 +1,104 @@
+package geoip
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+)
+
+// CachedResolver remembers the last size addresses another resolver placed,
+// evicting the least recently used first. Entries older than ttl are looked
+// up again. Failed lookups are not cached.
+type CachedResolver struct {
+	next GeoResolver
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	ip       string
+	location models.GeoLocation
+	expires  time.Time
+}
+
+func NewCachedResolver(next GeoResolver, size int, ttl time.Duration) *CachedResolver {
+	return &CachedResolver{
+		next:    next,
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+func (r *CachedResolver) Resolve(ip string) (models.GeoLocation, error) {
+	if location, ok := r.get(ip); ok {
+		return location, nil
+	}
+
+	location, err := r.next.Resolve(ip)
+	if err != nil {
+		return location, err
+	}
+
+	r.put(ip, location)
+	return location, nil
+}
+
+func (r *CachedResolver) get(ip string) (models.GeoLocation, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	element, ok := r.entries[ip]
+	if !ok {
+		return models.GeoLocation{}, false
+	}
+
+	entry := element.Value.(*cacheEntry)
+	if r.now().After(entry.expires) {
+		r.order.Remove(element)
+		delete(r.entries, ip)
+		return models.GeoLocation{}, false
+	}
+
+	r.order.MoveToFront(element)
+	return entry.location, true
+}
+
+func (r *CachedResolver) put(ip string, location models.GeoLocation) {
+	if r.size <= 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	expires := r.now().Add(r.ttl)
+	if element, ok := r.entries[ip]; ok {
+		element.Value = &cacheEntry{ip: ip, location: location, expires: expires}
+		r.order.MoveToFront(element)
+		return
+	}
+
+	r.entries[ip] = r.order.PushFront(&cacheEntry{ip: ip, location: location, expires: expires})
+	for r.order.Len() > r.size {
+		oldest := r.order.Back()
+		r.order.Remove(oldest)
+		delete(r.entries, oldest.Value.(*cacheEntry).ip)
+	}
+}
+
+// Len returns the number of cached addresses.
+func (r *CachedResolver) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.order.Len()
+}
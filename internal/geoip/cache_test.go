@@ -0,0 +1,71 @@
+package geoip
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+)
+
+type countingResolver struct {
+	calls map[string]int
+	err   error
+}
+
+func (r *countingResolver) Resolve(ip string) (models.GeoLocation, error) {
+	r.calls[ip]++
+	return models.GeoLocation{IP: ip, Country: "GB"}, r.err
+}
+
+func TestCachedResolverEvictsLeastRecentlyUsed(t *testing.T) {
+	next := &countingResolver{calls: map[string]int{}}
+	cache := NewCachedResolver(next, 2, time.Hour)
+
+	cache.Resolve("81.2.69.1")
+	cache.Resolve("81.2.69.2")
+	cache.Resolve("81.2.69.1") // now the most recently used
+	cache.Resolve("81.2.69.3") // evicts 81.2.69.2
+
+	if cache.Len() != 2 {
+		t.Fatalf("expected 2 cached addresses, got %d", cache.Len())
+	}
+
+	cache.Resolve("81.2.69.1")
+	cache.Resolve("81.2.69.2")
+
+	if next.calls["81.2.69.1"] != 1 {
+		t.Errorf("expected 81.2.69.1 to be served from the cache, got %d lookups", next.calls["81.2.69.1"])
+	}
+	if next.calls["81.2.69.2"] != 2 {
+		t.Errorf("expected 81.2.69.2 to be looked up again after eviction, got %d lookups", next.calls["81.2.69.2"])
+	}
+}
+
+func TestCachedResolverExpiresEntries(t *testing.T) {
+	next := &countingResolver{calls: map[string]int{}}
+	cache := NewCachedResolver(next, 10, time.Minute)
+
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	cache.Resolve("81.2.69.1")
+	now = now.Add(2 * time.Minute)
+	cache.Resolve("81.2.69.1")
+
+	if next.calls["81.2.69.1"] != 2 {
+		t.Fatalf("expected an expired entry to be looked up again, got %d lookups", next.calls["81.2.69.1"])
+	}
+}
+
+func TestCachedResolverSkipsFailures(t *testing.T) {
+	next := &countingResolver{calls: map[string]int{}, err: errors.New("lookup failed")}
+	cache := NewCachedResolver(next, 10, time.Hour)
+
+	cache.Resolve("81.2.69.1")
+	cache.Resolve("81.2.69.1")
+
+	if next.calls["81.2.69.1"] != 2 || cache.Len() != 0 {
+		t.Fatalf("expected failed lookups not to be cached, got %d lookups and %d entries", next.calls["81.2.69.1"], cache.Len())
+	}
+}
@@ -0,0 +1,67 @@
+package geoip
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// writeFixture builds a small city database in the layout of GeoLite2-City
+// and returns its path.
+func writeFixture(t *testing.T) string {
+	t.Helper()
+
+	writer, err := mmdbwriter.New(mmdbwriter.Options{
+		DatabaseType: "GeoLite2-City",
+		RecordSize:   24,
+		Languages:    []string{"en"},
+	})
+	if err != nil {
+		t.Fatalf("mmdbwriter.New: %v", err)
+	}
+
+	records := map[string]mmdbtype.Map{
+		"81.2.69.0/24":  city("London", "England", "GB", 51.5142, -0.0931),
+		"2a02:ff0::/32": city("Stockholm", "Stockholm County", "SE", 59.3294, 18.0687),
+	}
+	for cidr, record := range records {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatalf("ParseCIDR: %v", err)
+		}
+		if err := writer.Insert(network, record); err != nil {
+			t.Fatalf("Insert %s: %v", cidr, err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "GeoLite2-City-Test.mmdb")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer file.Close()
+
+	if _, err := writer.WriteTo(file); err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+
+	return path
+}
+
+func city(name, region, country string, latitude, longitude float64) mmdbtype.Map {
+	return mmdbtype.Map{
+		"city": mmdbtype.Map{"names": mmdbtype.Map{"en": mmdbtype.String(name)}},
+		"subdivisions": mmdbtype.Slice{
+			mmdbtype.Map{"names": mmdbtype.Map{"en": mmdbtype.String(region)}},
+		},
+		"country": mmdbtype.Map{"iso_code": mmdbtype.String(country)},
+		"location": mmdbtype.Map{
+			"latitude":  mmdbtype.Float64(latitude),
+			"longitude": mmdbtype.Float64(longitude),
+		},
+	}
+}
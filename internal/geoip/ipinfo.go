@@ -0,0 +1,59 @@
+package geoip
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+)
+
+// IPInfoResolver looks addresses up with the ipinfo.io API. Each lookup is
+// a network call, so it is best wrapped in a CachedResolver.
+type IPInfoResolver struct {
+	token   string
+	baseURL string
+	client  *http.Client
+}
+
+// NewIPInfoResolver returns a resolver using token, which may be empty for
+// ipinfo's limited free tier.
+func NewIPInfoResolver(token string) *IPInfoResolver {
+	return &IPInfoResolver{
+		token:   token,
+		baseURL: "https://ipinfo.io",
+		client:  &http.Client{Timeout: 3 * time.Second},
+	}
+}
+
+func (r *IPInfoResolver) Resolve(ip string) (models.GeoLocation, error) {
+	location := models.GeoLocation{IP: ip}
+
+	if net.ParseIP(ip) == nil {
+		return location, fmt.Errorf("%w: %q", ErrInvalidIP, ip)
+	}
+
+	endpoint := fmt.Sprintf("%s/%s/json", r.baseURL, url.PathEscape(ip))
+	if r.token != "" {
+		endpoint += "?token=" + url.QueryEscape(r.token)
+	}
+
+	response, err := r.client.Get(endpoint)
+	if err != nil {
+		return location, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return location, fmt.Errorf("ipinfo returned %s", response.Status)
+	}
+
+	if err := json.NewDecoder(response.Body).Decode(&location); err != nil {
+		return location, err
+	}
+	location.IP = ip
+	return location, nil
+}
@@ -0,0 +1,37 @@
+package geoip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+)
+
+func TestIPInfoResolver(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/81.2.69.142/json" || r.URL.Query().Get("token") != "secret" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"ip":"81.2.69.142","city":"London","region":"England","country":"GB","loc":"51.5142,-0.0931"}`))
+	}))
+	defer server.Close()
+
+	resolver := NewIPInfoResolver("secret")
+	resolver.baseURL = server.URL
+
+	location, err := resolver.Resolve("81.2.69.142")
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+
+	expected := models.GeoLocation{IP: "81.2.69.142", City: "London", Region: "England", Country: "GB", Location: "51.5142,-0.0931"}
+	if location != expected {
+		t.Fatalf("expected %+v, got %+v", expected, location)
+	}
+
+	if _, err := resolver.Resolve("10.0.0.1"); err == nil {
+		t.Fatal("expected an error for a failed lookup")
+	}
+}
@@ -0,0 +1,75 @@
+package geoip
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// cityRecord is the part of a GeoIP2, GeoLite2 or DB-IP city record that is
+// kept.
+type cityRecord struct {
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Subdivisions []struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Location struct {
+		Latitude  float64 `maxminddb:"latitude"`
+		Longitude float64 `maxminddb:"longitude"`
+	} `maxminddb:"location"`
+}
+
+// MMDBResolver looks addresses up in a local MaxMind DB file, such as
+// GeoLite2-City.mmdb or DB-IP's city lite database.
+type MMDBResolver struct {
+	reader *maxminddb.Reader
+}
+
+// OpenMMDB opens the database at path.
+func OpenMMDB(path string) (*MMDBResolver, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &MMDBResolver{reader: reader}, nil
+}
+
+func (r *MMDBResolver) Resolve(ip string) (models.GeoLocation, error) {
+	location := models.GeoLocation{IP: ip}
+
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return location, fmt.Errorf("%w: %q", ErrInvalidIP, ip)
+	}
+
+	var record cityRecord
+	_, found, err := r.reader.LookupNetwork(addr, &record)
+	if err != nil || !found {
+		return location, err
+	}
+
+	location.City = record.City.Names["en"]
+	if len(record.Subdivisions) > 0 {
+		location.Region = record.Subdivisions[0].Names["en"]
+	}
+	location.Country = record.Country.ISOCode
+	if record.Location.Latitude != 0 || record.Location.Longitude != 0 {
+		location.Location = strconv.FormatFloat(record.Location.Latitude, 'f', 4, 64) + "," +
+			strconv.FormatFloat(record.Location.Longitude, 'f', 4, 64)
+	}
+
+	return location, nil
+}
+
+// Close releases the database file.
+func (r *MMDBResolver) Close() error {
+	return r.reader.Close()
+}
@@ -0,0 +1,58 @@
+package geoip
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+)
+
+func TestMMDBResolver(t *testing.T) {
+	resolver, err := OpenMMDB(writeFixture(t))
+	if err != nil {
+		t.Fatalf("OpenMMDB: %v", err)
+	}
+	defer resolver.Close()
+
+	tests := []struct {
+		ip       string
+		expected models.GeoLocation
+	}{
+		{
+			ip:       "81.2.69.142",
+			expected: models.GeoLocation{IP: "81.2.69.142", City: "London", Region: "England", Country: "GB", Location: "51.5142,-0.0931"},
+		},
+		{
+			ip:       "2a02:ff0::1",
+			expected: models.GeoLocation{IP: "2a02:ff0::1", City: "Stockholm", Region: "Stockholm County", Country: "SE", Location: "59.3294,18.0687"},
+		},
+		{
+			ip:       "10.0.0.1",
+			expected: models.GeoLocation{IP: "10.0.0.1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			location, err := resolver.Resolve(tt.ip)
+			if err != nil {
+				t.Fatalf("Resolve: %v", err)
+			}
+			if location != tt.expected {
+				t.Fatalf("expected %+v, got %+v", tt.expected, location)
+			}
+		})
+	}
+}
+
+func TestMMDBResolverRejectsInvalidIP(t *testing.T) {
+	resolver, err := OpenMMDB(writeFixture(t))
+	if err != nil {
+		t.Fatalf("OpenMMDB: %v", err)
+	}
+	defer resolver.Close()
+
+	if _, err := resolver.Resolve("not-an-ip"); !errors.Is(err, ErrInvalidIP) {
+		t.Fatalf("expected ErrInvalidIP, got %v", err)
+	}
+}
@@ -0,0 +1,24 @@
+// Package geoip places client IP addresses on the map.
+package geoip
+
+import (
+	"errors"
+
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+)
+
+var ErrInvalidIP = errors.New("invalid IP address")
+
+// GeoResolver finds where an IP address is. Addresses it has no data for,
+// such as private ones, resolve to a location with only the IP set.
+type GeoResolver interface {
+	Resolve(ip string) (models.GeoLocation, error)
+}
+
+// NoopResolver resolves every address to a location with only the IP set.
+// It is used when no GeoIP source is configured.
+type NoopResolver struct{}
+
+func (NoopResolver) Resolve(ip string) (models.GeoLocation, error) {
+	return models.GeoLocation{IP: ip}, nil
+}
@@ -0,0 +1,603 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bjorndonald/golang-backend-template/internal/bootstrap"
+	"github.com/bjorndonald/golang-backend-template/internal/helpers"
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+	"github.com/bjorndonald/golang-backend-template/internal/otp"
+	"github.com/bjorndonald/golang-backend-template/internal/rbac"
+	"github.com/bjorndonald/golang-backend-template/internal/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+)
+
+var (
+	errSelfAction       = errors.New("admins cannot do this to their own account")
+	errOutranked        = errors.New("user has permissions you do not have")
+	errNotSuspended     = errors.New("account is not suspended")
+	errAlreadyVerified  = errors.New("email is already verified")
+	errUnknownRole      = errors.New("role must be user or admin")
+	errImpersonateAdmin = errors.New("users with admin access cannot be impersonated")
+)
+
+// ImpersonationToken is returned when an admin starts impersonating a user.
+type ImpersonationToken struct {
+	AccessToken string    `json:"access_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+type AdminHandler struct {
+	deps *bootstrap.AppDependencies
+}
+
+func NewAdminHandler(deps *bootstrap.AppDependencies) *AdminHandler {
+	return &AdminHandler{
+		deps: deps,
+	}
+}
+
+// userInfo copies the fields of user that admins get to see.
+func userInfo(user *models.User) models.UserInfo {
+	info := models.UserInfo{
+		Email:         user.Email,
+		LastLogin:     user.LastLogin,
+		IP:            user.IP,
+		Photo:         user.Photo,
+		ID:            user.ID,
+		Role:          user.Role,
+		EmailVerified: user.EmailVerified,
+		Country:       user.Country,
+		PhoneNumber:   user.PhoneNumber,
+		FirstName:     user.FirstName,
+		LastName:      user.LastName,
+		Status:        user.Status,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+	}
+	if user.DeletedAt.Valid {
+		info.DeletedAt = &user.DeletedAt.Time
+	}
+	return info
+}
+
+// userIDParam reads the :id path parameter.
+func userIDParam(c *gin.Context) (uuid.UUID, bool) {
+	userID, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		helpers.ReturnError(c, "Invalid user", err, http.StatusBadRequest)
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+// findUserParam loads the user named by the :id path parameter.
+func findUserParam(c *gin.Context, deps *bootstrap.AppDependencies) (*models.User, bool) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return nil, false
+	}
+
+	user, found, err := deps.UserRepo.FindByCondition("id = ?", userID.String())
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return nil, false
+	}
+
+	if !found {
+		helpers.ReturnError(c, "User not found", fmt.Errorf("user not found"), http.StatusNotFound)
+		return nil, false
+	}
+
+	return user, true
+}
+
+// findManagedUser loads the user named by the :id path parameter and checks
+// that the authenticated admin may act on them: not on themselves, and not
+// on anyone whose roles grant something the admin's do not.
+func findManagedUser(c *gin.Context, deps *bootstrap.AppDependencies) (*models.User, bool) {
+	user, ok := findUserParam(c, deps)
+	if !ok {
+		return nil, false
+	}
+
+	claims, err := helpers.GetAuthenticatedUser(c)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return nil, false
+	}
+
+	if claims.UserId == user.ID.String() {
+		helpers.ReturnError(c, "Unauthorized access to resource", errSelfAction, http.StatusForbidden)
+		return nil, false
+	}
+
+	permissions, err := deps.RoleRepo.PermissionsForUser(user)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return nil, false
+	}
+
+	granted := helpers.GetPermissions(c)
+	for _, permission := range permissions {
+		if !rbac.Allows(granted, permission) {
+			helpers.ReturnError(c, "Unauthorized access to resource", errOutranked, http.StatusForbidden)
+			return nil, false
+		}
+	}
+
+	return user, true
+}
+
+// UnlockUser is a route handler that lifts a lock placed on an account after failed logins.
+//
+// @Summary Unlock account
+// @Description Clears the failed login count of a user and lifts the lock
+// @Tags Admin
+// @Produce json
+// @Param id path string true "User ID"
+// @Security BearerAuth
+// @Success 200 {object} SuccessResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /admin/users/{id}/unlock [post]
+func (a *AdminHandler) UnlockUser(c *gin.Context) {
+	user, ok := findUserParam(c, a.deps)
+	if !ok {
+		return
+	}
+
+	if err := unlockAccount(a.deps, user); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	recordAudit(c, a.deps, models.AuditUserUnlock, "user", user.ID.String(), nil)
+
+	helpers.ReturnJSON(c, "Account unlocked", user, http.StatusOK)
+}
+
+// ListUsers is a route handler that lists users a page at a time.
+//
+// @Summary List users
+// @Description Lists users, newest first. X-Total-Count gives the number of matching users.
+// @Tags Admin
+// @Produce json
+// @Param status query string false "Account status, for example Active or Suspended"
+// @Param role query string false "Role column, user or admin"
+// @Param email_verified query bool false "Only users whose email is or is not verified"
+// @Param q query string false "Part of the email address or name"
+// @Param deleted query bool false "List soft deleted users instead"
+// @Param page query int false "Page number, starting at 1"
+// @Param per_page query int false "Users per page, at most 100"
+// @Security BearerAuth
+// @Success 200 {object} UserListResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /admin/users [get]
+func (a *AdminHandler) ListUsers(c *gin.Context) {
+	offset, limit := pageParams(c)
+
+	filter := repository.UserFilter{
+		Status: c.Query("status"),
+		Role:   c.Query("role"),
+		Search: c.Query("q"),
+		Offset: offset,
+		Limit:  limit,
+	}
+
+	if value := c.Query("email_verified"); value != "" {
+		verified, err := strconv.ParseBool(value)
+		if err != nil {
+			helpers.ReturnError(c, "Invalid email_verified filter", err, http.StatusBadRequest)
+			return
+		}
+		filter.EmailVerified = &verified
+	}
+
+	if value := c.Query("deleted"); value != "" {
+		deleted, err := strconv.ParseBool(value)
+		if err != nil {
+			helpers.ReturnError(c, "Invalid deleted filter", err, http.StatusBadRequest)
+			return
+		}
+		filter.Deleted = deleted
+	}
+
+	users, total, err := a.deps.UserRepo.List(filter)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	helpers.ReturnJSON(c, "Users retrieved", users, http.StatusOK)
+}
+
+// GetUser is a route handler that returns one user, including soft deleted ones.
+//
+// @Summary Get user
+// @Description Returns a user by ID
+// @Tags Admin
+// @Produce json
+// @Param id path string true "User ID"
+// @Security BearerAuth
+// @Success 200 {object} UserResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /admin/users/{id} [get]
+func (a *AdminHandler) GetUser(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	user, found, err := a.deps.UserRepo.FindIncludingDeleted(userID)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	if !found {
+		helpers.ReturnError(c, "User not found", fmt.Errorf("user not found"), http.StatusNotFound)
+		return
+	}
+
+	helpers.ReturnJSON(c, "User retrieved", userInfo(user), http.StatusOK)
+}
+
+// SuspendUser is a route handler that suspends an account and signs it out everywhere.
+//
+// @Summary Suspend user
+// @Description Suspends the account, revokes its sessions and stops its API tokens from working
+// @Tags Admin
+// @Produce json
+// @Param id path string true "User ID"
+// @Security BearerAuth
+// @Success 200 {object} UserResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /admin/users/{id}/suspend [post]
+func (a *AdminHandler) SuspendUser(c *gin.Context) {
+	user, ok := findManagedUser(c, a.deps)
+	if !ok {
+		return
+	}
+
+	previous := user.Status
+	user.Status = models.SuspendedAccount
+	if err := a.deps.UserRepo.UpdateColumns(user, "status"); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := revokeAllSessions(a.deps, user.ID, uuid.Nil); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	recordAudit(c, a.deps, models.AuditUserSuspend, "user", user.ID.String(), map[string]interface{}{"previous_status": previous})
+
+	helpers.ReturnJSON(c, "User suspended", userInfo(user), http.StatusOK)
+}
+
+// ReactivateUser is a route handler that lifts a suspension.
+//
+// @Summary Reactivate user
+// @Description Lifts a suspension. Accounts whose email is not verified go back to Inactive.
+// @Tags Admin
+// @Produce json
+// @Param id path string true "User ID"
+// @Security BearerAuth
+// @Success 200 {object} UserResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /admin/users/{id}/reactivate [post]
+func (a *AdminHandler) ReactivateUser(c *gin.Context) {
+	user, ok := findManagedUser(c, a.deps)
+	if !ok {
+		return
+	}
+
+	if user.Status != models.SuspendedAccount {
+		helpers.ReturnError(c, "Account is not suspended", errNotSuspended, http.StatusConflict)
+		return
+	}
+
+	user.Status = models.ActiveAccount
+	if !user.EmailVerified {
+		user.Status = models.InactiveAccount
+	}
+
+	if err := a.deps.UserRepo.UpdateColumns(user, "status"); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	recordAudit(c, a.deps, models.AuditUserReactivate, "user", user.ID.String(), map[string]interface{}{"status": user.Status})
+
+	helpers.ReturnJSON(c, "User reactivated", userInfo(user), http.StatusOK)
+}
+
+// ForcePasswordReset is a route handler that makes a user choose a new password before logging in again.
+//
+// @Summary Force password reset
+// @Description Marks the password as outdated and revokes every session of the user
+// @Tags Admin
+// @Produce json
+// @Param id path string true "User ID"
+// @Security BearerAuth
+// @Success 200 {object} SuccessResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /admin/users/{id}/force-password-reset [post]
+func (a *AdminHandler) ForcePasswordReset(c *gin.Context) {
+	user, ok := findManagedUser(c, a.deps)
+	if !ok {
+		return
+	}
+
+	user.AuthVersion = models.Outdated
+	if err := a.deps.UserRepo.UpdateColumns(user, "auth_version"); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := revokeAllSessions(a.deps, user.ID, uuid.Nil); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	recordAudit(c, a.deps, models.AuditUserForcePasswordReset, "user", user.ID.String(), nil)
+
+	helpers.ReturnJSON(c, "User will have to reset their password", nil, http.StatusOK)
+}
+
+// ChangeUserRole is a route handler that changes the role column of a user.
+//
+// Roles assigned through /admin/users/{id}/roles are not affected.
+//
+// @Summary Change user role
+// @Description Sets the user's role to user or admin. Only admins who hold every permission of the new role can do this.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param id path string true "User ID"
+// @Param credentials body UpdateRoleInput true "New role"
+// @Security BearerAuth
+// @Success 200 {object} UserResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /admin/users/{id}/role [put]
+func (a *AdminHandler) ChangeUserRole(c *gin.Context) {
+	var input UpdateRoleInput
+	validatedReqBody, exists := c.Get("validatedRequestBody")
+
+	if !exists {
+		helpers.ReturnError(c, "Error parsing request", fmt.Errorf(helpers.INVALID_REQUEST_BODY), http.StatusBadRequest)
+		return
+	}
+
+	input, ok := validatedReqBody.(UpdateRoleInput)
+	if !ok {
+		helpers.ReturnError(c, "Error parsing request", fmt.Errorf(helpers.REQUEST_BODY_PARSE_ERROR), http.StatusBadRequest)
+		return
+	}
+
+	role := models.AccountRole(input.Role)
+	if role != models.UserRole && role != models.AdminRole {
+		helpers.ReturnError(c, "Invalid role", errUnknownRole, http.StatusBadRequest)
+		return
+	}
+
+	user, ok := findManagedUser(c, a.deps)
+	if !ok {
+		return
+	}
+
+	granted, found, err := a.deps.RoleRepo.FindByName(input.Role)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	if found && !checkGrantable(c, granted.Permissions) {
+		return
+	}
+
+	previous := user.Role
+	user.Role = role
+	if err := a.deps.UserRepo.UpdateColumns(user, "role"); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	a.deps.RoleRepo.Forget(user.ID)
+
+	recordAudit(c, a.deps, models.AuditUserChangeRole, "user", user.ID.String(), map[string]interface{}{"from": previous, "to": role})
+
+	helpers.ReturnJSON(c, "User role changed", userInfo(user), http.StatusOK)
+}
+
+// ResendVerification is a route handler that sends a user a new email verification link.
+//
+// @Summary Resend verification email
+// @Description Sends a new verification link to a user whose email is not verified yet
+// @Tags Admin
+// @Produce json
+// @Param id path string true "User ID"
+// @Security BearerAuth
+// @Success 200 {object} SuccessResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse "Retry-After gives the seconds until a new link can be sent"
+// @Router /admin/users/{id}/resend-verification [post]
+func (a *AdminHandler) ResendVerification(c *gin.Context) {
+	user, ok := findUserParam(c, a.deps)
+	if !ok {
+		return
+	}
+
+	if user.EmailVerified {
+		helpers.ReturnError(c, "Email is already verified", errAlreadyVerified, http.StatusConflict)
+		return
+	}
+
+	code, ok := issueOTP(c, a.deps, user.Email, otp.EmailVerificationOTP)
+	if !ok {
+		return
+	}
+
+	go a.deps.EmailService.SendNewUserEmail(user.FirstName, user.Email, helpers.GetBaseURL(c), code)
+
+	recordAudit(c, a.deps, models.AuditUserResendVerification, "user", user.ID.String(), nil)
+
+	helpers.ReturnJSON(c, "Verification email sent", nil, http.StatusOK)
+}
+
+// DeleteUser is a route handler that deletes a user.
+//
+// By default the account is soft deleted: it can no longer be used and is
+// left out of listings, but its row is kept. Passing hard=true removes the
+// user and everything stored against them, and also works on an account
+// that was already soft deleted.
+//
+// @Summary Delete user
+// @Description Soft deletes a user, or removes them for good with hard=true
+// @Tags Admin
+// @Produce json
+// @Param id path string true "User ID"
+// @Param hard query bool false "Remove the user and their data instead of soft deleting"
+// @Security BearerAuth
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /admin/users/{id} [delete]
+func (a *AdminHandler) DeleteUser(c *gin.Context) {
+	hard := false
+	if value := c.Query("hard"); value != "" {
+		var err error
+		hard, err = strconv.ParseBool(value)
+		if err != nil {
+			helpers.ReturnError(c, "Invalid hard parameter", err, http.StatusBadRequest)
+			return
+		}
+	}
+
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	user, found, err := a.deps.UserRepo.FindIncludingDeleted(userID)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	if !found || (user.DeletedAt.Valid && !hard) {
+		helpers.ReturnError(c, "User not found", fmt.Errorf("user not found"), http.StatusNotFound)
+		return
+	}
+
+	// A soft deleted user was already checked when they were soft deleted,
+	// and has no sessions left to revoke.
+	if !user.DeletedAt.Valid {
+		if _, ok := findManagedUser(c, a.deps); !ok {
+			return
+		}
+
+		if err := revokeAllSessions(a.deps, user.ID, uuid.Nil); err != nil {
+			helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	action := models.AuditUserSoftDelete
+	if hard {
+		action = models.AuditUserHardDelete
+		err = a.deps.UserRepo.HardDelete(user.ID)
+	} else {
+		err = a.deps.UserRepo.SoftDelete(user.ID)
+	}
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	a.deps.RoleRepo.Forget(user.ID)
+
+	recordAudit(c, a.deps, action, "user", user.ID.String(), map[string]interface{}{"email": user.Email})
+
+	helpers.ReturnJSON(c, "User deleted", nil, http.StatusOK)
+}
+
+// ImpersonateUser is a route handler that lets an admin act as a user to see what they see.
+//
+// The token belongs to no session, cannot be refreshed and stops working
+// early if the admin loses the users:impersonate permission. Every request
+// made with it is written to the audit log, and routes that change how the
+// account signs in refuse it.
+//
+// @Summary Impersonate user
+// @Description Returns a short lived access token for the user with the admin in its act claim. Users with admin access cannot be impersonated.
+// @Tags Admin
+// @Produce json
+// @Param id path string true "User ID"
+// @Security BearerAuth
+// @Success 200 {object} ImpersonationToken
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /admin/users/{id}/impersonate [post]
+func (a *AdminHandler) ImpersonateUser(c *gin.Context) {
+	user, ok := findUserParam(c, a.deps)
+	if !ok {
+		return
+	}
+
+	admin, ok := loadAuthenticatedUser(c, a.deps)
+	if !ok {
+		return
+	}
+
+	if admin.ID == user.ID {
+		helpers.ReturnError(c, "Unauthorized access to resource", errSelfAction, http.StatusForbidden)
+		return
+	}
+
+	permissions, err := a.deps.RoleRepo.PermissionsForUser(user)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	// Anyone with a permission could reach admin routes as themselves, so
+	// only plain users can be impersonated.
+	if user.Role == models.AdminRole || len(permissions) > 0 {
+		helpers.ReturnError(c, "Unauthorized access to resource", errImpersonateAdmin, http.StatusForbidden)
+		return
+	}
+
+	actor := helpers.Actor{Sub: admin.ID.String(), Email: admin.Email}
+	token, claims, err := helpers.GenerateImpersonationToken(user.Email, user.FirstName, user.ID.String(), actor)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	recordAudit(c, a.deps, models.AuditUserImpersonate, "user", user.ID.String(), map[string]interface{}{"token_id": claims.Id})
+
+	helpers.ReturnJSON(c, "Impersonation started", ImpersonationToken{
+		AccessToken: token,
+		ExpiresAt:   time.Unix(claims.ExpiresAt, 0),
+	}, http.StatusOK)
+}
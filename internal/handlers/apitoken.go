@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bjorndonald/golang-backend-template/internal/apitoken"
+	"github.com/bjorndonald/golang-backend-template/internal/bootstrap"
+	"github.com/bjorndonald/golang-backend-template/internal/helpers"
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+)
+
+var (
+	errAPITokenNotFound = errors.New("api token not found")
+	errAdminScope       = errors.New("only service keys can have the admin scope")
+)
+
+type CreateAPITokenInput struct {
+	Name          string   `json:"name" validate:"required,max=64"`
+	Scopes        []string `json:"scopes" validate:"required,min=1,dive,oneof=read write admin"`
+	ExpiresInDays int      `json:"expires_in_days" validate:"omitempty,min=1,max=365"`
+}
+
+// CreatedAPIToken is returned once when a token is made. The secret cannot
+// be read back afterwards.
+type CreatedAPIToken struct {
+	*models.APIToken
+	Token string `json:"token"`
+}
+
+// createAPIToken stores a new token of kind for the user and returns it with
+// its secret.
+func createAPIToken(deps *bootstrap.AppDependencies, userID uuid.UUID, kind apitoken.Kind, input CreateAPITokenInput) (*CreatedAPIToken, error) {
+	secret, hint, err := apitoken.Generate(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return nil, err
+	}
+
+	token := &models.APIToken{
+		ID:        id,
+		UserID:    userID,
+		Kind:      string(kind),
+		Name:      input.Name,
+		Hint:      hint,
+		TokenHash: apitoken.Hash(secret),
+		Scopes:    apitoken.JoinScopes(input.Scopes),
+		CreatedAt: time.Now(),
+	}
+
+	if input.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, input.ExpiresInDays)
+		token.ExpiresAt = &expiresAt
+	}
+
+	if err := deps.APITokenRepo.Create(token); err != nil {
+		return nil, err
+	}
+
+	return &CreatedAPIToken{APIToken: token, Token: secret}, nil
+}
+
+// createAPITokenInput reads the validated request body.
+func createAPITokenInput(c *gin.Context) (CreateAPITokenInput, bool) {
+	validatedReqBody, exists := c.Get("validatedRequestBody")
+
+	if !exists {
+		helpers.ReturnError(c, "Error parsing request", fmt.Errorf(helpers.INVALID_REQUEST_BODY), http.StatusBadRequest)
+		return CreateAPITokenInput{}, false
+	}
+
+	input, ok := validatedReqBody.(CreateAPITokenInput)
+	if !ok {
+		helpers.ReturnError(c, "Error parsing request", fmt.Errorf(helpers.REQUEST_BODY_PARSE_ERROR), http.StatusBadRequest)
+		return CreateAPITokenInput{}, false
+	}
+
+	return input, true
+}
+
+// CreateAccessToken is a route handler that creates a personal access token for the authenticated user.
+//
+// The secret is only returned in this response.
+//
+// @Summary Create personal access token
+// @Description Creates a token scripts can send as a Bearer token instead of logging in
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param credentials body CreateAPITokenInput true "Token name, scopes and lifetime"
+// @Security BearerAuth
+// @Success 201 {object} CreatedAPIToken
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /user/tokens [post]
+func (u *UserHandler) CreateAccessToken(c *gin.Context) {
+	input, ok := createAPITokenInput(c)
+	if !ok {
+		return
+	}
+
+	if apitoken.Allows(input.Scopes, apitoken.AdminScope) {
+		helpers.ReturnError(c, "Personal access tokens cannot have the admin scope", errAdminScope, http.StatusBadRequest)
+		return
+	}
+
+	user, ok := u.authenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	token, err := createAPIToken(u.deps, user.ID, apitoken.Personal, input)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	helpers.ReturnJSON(c, "Token created, copy it now as it will not be shown again", token, http.StatusCreated)
+}
+
+// ListAccessTokens is a route handler that lists the personal access tokens of the authenticated user.
+//
+// @Summary List personal access tokens
+// @Description Lists the authenticated user's tokens that have not been revoked
+// @Tags User
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.APIToken
+// @Failure 401 {object} ErrorResponse
+// @Router /user/tokens [get]
+func (u *UserHandler) ListAccessTokens(c *gin.Context) {
+	user, ok := u.authenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	tokens, err := u.deps.APITokenRepo.FindByUserID(user.ID, string(apitoken.Personal))
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	helpers.ReturnJSON(c, "Tokens retrieved", tokens, http.StatusOK)
+}
+
+// RevokeAccessToken is a route handler that revokes one of the authenticated user's personal access tokens.
+//
+// @Summary Revoke personal access token
+// @Description Revokes a personal access token so it can no longer be used
+// @Tags User
+// @Produce json
+// @Param id path string true "Token ID"
+// @Security BearerAuth
+// @Success 200 {object} SuccessResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /user/tokens/{id} [delete]
+func (u *UserHandler) RevokeAccessToken(c *gin.Context) {
+	user, ok := u.authenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	token, ok := findAPIToken(c, u.deps, apitoken.Personal)
+	if !ok {
+		return
+	}
+
+	if token.UserID != user.ID {
+		helpers.ReturnError(c, "Token not found", errAPITokenNotFound, http.StatusNotFound)
+		return
+	}
+
+	if err := u.deps.APITokenRepo.Revoke(token.ID); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	helpers.ReturnJSON(c, "Token revoked", nil, http.StatusOK)
+}
+
+// findAPIToken loads the live token of kind named in the path.
+func findAPIToken(c *gin.Context, deps *bootstrap.AppDependencies, kind apitoken.Kind) (*models.APIToken, bool) {
+	tokenID, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		helpers.ReturnError(c, "Invalid token", err, http.StatusBadRequest)
+		return nil, false
+	}
+
+	token, found, err := deps.APITokenRepo.Find(tokenID)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return nil, false
+	}
+
+	if !found || token.Kind != string(kind) || token.RevokedAt != nil {
+		helpers.ReturnError(c, "Token not found", errAPITokenNotFound, http.StatusNotFound)
+		return nil, false
+	}
+
+	return token, true
+}
+
+// CreateAPIKey is a route handler that creates a service API key owned by the authenticated admin.
+//
+// The secret is only returned in this response.
+//
+// @Summary Create service API key
+// @Description Creates a key other services can send as a Bearer token. It stops working if its owner loses the api_keys:write permission.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param credentials body CreateAPITokenInput true "Key name, scopes and lifetime"
+// @Security BearerAuth
+// @Success 201 {object} CreatedAPIToken
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /admin/api-keys [post]
+func (a *AdminHandler) CreateAPIKey(c *gin.Context) {
+	input, ok := createAPITokenInput(c)
+	if !ok {
+		return
+	}
+
+	admin, ok := loadAuthenticatedUser(c, a.deps)
+	if !ok {
+		return
+	}
+
+	token, err := createAPIToken(a.deps, admin.ID, apitoken.Service, input)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	recordAudit(c, a.deps, models.AuditAPIKeyCreate, "api_key", token.ID.String(), map[string]interface{}{"name": token.Name, "scopes": token.Scopes})
+
+	helpers.ReturnJSON(c, "API key created, copy it now as it will not be shown again", token, http.StatusCreated)
+}
+
+// ListAPIKeys is a route handler that lists every service API key.
+//
+// @Summary List service API keys
+// @Description Lists the service keys that have not been revoked
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.APIToken
+// @Failure 401 {object} ErrorResponse
+// @Router /admin/api-keys [get]
+func (a *AdminHandler) ListAPIKeys(c *gin.Context) {
+	tokens, err := a.deps.APITokenRepo.FindByKind(string(apitoken.Service))
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	helpers.ReturnJSON(c, "API keys retrieved", tokens, http.StatusOK)
+}
+
+// RevokeAPIKey is a route handler that revokes a service API key.
+//
+// @Summary Revoke service API key
+// @Description Revokes a service key so it can no longer be used
+// @Tags Admin
+// @Produce json
+// @Param id path string true "API key ID"
+// @Security BearerAuth
+// @Success 200 {object} SuccessResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /admin/api-keys/{id} [delete]
+func (a *AdminHandler) RevokeAPIKey(c *gin.Context) {
+	token, ok := findAPIToken(c, a.deps, apitoken.Service)
+	if !ok {
+		return
+	}
+
+	if err := a.deps.APITokenRepo.Revoke(token.ID); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	recordAudit(c, a.deps, models.AuditAPIKeyRevoke, "api_key", token.ID.String(), map[string]interface{}{"name": token.Name})
+
+	helpers.ReturnJSON(c, "API key revoked", nil, http.StatusOK)
+}
@@ -1,9 +1,11 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 
 	"encoding/json"
+	"log"
 	"net/http"
 	"strings"
 	"time"
@@ -12,23 +14,37 @@ import (
 	"github.com/bjorndonald/golang-backend-template/internal/bootstrap"
 	"github.com/bjorndonald/golang-backend-template/internal/helpers"
 	"github.com/bjorndonald/golang-backend-template/internal/models"
+	"github.com/bjorndonald/golang-backend-template/internal/oauth"
 	"github.com/bjorndonald/golang-backend-template/internal/otp"
+	"github.com/bjorndonald/golang-backend-template/internal/passkey"
+	"github.com/bjorndonald/golang-backend-template/internal/passwords"
+	"github.com/bjorndonald/golang-backend-template/internal/risk"
+	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/gofrs/uuid"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type AuthHandler struct {
-	deps *bootstrap.AppDependencies
+	deps           *bootstrap.AppDependencies
+	oauthProviders map[string]*oauth.Provider
+	passkeys       *webauthn.WebAuthn
 }
 
 func NewAuthHandler(
 	deps *bootstrap.AppDependencies,
 ) *AuthHandler {
+	// Password and OAuth login keep working when the passkey settings are
+	// invalid, so the error only disables the passkey endpoints.
+	passkeys, err := passkey.New(constant)
+	if err != nil {
+		log.Printf("passkey login disabled: %v", err)
+	}
+
 	return &AuthHandler{
-		deps: deps,
+		deps:           deps,
+		oauthProviders: oauth.NewProviders(constant),
+		passkeys:       passkeys,
 	}
 }
 
@@ -36,6 +52,13 @@ var (
 	constant = constants.New()
 )
 
+// publicURL returns the address of path on this API as seen from outside.
+// Links sent by email are built with it rather than from the request, whose
+// Host header the client controls.
+func publicURL(path string) string {
+	return strings.TrimSuffix(constant.PublicBaseURL, "/") + path
+}
+
 type ErrorResponse struct {
 	Data    interface{} `json:"data"`
 	Message string      `json:"message"`
@@ -65,7 +88,7 @@ type AuthenticateUser struct {
 
 type InputCreateUser struct {
 	Email           string `json:"email" validate:"required,email"`
-	Password        string `json:"password" validate:"required"`
+	Password        string `json:"password" validate:"required,password"`
 	ConfirmPassword string `json:"confirm_password" validate:"required"`
 	FirstName       string `json:"first_name" validate:"required"`
 	LastName        string `json:"last_name" validate:"required"`
@@ -97,7 +120,7 @@ type EmailInput struct {
 
 // ? ResetPasswordInput struct
 type ResetPasswordInput struct {
-	Password        string `json:"password" validate:"required"`
+	Password        string `json:"password" validate:"required,password"`
 	PasswordConfirm string `json:"passwordConfirm" validate:"required"`
 }
 
@@ -110,20 +133,6 @@ type OtpInput struct {
 	OTP string `json:"otp" validate:"required"`
 }
 
-func checkAgent(userAgent, newAgent models.UserAgent) bool {
-	if userAgent.OS == newAgent.OS && userAgent.Platform == newAgent.Platform && userAgent.BrowserName == newAgent.BrowserName && userAgent.Model == newAgent.Model && userAgent.Mobile == newAgent.Mobile {
-		return true
-	}
-	return false
-}
-
-func checkLocation(userLocation, newLocation models.GeoLocation) bool {
-	if userLocation.City == newLocation.City && userLocation.Country == newLocation.Country && userLocation.Region == newLocation.Region {
-		return true
-	}
-	return false
-}
-
 // Authenticate authenticates a user and generates a JWT token.
 //
 // @Summary Authenticate User
@@ -134,6 +143,8 @@ func checkLocation(userLocation, newLocation models.GeoLocation) bool {
 // @Param credentials body AuthenticateUser true "User credentials (email and password)"
 // @Success 200 {object} LoginResponse
 // @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse "The device has to be approved from an emailed link first"
+// @Failure 429 {object} ErrorResponse "Too many failed attempts, Retry-After gives the seconds to wait"
 // @Failure 500 {object} ErrorResponse
 // @Router /auth/login [post]
 func (a *AuthHandler) Authenticate(c *gin.Context) {
@@ -151,6 +162,10 @@ func (a *AuthHandler) Authenticate(c *gin.Context) {
 		return
 	}
 
+	if ipBlocked(c, a.deps) {
+		return
+	}
+
 	user, userExist, err := a.deps.UserRepo.FindByCondition("email = ?", strings.ToLower(input.Email))
 	if err != nil {
 		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
@@ -158,93 +173,134 @@ func (a *AuthHandler) Authenticate(c *gin.Context) {
 	}
 
 	if !userExist {
+		recordLoginFailure(c, a.deps, nil)
+		recordLoginEvent(c, a.deps, nil, models.LoginEvent{Type: models.LoginFailed, Email: strings.ToLower(input.Email), Method: passwordLogin, Reason: "unknown_account"})
 		helpers.ReturnError(c, "Something went wrong", fmt.Errorf("invalid account credentials"), http.StatusBadRequest)
 		return
 	}
 
-	clientUrl := constant.ClientUrl
-
-	timeNow, err := helpers.TimeNow("Africa/Lagos")
-	if err != nil {
-		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+	if accountLocked(c, user) {
+		recordLoginEvent(c, a.deps, user, models.LoginEvent{Type: models.LoginFailed, Method: passwordLogin, Reason: "account_locked"})
 		return
 	}
 
+	clientUrl := constant.ClientUrl
+
 	if !user.EmailVerified {
+		recordLoginEvent(c, a.deps, user, models.LoginEvent{Type: models.LoginFailed, Method: passwordLogin, Reason: "email_not_verified"})
 		helpers.ReturnError(c, "Account not active", fmt.Errorf("account not verified"), http.StatusBadRequest)
 		return
 	}
 
-	userAgent, _, err := a.deps.AgentRepo.FindByCondition("user_id = ?", user.ID.String())
-	if err != nil {
-		helpers.ReturnError(c, "Something went wrong", fmt.Errorf("could not get user device info"), http.StatusInternalServerError)
+	if user.AuthVersion == models.Outdated {
+		c.Header("Access-Control-Allow-Origin", "*")
+		c.JSON(http.StatusFound, fmt.Sprintf("%s/auth/forgot-password", clientUrl))
 		return
 	}
 
-	userLocation, _, err := a.deps.LocationRepo.FindByCondition("user_id = ?", user.ID.String())
+	match, rehash, err := passwords.CurrentHasher().Verify(input.Password, user.Password)
+	if err == nil && !match {
+		err = errWrongPassword
+	}
+
 	if err != nil {
-		helpers.ReturnError(c, "Something went wrong", fmt.Errorf("could not get user location info"), http.StatusInternalServerError)
+		recordLoginEvent(c, a.deps, user, models.LoginEvent{Type: models.LoginFailed, Method: passwordLogin, Reason: "wrong_password"})
+		recordLoginFailure(c, a.deps, user)
+		helpers.ReturnError(c, "Email and Password is not correct", err, http.StatusBadRequest)
+		return
+	}
+
+	if user.Status == models.SuspendedAccount {
+		recordLoginEvent(c, a.deps, user, models.LoginEvent{Type: models.LoginFailed, Method: passwordLogin, Reason: "account_suspended"})
+		helpers.ReturnError(c, "Account suspended", errAccountSuspended, http.StatusForbidden)
 		return
 	}
 
-	loc, agent, err := helpers.GetDeviceLocation(c)
+	loc, agent, err := helpers.GetDeviceLocation(c, a.deps.GeoResolver)
+	if err != nil {
+		log.Printf("could not resolve login location: %v", err)
+	}
+
+	fingerprint, err := currentDeviceFingerprint(c, agent)
 	if err != nil {
 		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
 		return
 	}
 
-	userLocationCheck := checkLocation(*userLocation, loc)
-	if !userLocationCheck {
-		baseURL := helpers.GetBaseURL(c)
+	device, knownDevice, err := a.deps.AgentRepo.FindByFingerprint(user.ID, fingerprint)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", fmt.Errorf("could not get user device info"), http.StatusInternalServerError)
+		return
+	}
 
-		user.LastLogin = timeNow
+	_, hasDevices, err := a.deps.AgentRepo.FindByUserID(user.ID)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", fmt.Errorf("could not get user device info"), http.StatusInternalServerError)
+		return
+	}
 
-		_, err = a.deps.UserRepo.Save(user)
+	// An address that could not be placed says nothing about where the user
+	// is, so it is not reported as a new location.
+	newLocation := false
+	if loc.Country != "" {
+		_, knownLocation, err := a.deps.LocationRepo.FindByPlace(user.ID, loc)
 		if err != nil {
-			helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+			helpers.ReturnError(c, "Something went wrong", fmt.Errorf("could not get user location info"), http.StatusInternalServerError)
 			return
 		}
 
-		go a.deps.EmailService.SendNewLocationEmail(user.FirstName, user.Email, baseURL, loc)
-		// helpers.ReturnError(c, "New device needs authorization", fmt.Errorf("New device needs authorization"), http.StatusBadRequest)
-		// c.Redirect(http.StatusFound, fmt.Sprintf("%s/auth/location", clientUrl))
-		// return
+		_, hasLocations, err := a.deps.LocationRepo.FindByUserID(user.ID)
+		if err != nil {
+			helpers.ReturnError(c, "Something went wrong", fmt.Errorf("could not get user location info"), http.StatusInternalServerError)
+			return
+		}
+		newLocation = hasLocations && !knownLocation
 	}
 
-	userAgentCheck := checkAgent(*userAgent, agent)
-	if !userAgentCheck {
-		baseURL := helpers.GetBaseURL(c)
+	// The first device an account logs in from is not news to its owner.
+	newDevice := hasDevices && !knownDevice
 
-		user.LastLogin = timeNow
+	assessment, err := assessLogin(c, a.deps, user, device, newDevice, loc)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
 
-		_, err = a.deps.UserRepo.Save(user)
+	// A login risky enough to need approval only goes on once the user has
+	// approved the device from their inbox, and then only once.
+	if assessment.Decision == risk.Approve {
+		approved, err := deviceApproved(c, a.deps, user, fingerprint, loc, assessment)
 		if err != nil {
 			helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
 			return
 		}
 
-		go a.deps.EmailService.SendNewDeviceEmail(user.FirstName, user.Email, baseURL, agent)
-		// helpers.ReturnError(c, "New device needs authorization", fmt.Errorf("New device needs authorization"), http.StatusBadRequest)
-		// c.Redirect(http.StatusFound, fmt.Sprintf("%s/auth/device", clientUrl))
-		// return
+		if !approved {
+			recordLoginEvent(c, a.deps, user, models.LoginEvent{Type: models.LoginChallenged, Method: "device_approval", Reason: strings.Join(assessment.Signals, ",")})
+			helpers.ReturnError(c, "Check your email to approve this device", errDeviceApproval, http.StatusForbidden)
+			return
+		}
 	}
 
-	if user.AuthVersion == models.Outdated {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.JSON(http.StatusFound, fmt.Sprintf("%s/auth/forgot-password", clientUrl))
-		return
+	baseURL := helpers.GetBaseURL(c)
+	if newLocation {
+		go a.deps.EmailService.SendNewLocationEmail(user.FirstName, user.Email, baseURL, loc)
+	}
+	if newDevice {
+		recordLoginEvent(c, a.deps, user, models.LoginEvent{Type: models.LoginNewDevice, Method: passwordLogin, Reason: deviceName(agent)})
+		go a.deps.EmailService.SendNewDeviceEmail(user.FirstName, user.Email, baseURL, agent)
 	}
 
-	hashedPassword := []byte(user.Password)
-	plainPassword := []byte(input.Password)
-	err = bcrypt.CompareHashAndPassword(hashedPassword, plainPassword)
-
-	if err != nil {
-		helpers.ReturnError(c, "Email and Password is not correct", err, http.StatusBadRequest)
-		return
+	// A hash made with an older algorithm or weaker settings is replaced
+	// while the plain password is at hand, and saved with the login below.
+	if rehash {
+		if hash, err := helpers.HashPassword(input.Password); err == nil {
+			user.Password = hash
+		} else {
+			log.Printf("rehashing password of user %s: %v", user.ID, err)
+		}
 	}
 
-	user.LastLogin = timeNow
 	user.IP = c.ClientIP()
 
 	_, err = a.deps.UserRepo.Save(user)
@@ -253,21 +309,50 @@ func (a *AuthHandler) Authenticate(c *gin.Context) {
 		return
 	}
 
-	accessToken, err := helpers.GenerateAccessToken(constant.JWTSecretKey, user.Email, user.FirstName, (user.ID).String())
+	// A challenged login needs an emailed code on top of the user's usual
+	// second factor, even if email codes are turned off.
+	stepUp := assessment.Decision == risk.Challenge
+	methods := twoFactorMethodsQuery(user)
+
+	var accessToken string
+	if stepUp {
+		accessToken, err = helpers.GenerateStepUpTwoFactorToken(user.Email, user.FirstName, user.ID.String())
+		if !user.EmailOTPEnabled {
+			methods = strings.TrimPrefix(methods+","+string(models.EmailOTPMethod), ",")
+		}
+	} else {
+		accessToken, err = helpers.GenerateTwoFactorToken(user.Email, user.FirstName, user.ID.String())
+	}
 	if err != nil {
 		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
 		return
 	}
 
-	go a.deps.EmailService.SendOTPEmail(user.FirstName, user.Email)
+	if user.EmailOTPEnabled || stepUp {
+		if err := sendLoginCode(a.deps, user); err != nil {
+			helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	challenge := models.LoginEvent{Type: models.LoginChallenged, Method: methods}
+	if stepUp {
+		challenge.Reason = strings.Join(assessment.Signals, ",")
+	}
+	recordLoginEvent(c, a.deps, user, challenge)
+
+	redirect := fmt.Sprintf("%s/auth/2fa?token=%s&methods=%s", clientUrl, accessToken, methods)
+	if stepUp {
+		redirect += "&step_up=email"
+	}
 
 	c.Header("Access-Control-Allow-Origin", "*")
-	c.JSON(http.StatusFound, fmt.Sprintf("%s/auth/2fa?token=%s", clientUrl, accessToken))
+	c.JSON(http.StatusFound, redirect)
 }
 
 // LogOut is a route handler that logs out the user.
 //
-// This endpoint is used to log out the user.
+// This endpoint revokes the current session and clears the refresh token cookie.
 //
 // @Summary Log out User
 // @Description Log out User
@@ -276,11 +361,62 @@ func (a *AuthHandler) Authenticate(c *gin.Context) {
 // @Produce json
 // @Success 200 {string} string "Returns 'success' "
 // @Failure 400 {string} string "Returns error message"
-// @Router /auth/2fa/{token} [post]
+// @Router /auth/logout [post]
 func (a *AuthHandler) LogOut(c *gin.Context) {
+	refreshToken, err := c.Cookie(refreshTokenCookie)
 
-	c.SetCookie("refreshToken", "", 0, "/", "", true, true)
-	helpers.ReturnJSON(c, "OTP sent successfully", nil, http.StatusOK)
+	if err == nil && refreshToken != "" {
+		record, found, err := a.deps.RefreshTokenRepo.FindByHash(helpers.HashToken(refreshToken))
+		if err != nil {
+			helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+			return
+		}
+
+		if found {
+			if err := revokeSession(a.deps, record.UserID, record.FamilyID); err != nil {
+				helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+
+	clearRefreshToken(c)
+	helpers.ReturnJSON(c, "Logged out successfully", nil, http.StatusOK)
+}
+
+// LogOutAll is a route handler that logs the user out of every session.
+//
+// This endpoint revokes all sessions and refresh tokens of the authenticated user.
+//
+// @Summary Log out of all sessions
+// @Description Revokes every session belonging to the authenticated user
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {string} string "Returns 'success' "
+// @Failure 401 {object} ErrorResponse
+// @Router /auth/logout/all [post]
+func (a *AuthHandler) LogOutAll(c *gin.Context) {
+	claims, err := helpers.GetAuthenticatedUser(c)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := uuid.FromString(claims.UserId)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusUnauthorized)
+		return
+	}
+
+	if err := revokeAllSessions(a.deps, userID, uuid.Nil); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	clearRefreshToken(c)
+	helpers.ReturnJSON(c, "Logged out of all sessions", nil, http.StatusOK)
 }
 
 // Send2faEmail is a route handler that send an otp to user's email address.
@@ -295,19 +431,14 @@ func (a *AuthHandler) LogOut(c *gin.Context) {
 // @Param credentials body Email true "Email"
 // @Success 200 {string} string "Returns 'success' "
 // @Failure 400 {string} string "Returns error message"
+// @Failure 429 {object} ErrorResponse "Retry-After gives the seconds until a new code can be sent"
 // @Router /auth/2fa/{token} [post]
 func (a *AuthHandler) Send2FAEmail(c *gin.Context) {
 	accessToken := c.Params.ByName("token")
 
-	token, err := jwt.ParseWithClaims(
-		accessToken, &helpers.AuthTokenJwtClaim{}, func(token *jwt.Token) (interface{}, error) {
-			return []byte(constant.JWTSecretKey), nil
-		})
-
-	claims := token.Claims.(*helpers.AuthTokenJwtClaim)
-
+	claims, err := helpers.ValidateTwoFactorToken(accessToken)
 	if err != nil {
-		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		helpers.ReturnError(c, "Invalid or expired token", err, http.StatusUnauthorized)
 		return
 	}
 
@@ -318,7 +449,17 @@ func (a *AuthHandler) Send2FAEmail(c *gin.Context) {
 		return
 	}
 
-	go a.deps.EmailService.SendOTPEmail(user.FirstName, user.Email)
+	if !user.EmailOTPEnabled && !claims.StepUp {
+		helpers.ReturnError(c, "Email codes are not enabled", fmt.Errorf("email otp not enabled"), http.StatusBadRequest)
+		return
+	}
+
+	code, ok := issueOTP(c, a.deps, user.Email, otp.LoginOTP)
+	if !ok {
+		return
+	}
+
+	go a.deps.EmailService.SendOTPEmail(user.FirstName, user.Email, code)
 
 	helpers.ReturnJSON(c, "OTP sent successfully", nil, http.StatusOK)
 }
@@ -353,43 +494,7 @@ func (a *AuthHandler) Verify2FAEmail(c *gin.Context) {
 		return
 	}
 
-	authToken, err := jwt.ParseWithClaims(
-		token, &helpers.AuthTokenJwtClaim{}, func(token *jwt.Token) (interface{}, error) {
-			return []byte(constant.JWTSecretKey), nil
-		})
-
-	claims := authToken.Claims.(*helpers.AuthTokenJwtClaim)
-
-	if err != nil {
-		helpers.ReturnError(c, "Access token is not valid", err, http.StatusInternalServerError)
-		return
-	}
-
-	valid := otp.OTPManage.VerifyOTP(claims.Email, input.OTP)
-
-	if !valid {
-		helpers.ReturnJSON(c, "OTP not valid", nil, http.StatusBadRequest)
-		return
-	}
-
-	accessToken, err := helpers.GenerateAccessToken(constant.JWTSecretKey, claims.Email, claims.Name, claims.UserId)
-	if err != nil {
-		helpers.ReturnError(c, "Could not generate token", err, http.StatusInternalServerError)
-		return
-	}
-
-	refreshToken, err := helpers.GenerateRefreshToken(constant.JWTSecretKey, claims.Email, claims.Name, claims.UserId)
-	if err != nil {
-		helpers.ReturnError(c, "Could not generate token", err, http.StatusInternalServerError)
-		return
-	}
-
-	c.SetCookie("refreshToken", refreshToken, 60*60, "/", "", true, true)
-
-	helpers.ReturnJSON(c, "Authenticated successfully", map[string]interface{}{
-		"access_token": accessToken,
-		"expires_in":   time.Now().Local().Add(time.Minute * 15),
-	}, http.StatusOK)
+	a.completeTwoFactor(c, token, models.EmailOTPMethod, input.OTP, "")
 }
 
 // Authenticate authenticates a user and generates a JWT token.
@@ -405,36 +510,72 @@ func (a *AuthHandler) Verify2FAEmail(c *gin.Context) {
 // @Failure 500 {object} ErrorResponse
 // @Router /auth/refresh-token [post]
 func (a *AuthHandler) RefreshToken(c *gin.Context) {
-	refreshToken, err := c.Cookie("refreshToken")
+	refreshToken, err := c.Cookie(refreshTokenCookie)
 
 	if err != nil {
-		helpers.ReturnError(c, "Refresh token missing", err, http.StatusInternalServerError)
+		helpers.ReturnError(c, "Refresh token missing", err, http.StatusUnauthorized)
 		return
 	}
 
-	claims, err := helpers.ValidateToken(refreshToken)
+	if _, err := helpers.ValidateRefreshToken(refreshToken); err != nil {
+		helpers.ReturnError(c, "Invalid refresh token", err, http.StatusUnauthorized)
+		return
+	}
+
+	record, found, err := a.deps.RefreshTokenRepo.FindByHash(helpers.HashToken(refreshToken))
 	if err != nil {
-		helpers.ReturnError(c, "Invalid refresh token", err, http.StatusInternalServerError)
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
 		return
 	}
 
-	accessToken, err := helpers.GenerateAccessToken(constant.JWTSecretKey, claims.Email, claims.Name, claims.UserId)
+	if !found || record.RevokedAt != nil || time.Now().After(record.ExpiresAt) {
+		clearRefreshToken(c)
+		helpers.ReturnError(c, "Invalid refresh token", fmt.Errorf("refresh token is not active"), http.StatusUnauthorized)
+		return
+	}
+
+	// The user is read again rather than trusted from the claims, so a new
+	// email address is picked up and suspended accounts cannot refresh.
+	user, found, err := a.deps.UserRepo.FindByCondition("id = ?", record.UserID.String())
 	if err != nil {
 		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
 		return
 	}
 
-	refreshToken, err = helpers.GenerateRefreshToken(constant.JWTSecretKey, claims.Email, claims.Name, claims.UserId)
+	if !found || user.Status == models.SuspendedAccount {
+		clearRefreshToken(c)
+		helpers.ReturnError(c, "Invalid refresh token", fmt.Errorf("account is not active"), http.StatusUnauthorized)
+		return
+	}
+
+	rotated, err := a.deps.RefreshTokenRepo.MarkUsed(record.ID)
 	if err != nil {
 		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
 		return
 	}
 
-	c.SetCookie("refreshToken", refreshToken, 60*60, "/", "", true, true)
+	// A refresh token that has already been rotated is being replayed, so
+	// the whole family is treated as compromised.
+	if !rotated {
+		if err := revokeSession(a.deps, record.UserID, record.FamilyID); err != nil {
+			helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+			return
+		}
+
+		clearRefreshToken(c)
+		helpers.ReturnError(c, "Invalid refresh token", fmt.Errorf("refresh token reuse detected"), http.StatusUnauthorized)
+		return
+	}
+
+	accessToken, err := issueTokenPair(c, a.deps, user.Email, user.FirstName, user.ID.String(), record)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
 
 	helpers.ReturnJSON(c, "Refreshed successfully", map[string]interface{}{
 		"access_token": accessToken,
-		"expires_in":   time.Now().Local().Add(time.Minute * 15),
+		"expires_in":   time.Now().Local().Add(helpers.AccessTokenTTL),
 	}, http.StatusOK)
 }
 
@@ -525,7 +666,13 @@ func (a *AuthHandler) CreateUser(c *gin.Context) {
 
 	baseURL := helpers.GetBaseURL(c)
 
-	go a.deps.EmailService.SendNewUserEmail(user.FirstName, user.Email, baseURL)
+	code, err := a.deps.OTPManager.GenerateOTP(user.Email, otp.EmailVerificationOTP)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	go a.deps.EmailService.SendNewUserEmail(user.FirstName, user.Email, baseURL, code)
 
 	eventJSON, err := json.Marshal(user)
 	if err != nil {
@@ -567,86 +714,43 @@ func (a *AuthHandler) VerifyEmail(c *gin.Context) {
 		return
 	}
 
-	location, agent, err := helpers.GetDeviceLocation(c)
-	if err != nil {
-		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
-		return
-	}
-
-	agentID, err := uuid.NewV7()
-	if err != nil {
-		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
-		return
-	}
-
-	agent.ID = agentID
-	agent.UserID = user.ID
-
-	if err := a.deps.AgentRepo.Create(&agent); err != nil {
-		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
-		return
-	}
-
-	locationID, err := uuid.NewV7()
-	if err != nil {
-		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+	// The link signs the user in only once. Followed again after the
+	// account is verified, it would sign in anyone who knows the address.
+	if user.EmailVerified {
+		c.Redirect(http.StatusFound, fmt.Sprintf("%s/auth/signin?status=already_verified", clientUrl))
 		return
 	}
 
-	userLocation := &models.GeoLocation{
-		UserID:   user.ID,
-		ID:       locationID,
-		IP:       location.IP,
-		City:     location.City,
-		Country:  location.Country,
-		Region:   location.Region,
-		Location: location.Location,
-	}
+	outcome, err := a.deps.OTPManager.VerifyOTP(email, otp.EmailVerificationOTP, token)
 
-	if err := a.deps.LocationRepo.Create(userLocation); err != nil {
-		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+	if err != nil || outcome != otp.OutcomeOK {
+		c.Redirect(http.StatusFound, fmt.Sprintf("%s/auth/signin?error=401V", clientUrl))
 		return
 	}
 
-	accessToken, err := helpers.GenerateAccessToken(constant.JWTSecretKey, user.Email, user.FirstName, user.ID.String())
-
-	if err != nil {
-		c.Redirect(http.StatusFound, fmt.Sprintf("%s/auth/signin?error=500", clientUrl))
-		return
-	}
+	user.EmailVerified = true
+	user.Status = models.ActiveAccount
+	user.UpdatedAt = time.Now()
 
-	refreshToken, err := helpers.GenerateRefreshToken(constant.JWTSecretKey, user.Email, user.FirstName, user.ID.String())
+	_, err = a.deps.UserRepo.Save(user)
 
 	if err != nil {
 		c.Redirect(http.StatusFound, fmt.Sprintf("%s/auth/signin?error=500", clientUrl))
 		return
 	}
 
-	if user.EmailVerified {
-		c.SetCookie("refreshToken", refreshToken, 60*60, "/", "", true, true)
-		c.Redirect(http.StatusFound, fmt.Sprintf("%s/auth/signin?&access_token=%s", clientUrl, accessToken))
-		return
-	}
-
-	valid := otp.OTPManage.VerifyOTP(email, token)
+	accessToken, err := issueTokenPair(c, a.deps, user.Email, user.FirstName, user.ID.String(), nil)
 
-	if !valid {
-		c.Redirect(http.StatusFound, fmt.Sprintf("%s/auth/signin?error=401V", clientUrl))
+	if errors.Is(err, errPasswordResetRequired) {
+		c.Redirect(http.StatusFound, fmt.Sprintf("%s/auth/signin?error=password_reset_required", clientUrl))
 		return
 	}
-
-	user.EmailVerified = true
-	user.Status = models.ActiveAccount
-	user.UpdatedAt = time.Now()
-
-	_, err = a.deps.UserRepo.Save(user)
-
 	if err != nil {
 		c.Redirect(http.StatusFound, fmt.Sprintf("%s/auth/signin?error=500", clientUrl))
 		return
 	}
+	recordLoginEvent(c, a.deps, user, models.LoginEvent{Type: models.LoginSucceeded, Method: emailVerificationLogin})
 
-	c.SetCookie("refreshToken", refreshToken, 60*60, "/", "", true, true)
 	c.Redirect(http.StatusFound, fmt.Sprintf("%s/auth/signin?&access_token=%s", clientUrl, accessToken))
 }
 
@@ -662,6 +766,7 @@ func (a *AuthHandler) VerifyEmail(c *gin.Context) {
 // @Param credentials body EmailInput true "Input (email)"
 // @Success 200 {string} string "Returns 'success' "
 // @Failure 400 {string} string "Returns error message"
+// @Failure 429 {object} ErrorResponse "Retry-After gives the seconds until a new code can be sent"
 // @Router /auth/forgot-password [post]
 func (a *AuthHandler) ForgotPassword(c *gin.Context) {
 	var input EmailInput
@@ -691,7 +796,12 @@ func (a *AuthHandler) ForgotPassword(c *gin.Context) {
 	if strings.Contains(fullName, " ") {
 		fullName = strings.Split(fullName, " ")[1]
 	}
-	a.deps.EmailService.SendForgotPasswordEmail(fullName, email)
+	code, ok := issueOTP(c, a.deps, email, otp.PasswordResetOTP)
+	if !ok {
+		return
+	}
+
+	a.deps.EmailService.SendForgotPasswordEmail(fullName, email, code)
 	clientUrl := constant.ClientUrl
 
 	// helpers.ReturnJSON(c, "Action successful", nil, http.StatusOK)
@@ -727,6 +837,10 @@ func (a *AuthHandler) VerifyResetOTP(c *gin.Context) {
 		return
 	}
 
+	if ipBlocked(c, a.deps) {
+		return
+	}
+
 	user, userExist, err := a.deps.UserRepo.FindByCondition("email", input.Email)
 
 	if err != nil {
@@ -735,21 +849,22 @@ func (a *AuthHandler) VerifyResetOTP(c *gin.Context) {
 	}
 
 	if !userExist {
+		recordLoginFailure(c, a.deps, nil)
 		helpers.ReturnError(c, "User does not exist", fmt.Errorf("user account does not exist"), http.StatusBadRequest)
 		return
 	}
 
-	valid := otp.OTPManage.VerifyOTP(input.Email, input.OTP)
-
-	if !valid {
-		helpers.ReturnError(c, "OTP not valid", fmt.Errorf("invalid opt"), http.StatusBadRequest)
+	// Reset codes have their own attempt limit, so only the client address
+	// is counted here. Counting the account would let anyone lock it.
+	if !checkOTP(c, a.deps, user.Email, otp.PasswordResetOTP, input.OTP) {
+		recordLoginFailure(c, a.deps, nil)
 		return
 	}
 
-	jwtToken, err := helpers.GenerateAccessToken(constant.JWTSecretKey, user.Email, user.FirstName, user.ID.String())
+	jwtToken, err := helpers.GenerateResetToken(user.Email, user.FirstName, user.ID.String())
 
 	if err != nil {
-		helpers.ReturnError(c, "Error generating access token", err, http.StatusInternalServerError)
+		helpers.ReturnError(c, "Error generating reset token", err, http.StatusInternalServerError)
 		return
 	}
 
@@ -768,6 +883,7 @@ func (a *AuthHandler) VerifyResetOTP(c *gin.Context) {
 // @Param credentials body ResetPasswordInput true "New password and password confirmation"
 // @Success 200 {string} string "Success: Password reset"
 // @Failure 400 {string} string "Error: Invalid input or token"
+// @Failure 401 {object} ErrorResponse "The token is not a reset token, has expired or was already used"
 // @Router /auth/reset-password/confirm/{reset-token} [post]
 func (a *AuthHandler) ResetPassword(c *gin.Context) {
 	resetToken := c.Params.ByName("reset-token")
@@ -787,15 +903,9 @@ func (a *AuthHandler) ResetPassword(c *gin.Context) {
 		return
 	}
 
-	token, err := jwt.ParseWithClaims(
-		resetToken, &helpers.AuthTokenJwtClaim{}, func(token *jwt.Token) (interface{}, error) {
-			return []byte(constant.JWTSecretKey), nil
-		})
-
-	claims := token.Claims.(*helpers.AuthTokenJwtClaim)
-
+	claims, err := helpers.ValidateResetToken(resetToken)
 	if err != nil {
-		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		helpers.ReturnError(c, "Invalid or expired token", err, http.StatusUnauthorized)
 		return
 	}
 
@@ -816,19 +926,45 @@ func (a *AuthHandler) ResetPassword(c *gin.Context) {
 		return
 	}
 
-	oldPassword := []byte(user.Password)
-	plainPassword := []byte(input.Password)
-	err = bcrypt.CompareHashAndPassword(oldPassword, plainPassword)
-	if err == nil {
-		helpers.ReturnError(c, "Please input a different password than one used before.", fmt.Errorf("Password is the same"), http.StatusBadRequest)
+	if weakPassword(c, user, input.Password) {
+		return
+	}
+
+	reused, err := passwordUsedBefore(a.deps, user, input.Password)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	if reused {
+		helpers.ReturnError(c, "Please input a different password than one used before.", errPasswordReused, http.StatusBadRequest)
+		return
+	}
+
+	// The jti is spent last so that a request rejected above does not use
+	// up the link.
+	fresh, err := a.deps.UsedTokenRepo.Consume(claims.Id, time.Unix(claims.ExpiresAt, 0))
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	if !fresh {
+		helpers.ReturnError(c, "Reset link has already been used", fmt.Errorf("reset token replayed"), http.StatusUnauthorized)
+		return
+	}
+
+	if err := setPassword(a.deps, user, input.Password); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
 		return
 	}
 
-	hashedPassword, _ := helpers.HashPassword(input.Password)
-	user.Password = hashedPassword
 	user.EmailVerified = true
 	user.AuthVersion = models.UpToDate
-	user.Status = models.ActiveAccount
+	// A reset link proves the inbox, not that a suspension was lifted.
+	if user.Status != models.SuspendedAccount {
+		user.Status = models.ActiveAccount
+	}
 	user.UpdatedAt = time.Now()
 
 	_, err = a.deps.UserRepo.Save(user)
@@ -838,5 +974,6 @@ func (a *AuthHandler) ResetPassword(c *gin.Context) {
 		return
 	}
 
+	recordLoginEvent(c, a.deps, user, models.LoginEvent{Type: models.LoginPasswordReset, Method: "reset_link"})
 	helpers.ReturnJSON(c, "Password updated successfully", user, http.StatusOK)
 }
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bjorndonald/golang-backend-template/internal/helpers"
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+)
+
+func TestRefreshTokenReplayRevokesFamily(t *testing.T) {
+	a := newTestAuth(t)
+	a.router.POST("/refresh-token", a.handler.RefreshToken)
+
+	tokenID, familyID := newID(t), newID(t)
+	first, err := helpers.GenerateRefreshToken(a.user.Email, a.user.FirstName, a.user.ID.String(), tokenID.String())
+	if err != nil {
+		t.Fatalf("refresh token: %v", err)
+	}
+	a.refreshTokens.tokens[tokenID] = &models.RefreshToken{
+		ID:        tokenID,
+		UserID:    a.user.ID,
+		FamilyID:  familyID,
+		TokenHash: helpers.HashToken(first),
+		ExpiresAt: time.Now().Add(helpers.RefreshTokenTTL),
+		CreatedAt: time.Now(),
+	}
+
+	refresh := func(token string) (int, string) {
+		recorder := a.do(http.MethodPost, "/refresh-token", &http.Cookie{Name: refreshTokenCookie, Value: token})
+		return recorder.Code, responseCookie(recorder, refreshTokenCookie)
+	}
+
+	code, second := refresh(first)
+	if code != http.StatusOK || second == "" || second == first {
+		t.Fatalf("expected a rotated refresh token, got %d %q", code, second)
+	}
+
+	if code, _ := refresh(first); code != http.StatusUnauthorized {
+		t.Fatalf("expected the replayed token to be refused, got %d", code)
+	}
+
+	if !a.sessions.revoked[familyID] {
+		t.Errorf("expected the session to be revoked after a replay")
+	}
+	for _, token := range a.refreshTokens.tokens {
+		if token.FamilyID == familyID && token.RevokedAt == nil {
+			t.Errorf("expected refresh token %s in the family to be revoked", token.ID)
+		}
+	}
+
+	if code, _ := refresh(second); code != http.StatusUnauthorized {
+		t.Errorf("expected the token rotated before the replay to be refused, got %d", code)
+	}
+}
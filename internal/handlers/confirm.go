@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+
+	"github.com/bjorndonald/golang-backend-template/internal/helpers"
+	"github.com/gin-gonic/gin"
+)
+
+var confirmationPage = template.Must(template.New("confirmation").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Text}}</p>
+<form method="post">
+<button type="submit">{{.Button}}</button>
+</form>
+</body>
+</html>
+`))
+
+// confirmation is the text of a page asking the user to confirm what an
+// emailed link does.
+type confirmation struct {
+	Title  string
+	Text   string
+	Button string
+}
+
+// renderConfirmation shows a page whose button posts back to the link.
+// Mail scanners open links but do not submit forms, so links that change
+// something only do it on the POST.
+func renderConfirmation(c *gin.Context, page confirmation) {
+	var body bytes.Buffer
+	if err := confirmationPage.Execute(&body, page); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	// The token is in the URL, so it should not leave in a Referer header
+	// or be kept by a cache.
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", body.Bytes())
+}
@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bjorndonald/golang-backend-template/internal/bootstrap"
+	"github.com/bjorndonald/golang-backend-template/internal/helpers"
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+)
+
+const (
+	deviceCookie       = "deviceId"
+	deviceCookiePath   = "/api/v1"
+	deviceCookieLength = 32
+	deviceCookieMaxAge = 400 * 24 * 60 * 60
+)
+
+var errDeviceNotFound = errors.New("device not found")
+
+// UpdateDeviceInput renames a device or changes whether it is trusted.
+type UpdateDeviceInput struct {
+	Name    *string `json:"name" validate:"required_without=Trusted,omitempty,min=1,max=64"`
+	Trusted *bool   `json:"trusted" validate:"required_without=Name"`
+}
+
+// deviceID returns the browser's device cookie, creating one if it does not
+// have one yet. The cookie is set again on every login so it does not run
+// out on a device that is still in use.
+func deviceID(c *gin.Context) (string, error) {
+	id, err := c.Cookie(deviceCookie)
+	if err != nil || len(id) != deviceCookieLength {
+		id, err = helpers.GenerateRandomString(deviceCookieLength)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	c.SetCookie(deviceCookie, id, deviceCookieMaxAge, deviceCookiePath, "", true, true)
+	return id, nil
+}
+
+// currentDeviceFingerprint fingerprints the device the request comes from.
+func currentDeviceFingerprint(c *gin.Context, agent models.UserAgent) (string, error) {
+	id, err := deviceID(c)
+	if err != nil {
+		return "", err
+	}
+	return helpers.DeviceFingerprint(id, agent), nil
+}
+
+// deviceName is what a new device is called until the user renames it.
+func deviceName(agent models.UserAgent) string {
+	switch {
+	case agent.BrowserName != "" && agent.OS != "":
+		return fmt.Sprintf("%s on %s", agent.BrowserName, agent.OS)
+	case agent.BrowserName != "":
+		return agent.BrowserName
+	default:
+		return "Unknown device"
+	}
+}
+
+// rememberDevice adds the device the request comes from to the user's known
+// devices, or records that it was seen again.
+func rememberDevice(c *gin.Context, deps *bootstrap.AppDependencies, userID uuid.UUID, agent models.UserAgent, location models.GeoLocation) (*models.UserAgent, error) {
+	fingerprint, err := currentDeviceFingerprint(c, agent)
+	if err != nil {
+		return nil, err
+	}
+
+	device, found, err := deps.AgentRepo.FindByFingerprint(userID, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+
+	if found {
+		return device, deps.AgentRepo.Touch(device.ID, c.ClientIP(), location.City, location.Country)
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return nil, err
+	}
+
+	device = &models.UserAgent{
+		ID:          id,
+		UserID:      userID,
+		Fingerprint: fingerprint,
+		Name:        deviceName(agent),
+		Platform:    agent.Platform,
+		OS:          agent.OS,
+		BrowserName: agent.BrowserName,
+		Mobile:      agent.Mobile,
+		Model:       agent.Model,
+		IP:          c.ClientIP(),
+		City:        location.City,
+		Country:     location.Country,
+		FirstSeenAt: time.Now(),
+		LastSeenAt:  time.Now(),
+	}
+
+	return device, deps.AgentRepo.Create(device)
+}
+
+// rememberLocation adds the place the request comes from to the user's known
+// locations, or records that it was seen again. Addresses that could not be
+// placed are not recorded.
+func rememberLocation(c *gin.Context, deps *bootstrap.AppDependencies, userID uuid.UUID, location models.GeoLocation) error {
+	if location.Country == "" {
+		return nil
+	}
+
+	known, found, err := deps.LocationRepo.FindByPlace(userID, location)
+	if err != nil {
+		return err
+	}
+
+	if found {
+		return deps.LocationRepo.Touch(known.ID, c.ClientIP())
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return err
+	}
+
+	return deps.LocationRepo.Create(&models.GeoLocation{
+		ID:          id,
+		UserID:      userID,
+		IP:          c.ClientIP(),
+		City:        location.City,
+		Region:      location.Region,
+		Country:     location.Country,
+		Location:    location.Location,
+		FirstSeenAt: time.Now(),
+		LastSeenAt:  time.Now(),
+	})
+}
+
+// ListDevices is a route handler that lists the devices the authenticated user has logged in from.
+//
+// @Summary List devices
+// @Description Lists the authenticated user's known devices, most recently seen first
+// @Tags User
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.UserAgent
+// @Failure 401 {object} ErrorResponse
+// @Router /user/devices [get]
+func (u *UserHandler) ListDevices(c *gin.Context) {
+	user, ok := u.authenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	devices, _, err := u.deps.AgentRepo.FindByUserID(user.ID)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	if devices == nil {
+		devices = []*models.UserAgent{}
+	}
+
+	if claims, err := helpers.GetAuthenticatedUser(c); err == nil {
+		session, found, err := u.deps.SessionRepo.Find(uuid.FromStringOrNil(claims.SessionId))
+		if err == nil && found {
+			for _, device := range devices {
+				device.Current = device.ID == session.DeviceID
+			}
+		}
+	}
+
+	helpers.ReturnJSON(c, "Devices retrieved", devices, http.StatusOK)
+}
+
+// UpdateDevice is a route handler that renames one of the authenticated user's devices or changes whether it is trusted.
+//
+// @Summary Update device
+// @Description Renames a device or marks it as trusted, so risky logins from it are not asked for an emailed code
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param id path string true "Device ID"
+// @Param device body UpdateDeviceInput true "New name or trust"
+// @Security BearerAuth
+// @Success 200 {object} SuccessResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /user/devices/{id} [patch]
+func (u *UserHandler) UpdateDevice(c *gin.Context) {
+	var input UpdateDeviceInput
+
+	validatedReqBody, exists := c.Get("validatedRequestBody")
+
+	if !exists {
+		helpers.ReturnError(c, "Error parsing request", fmt.Errorf(helpers.INVALID_REQUEST_BODY), http.StatusBadRequest)
+		return
+	}
+
+	input, ok := validatedReqBody.(UpdateDeviceInput)
+	if !ok {
+		helpers.ReturnError(c, "Error parsing request", fmt.Errorf(helpers.REQUEST_BODY_PARSE_ERROR), http.StatusBadRequest)
+		return
+	}
+
+	device, ok := u.ownedDevice(c)
+	if !ok {
+		return
+	}
+
+	var columns []string
+	if input.Name != nil {
+		device.Name = *input.Name
+		columns = append(columns, "name")
+	}
+	if input.Trusted != nil {
+		device.Trusted = *input.Trusted
+		columns = append(columns, "trusted")
+	}
+
+	if err := u.deps.AgentRepo.UpdateColumns(device, columns...); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	helpers.ReturnJSON(c, "Device updated", device, http.StatusOK)
+}
+
+// ForgetDevice is a route handler that removes one of the authenticated user's devices.
+//
+// The device is signed out, and its next login is treated as coming from a
+// new device.
+//
+// @Summary Forget device
+// @Description Removes a known device and revokes its sessions
+// @Tags User
+// @Produce json
+// @Param id path string true "Device ID"
+// @Security BearerAuth
+// @Success 200 {object} SuccessResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /user/devices/{id} [delete]
+func (u *UserHandler) ForgetDevice(c *gin.Context) {
+	device, ok := u.ownedDevice(c)
+	if !ok {
+		return
+	}
+
+	sessions, err := u.deps.SessionRepo.FindActiveByUserID(device.UserID)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	for _, session := range sessions {
+		if session.DeviceID != device.ID {
+			continue
+		}
+
+		if err := revokeSession(u.deps, device.UserID, session.ID); err != nil {
+			helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := u.deps.AgentRepo.Delete(device.ID); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	helpers.ReturnJSON(c, "Device forgotten", nil, http.StatusOK)
+}
+
+// ownedDevice loads the device named in the path if it belongs to the
+// authenticated user.
+func (u *UserHandler) ownedDevice(c *gin.Context) (*models.UserAgent, bool) {
+	user, ok := u.authenticatedUser(c)
+	if !ok {
+		return nil, false
+	}
+
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		helpers.ReturnError(c, "Invalid device", err, http.StatusBadRequest)
+		return nil, false
+	}
+
+	device, found, err := u.deps.AgentRepo.FindByCondition("id = ?", id.String())
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return nil, false
+	}
+
+	if !found || device.UserID != user.ID {
+		helpers.ReturnError(c, "Device not found", errDeviceNotFound, http.StatusNotFound)
+		return nil, false
+	}
+
+	return device, true
+}
@@ -0,0 +1,369 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bjorndonald/golang-backend-template/internal/apitoken"
+	"github.com/bjorndonald/golang-backend-template/internal/helpers"
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+)
+
+var (
+	errSameEmail  = errors.New("this is already your email address")
+	errEmailTaken = errors.New("email address is already in use")
+)
+
+// ChangeEmailInput asks to move the account to a new address. The password
+// or a code confirms it is the account owner asking.
+type ChangeEmailInput struct {
+	Email string `json:"email" validate:"required,email"`
+	ReauthenticateInput
+}
+
+// RequestEmailChange is a route handler that starts moving the account to a new email address.
+//
+// A confirmation link is sent to the new address and a notice with an undo
+// link to the current one. Nothing changes until the confirmation link is
+// followed.
+//
+// @Summary Change email address
+// @Description Sends a confirmation link to the new address and an undo link to the current one
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param credentials body ChangeEmailInput true "New email and password or OTP"
+// @Security BearerAuth
+// @Success 202 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
+// @Router /user/email [post]
+func (u *UserHandler) RequestEmailChange(c *gin.Context) {
+	var input ChangeEmailInput
+
+	validatedReqBody, exists := c.Get("validatedRequestBody")
+
+	if !exists {
+		helpers.ReturnError(c, "Error parsing request", fmt.Errorf(helpers.INVALID_REQUEST_BODY), http.StatusBadRequest)
+		return
+	}
+
+	input, ok := validatedReqBody.(ChangeEmailInput)
+	if !ok {
+		helpers.ReturnError(c, "Error parsing request", fmt.Errorf(helpers.REQUEST_BODY_PARSE_ERROR), http.StatusBadRequest)
+		return
+	}
+
+	user, ok := u.authenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	if !confirmStepUp(c, u.deps, user, input.ReauthenticateInput) {
+		return
+	}
+
+	newEmail := strings.ToLower(input.Email)
+	if newEmail == user.Email {
+		helpers.ReturnError(c, "Email address not changed", errSameEmail, http.StatusBadRequest)
+		return
+	}
+
+	_, taken, err := u.deps.UserRepo.FindByCondition("email = ?", newEmail)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	if taken {
+		helpers.ReturnError(c, "Email address already in use", errEmailTaken, http.StatusConflict)
+		return
+	}
+
+	if err := u.deps.EmailChangeRepo.CancelPending(user.ID); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	change := &models.EmailChange{
+		ID:        id,
+		UserID:    user.ID,
+		OldEmail:  user.Email,
+		NewEmail:  newEmail,
+		ExpiresAt: time.Now().Add(helpers.EmailChangeTTL),
+		CreatedAt: time.Now(),
+	}
+
+	if err := u.deps.EmailChangeRepo.Create(change); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	confirmToken, err := helpers.GenerateEmailChangeToken(newEmail, user.FirstName, user.ID.String(), change.ID.String())
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	revertToken, err := helpers.GenerateEmailRevertToken(user.Email, user.FirstName, user.ID.String(), change.ID.String())
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	go u.deps.EmailService.SendEmailChangeConfirmationEmail(user.FirstName, newEmail, publicURL("/api/v1/auth/email/confirm?token="+confirmToken))
+	go u.deps.EmailService.SendEmailChangeNoticeEmail(user.FirstName, user.Email, newEmail, publicURL("/api/v1/auth/email/revert?token="+revertToken))
+
+	helpers.ReturnJSON(c, "Check your new email address for a confirmation link", nil, http.StatusAccepted)
+}
+
+// findEmailChange loads the change a confirm or undo link was issued for.
+func (a *AuthHandler) findEmailChange(claims *helpers.AuthTokenJwtClaim) (*models.EmailChange, bool, error) {
+	id, err := uuid.FromString(claims.Id)
+	if err != nil {
+		return nil, false, nil
+	}
+
+	change, found, err := a.deps.EmailChangeRepo.Find(id)
+	if err != nil || !found || change.UserID.String() != claims.UserId {
+		return nil, false, err
+	}
+
+	return change, true, nil
+}
+
+// ConfirmEmailChange is a route handler that swaps in the new email address from a confirmation link.
+//
+// Sessions are left alone: they are tied to the user ID, and the next
+// refresh picks up the new address.
+//
+// @Summary Confirm email change
+// @Description Makes the new address the account's email
+// @Tags Authentication
+// @Produce json
+// @Param token query string true "Email change token"
+// @Success 302 {string} string "Redirects to the client URL with the outcome"
+// @Failure 302 {string} string "Redirects to the client URL with an error code"
+// @Router /auth/email/confirm [get]
+func (a *AuthHandler) ConfirmEmailChange(c *gin.Context) {
+	redirect := func(query string) {
+		c.Redirect(http.StatusFound, fmt.Sprintf("%s/auth/email?%s", constant.ClientUrl, query))
+	}
+
+	claims, err := helpers.ValidateEmailChangeToken(c.Query("token"))
+	if err != nil {
+		redirect("error=email_change_invalid")
+		return
+	}
+
+	change, found, err := a.findEmailChange(claims)
+	if err != nil {
+		log.Println("error: ", err.Error())
+		redirect("error=500")
+		return
+	}
+
+	if !found || !change.Pending() {
+		redirect("error=email_change_expired")
+		return
+	}
+
+	user, found, err := a.deps.UserRepo.FindByCondition("id = ?", change.UserID.String())
+	if err != nil {
+		redirect("error=500")
+		return
+	}
+
+	// The address was changed some other way since the request was made.
+	if !found || user.Email != change.OldEmail {
+		redirect("error=email_change_expired")
+		return
+	}
+
+	_, taken, err := a.deps.UserRepo.FindByCondition("email = ?", change.NewEmail)
+	if err != nil {
+		redirect("error=500")
+		return
+	}
+
+	if taken {
+		redirect("error=email_taken")
+		return
+	}
+
+	confirmed, err := a.deps.EmailChangeRepo.MarkConfirmed(change.ID)
+	if err != nil {
+		redirect("error=500")
+		return
+	}
+
+	if !confirmed {
+		redirect("error=email_change_expired")
+		return
+	}
+
+	user.Email = change.NewEmail
+	user.EmailVerified = true
+	if err := a.deps.UserRepo.UpdateColumns(user, "email", "email_verified"); err != nil {
+		log.Println("error: ", err.Error())
+		redirect("error=500")
+		return
+	}
+
+	redirect("status=email_changed")
+}
+
+// emailChangeFromRevertLink loads the change the undo link in the query was
+// issued for. When it cannot be undone it redirects with the reason.
+func (a *AuthHandler) emailChangeFromRevertLink(c *gin.Context, redirect func(string)) (*models.EmailChange, bool) {
+	claims, err := helpers.ValidateEmailRevertToken(c.Query("token"))
+	if err != nil {
+		redirect("error=email_revert_invalid")
+		return nil, false
+	}
+
+	change, found, err := a.findEmailChange(claims)
+	if err != nil {
+		log.Println("error: ", err.Error())
+		redirect("error=500")
+		return nil, false
+	}
+
+	if !found || change.RevertedAt != nil {
+		redirect("error=email_revert_invalid")
+		return nil, false
+	}
+
+	return change, true
+}
+
+// ShowEmailRevert is a route handler that asks the user to confirm the undo link sent to their old address.
+//
+// Opening the link changes nothing, so a mail scanner fetching it cannot
+// undo the change.
+//
+// @Summary Show email change undo
+// @Description Shows the change and a button that undoes it
+// @Tags Authentication
+// @Produce html
+// @Param token query string true "Email revert token"
+// @Success 200 {string} string "The confirmation page"
+// @Failure 302 {string} string "Redirects to the client URL with an error code"
+// @Router /auth/email/revert [get]
+func (a *AuthHandler) ShowEmailRevert(c *gin.Context) {
+	redirect := func(query string) {
+		c.Redirect(http.StatusFound, fmt.Sprintf("%s/auth/email?%s", constant.ClientUrl, query))
+	}
+
+	change, ok := a.emailChangeFromRevertLink(c, redirect)
+	if !ok {
+		return
+	}
+
+	renderConfirmation(c, confirmation{
+		Title:  "Undo email change?",
+		Text:   fmt.Sprintf("Your account's email address is being changed from %s to %s. If you did not ask for this, undo it: every device will be signed out and a new password will be needed.", change.OldEmail, change.NewEmail),
+		Button: "Undo email change",
+	})
+}
+
+// RevertEmailChange is a route handler that cancels or undoes an email change from the link sent to the old address.
+//
+// Someone following this link did not make the change, so the account is
+// treated as compromised: every session and personal access token is
+// revoked and a new password is needed at the next login.
+//
+// @Summary Undo email change
+// @Description Restores the previous email address, signs out every device, revokes personal access tokens and forces a password reset
+// @Tags Authentication
+// @Produce json
+// @Param token query string true "Email revert token"
+// @Success 303 {string} string "Redirects to the client URL with the outcome"
+// @Failure 303 {string} string "Redirects to the client URL with an error code"
+// @Router /auth/email/revert [post]
+func (a *AuthHandler) RevertEmailChange(c *gin.Context) {
+	redirect := func(query string) {
+		c.Redirect(http.StatusSeeOther, fmt.Sprintf("%s/auth/email?%s", constant.ClientUrl, query))
+	}
+
+	change, ok := a.emailChangeFromRevertLink(c, redirect)
+	if !ok {
+		return
+	}
+
+	user, found, err := a.deps.UserRepo.FindByCondition("id = ?", change.UserID.String())
+	if err != nil {
+		redirect("error=500")
+		return
+	}
+
+	if !found {
+		redirect("error=email_revert_invalid")
+		return
+	}
+
+	reverted, err := a.deps.EmailChangeRepo.MarkReverted(change.ID)
+	if err != nil {
+		redirect("error=500")
+		return
+	}
+
+	if !reverted {
+		redirect("error=email_revert_invalid")
+		return
+	}
+
+	columns := []string{"auth_version"}
+	if change.ConfirmedAt != nil && user.Email == change.NewEmail {
+		// The old address could have been used to sign up again since.
+		other, taken, err := a.deps.UserRepo.FindByCondition("email = ?", change.OldEmail)
+		if err != nil {
+			redirect("error=500")
+			return
+		}
+
+		if taken && other.ID != user.ID {
+			redirect("error=email_taken")
+			return
+		}
+
+		user.Email = change.OldEmail
+		user.EmailVerified = true
+		columns = append(columns, "email", "email_verified")
+	}
+
+	user.AuthVersion = models.Outdated
+	if err := a.deps.UserRepo.UpdateColumns(user, columns...); err != nil {
+		log.Println("error: ", err.Error())
+		redirect("error=500")
+		return
+	}
+
+	if err := revokeAllSessions(a.deps, user.ID, uuid.Nil); err != nil {
+		log.Println("error: ", err.Error())
+		redirect("error=500")
+		return
+	}
+
+	if err := a.deps.APITokenRepo.RevokeAllForUser(user.ID, string(apitoken.Personal)); err != nil {
+		log.Println("error: ", err.Error())
+		redirect("error=500")
+		return
+	}
+
+	redirect("status=email_reverted")
+}
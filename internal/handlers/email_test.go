@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bjorndonald/golang-backend-template/internal/apitoken"
+	"github.com/bjorndonald/golang-backend-template/internal/helpers"
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+)
+
+// addEmailChange records a pending change of the test user's address.
+func addEmailChange(t *testing.T, a *testAuth) *models.EmailChange {
+	t.Helper()
+
+	change := &models.EmailChange{
+		ID:        newID(t),
+		UserID:    a.user.ID,
+		OldEmail:  a.user.Email,
+		NewEmail:  "ada@example.org",
+		ExpiresAt: time.Now().Add(helpers.EmailChangeTTL),
+		CreatedAt: time.Now(),
+	}
+	a.emailChanges.changes[change.ID] = change
+	return change
+}
+
+func TestConfirmEmailChangeLinkIsSingleUse(t *testing.T) {
+	a := newTestAuth(t)
+	a.router.GET("/email/confirm", a.handler.ConfirmEmailChange)
+
+	change := addEmailChange(t, a)
+	token, err := helpers.GenerateEmailChangeToken(change.NewEmail, a.user.FirstName, a.user.ID.String(), change.ID.String())
+	if err != nil {
+		t.Fatalf("email change token: %v", err)
+	}
+
+	recorder := a.do(http.MethodGet, "/email/confirm?token="+token)
+	expectRedirect(t, recorder.Code, recorder.Header().Get("Location"), http.StatusFound, "status=email_changed")
+
+	if email := a.users.users[a.user.ID].Email; email != change.NewEmail {
+		t.Fatalf("expected the address to be %s, got %s", change.NewEmail, email)
+	}
+
+	recorder = a.do(http.MethodGet, "/email/confirm?token="+token)
+	expectRedirect(t, recorder.Code, recorder.Header().Get("Location"), http.StatusFound, "error=email_change_expired")
+}
+
+func TestRevertEmailChangeLinkIsSingleUse(t *testing.T) {
+	a := newTestAuth(t)
+	a.router.GET("/email/revert", a.handler.ShowEmailRevert)
+	a.router.POST("/email/revert", a.handler.RevertEmailChange)
+
+	change := addEmailChange(t, a)
+	token, err := helpers.GenerateEmailRevertToken(change.OldEmail, a.user.FirstName, a.user.ID.String(), change.ID.String())
+	if err != nil {
+		t.Fatalf("email revert token: %v", err)
+	}
+
+	// Opening the link, as a mail scanner would, only shows the page.
+	recorder := a.do(http.MethodGet, "/email/revert?token="+token)
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), "<form method=\"post\">") {
+		t.Fatalf("expected the confirmation page, got %d", recorder.Code)
+	}
+	if change.RevertedAt != nil || a.users.users[a.user.ID].AuthVersion == models.Outdated {
+		t.Fatalf("expected opening the link to change nothing")
+	}
+
+	recorder = a.do(http.MethodPost, "/email/revert?token="+token)
+	expectRedirect(t, recorder.Code, recorder.Header().Get("Location"), http.StatusSeeOther, "status=email_reverted")
+
+	if a.users.users[a.user.ID].AuthVersion != models.Outdated {
+		t.Errorf("expected a password reset to be forced")
+	}
+	if kind := a.apiTokens.revokedFor[a.user.ID]; kind != string(apitoken.Personal) {
+		t.Errorf("expected personal access tokens to be revoked, got %q", kind)
+	}
+
+	recorder = a.do(http.MethodPost, "/email/revert?token="+token)
+	expectRedirect(t, recorder.Code, recorder.Header().Get("Location"), http.StatusSeeOther, "error=email_revert_invalid")
+}
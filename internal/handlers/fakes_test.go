@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bjorndonald/golang-backend-template/internal/bootstrap"
+	"github.com/bjorndonald/golang-backend-template/internal/helpers"
+	"github.com/bjorndonald/golang-backend-template/internal/keys"
+	"github.com/bjorndonald/golang-backend-template/internal/manager"
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+	"github.com/bjorndonald/golang-backend-template/internal/otp"
+	"github.com/bjorndonald/golang-backend-template/internal/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+)
+
+// The fakes below keep records in memory and implement only what the
+// handler tests reach. The embedded interface panics on anything else, so a
+// handler that starts using another method fails loudly rather than
+// quietly getting a zero value.
+
+type fakeUsers struct {
+	repository.UserRepositoryInterface
+	users map[uuid.UUID]models.User
+}
+
+func (f *fakeUsers) FindByCondition(condition, value string) (*models.User, bool, error) {
+	for _, user := range f.users {
+		if (condition == "id = ?" && user.ID.String() == value) || (condition == "email = ?" && user.Email == value) {
+			return &user, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+func (f *fakeUsers) UpdateColumns(user *models.User, columns ...string) error {
+	f.users[user.ID] = *user
+	return nil
+}
+
+type fakeRefreshTokens struct {
+	repository.RefreshTokenRepositoryInterface
+	tokens map[uuid.UUID]*models.RefreshToken
+}
+
+func (f *fakeRefreshTokens) Create(token *models.RefreshToken) error {
+	f.tokens[token.ID] = token
+	return nil
+}
+
+func (f *fakeRefreshTokens) FindByHash(hash string) (*models.RefreshToken, bool, error) {
+	for _, token := range f.tokens {
+		if token.TokenHash == hash {
+			found := *token
+			return &found, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+func (f *fakeRefreshTokens) MarkUsed(id uuid.UUID) (bool, error) {
+	token, ok := f.tokens[id]
+	if !ok || token.UsedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	token.UsedAt = &now
+	return true, nil
+}
+
+func (f *fakeRefreshTokens) RevokeFamily(familyID uuid.UUID) error {
+	now := time.Now()
+	for _, token := range f.tokens {
+		if token.FamilyID == familyID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (f *fakeRefreshTokens) RevokeAllForUser(userID, keepFamily uuid.UUID) error {
+	now := time.Now()
+	for _, token := range f.tokens {
+		if token.UserID == userID && token.FamilyID != keepFamily && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+type fakeSessions struct {
+	repository.SessionRepositoryInterface
+	revoked map[uuid.UUID]bool
+}
+
+func (f *fakeSessions) Extend(id uuid.UUID, expiresAt time.Time) error {
+	return nil
+}
+
+func (f *fakeSessions) Revoke(id uuid.UUID) error {
+	f.revoked[id] = true
+	return nil
+}
+
+func (f *fakeSessions) FindActiveByUserID(userID uuid.UUID) ([]*models.Session, error) {
+	return nil, nil
+}
+
+func (f *fakeSessions) RevokeAllForUser(userID, keep uuid.UUID) error {
+	return nil
+}
+
+type fakeEmailChanges struct {
+	repository.EmailChangeRepositoryInterface
+	changes map[uuid.UUID]*models.EmailChange
+}
+
+func (f *fakeEmailChanges) Find(id uuid.UUID) (*models.EmailChange, bool, error) {
+	change, ok := f.changes[id]
+	if !ok {
+		return nil, false, nil
+	}
+	found := *change
+	return &found, true, nil
+}
+
+func (f *fakeEmailChanges) MarkConfirmed(id uuid.UUID) (bool, error) {
+	change, ok := f.changes[id]
+	if !ok || change.ConfirmedAt != nil || change.RevertedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	change.ConfirmedAt = &now
+	return true, nil
+}
+
+func (f *fakeEmailChanges) MarkReverted(id uuid.UUID) (bool, error) {
+	change, ok := f.changes[id]
+	if !ok || change.RevertedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	change.RevertedAt = &now
+	return true, nil
+}
+
+type fakeDeviceApprovals struct {
+	repository.DeviceApprovalRepositoryInterface
+	approvals map[uuid.UUID]*models.DeviceApproval
+}
+
+func (f *fakeDeviceApprovals) Find(id uuid.UUID) (*models.DeviceApproval, bool, error) {
+	approval, ok := f.approvals[id]
+	if !ok {
+		return nil, false, nil
+	}
+	found := *approval
+	return &found, true, nil
+}
+
+func (f *fakeDeviceApprovals) MarkApproved(id uuid.UUID) (bool, error) {
+	approval, ok := f.approvals[id]
+	if !ok || approval.ApprovedAt != nil || time.Now().After(approval.ExpiresAt) {
+		return false, nil
+	}
+	now := time.Now()
+	approval.ApprovedAt = &now
+	return true, nil
+}
+
+type fakeAPITokens struct {
+	repository.APITokenRepositoryInterface
+	revokedFor map[uuid.UUID]string
+}
+
+func (f *fakeAPITokens) RevokeAllForUser(userID uuid.UUID, kind string) error {
+	f.revokedFor[userID] = kind
+	return nil
+}
+
+// testAuth is an AuthHandler over in-memory repositories, with one active
+// user to act on.
+type testAuth struct {
+	handler         *AuthHandler
+	user            models.User
+	users           *fakeUsers
+	refreshTokens   *fakeRefreshTokens
+	sessions        *fakeSessions
+	emailChanges    *fakeEmailChanges
+	deviceApprovals *fakeDeviceApprovals
+	apiTokens       *fakeAPITokens
+	router          *gin.Engine
+}
+
+func newTestAuth(t *testing.T) *testAuth {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	signingKeys := keys.NewManager()
+	signingKeys.Add(keys.NewHMACKey("test", []byte("test-secret")))
+	if err := signingKeys.SetSigningKey("test"); err != nil {
+		t.Fatalf("signing key: %v", err)
+	}
+	helpers.SetKeyManager(signingKeys)
+
+	user := models.User{
+		ID:            newID(t),
+		Email:         "ada@example.com",
+		FirstName:     "Ada",
+		EmailVerified: true,
+		Status:        models.ActiveAccount,
+		AuthVersion:   models.UpToDate,
+	}
+
+	a := &testAuth{
+		user:            user,
+		users:           &fakeUsers{users: map[uuid.UUID]models.User{user.ID: user}},
+		refreshTokens:   &fakeRefreshTokens{tokens: map[uuid.UUID]*models.RefreshToken{}},
+		sessions:        &fakeSessions{revoked: map[uuid.UUID]bool{}},
+		emailChanges:    &fakeEmailChanges{changes: map[uuid.UUID]*models.EmailChange{}},
+		deviceApprovals: &fakeDeviceApprovals{approvals: map[uuid.UUID]*models.DeviceApproval{}},
+		apiTokens:       &fakeAPITokens{revokedFor: map[uuid.UUID]string{}},
+		router:          gin.New(),
+	}
+
+	a.handler = &AuthHandler{deps: &bootstrap.AppDependencies{
+		UserRepo:           a.users,
+		RefreshTokenRepo:   a.refreshTokens,
+		SessionRepo:        a.sessions,
+		EmailChangeRepo:    a.emailChanges,
+		DeviceApprovalRepo: a.deviceApprovals,
+		APITokenRepo:       a.apiTokens,
+		OTPManager:         otp.NewOTPManager(otp.NewMemoryStore()),
+		StreamManager:      manager.NewGameManager(),
+	}}
+
+	return a
+}
+
+// do sends a request through the test router with the given cookies.
+func (a *testAuth) do(method, target string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, target, nil)
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+
+	recorder := httptest.NewRecorder()
+	a.router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// responseCookie returns the value of the named cookie the response set.
+func responseCookie(recorder *httptest.ResponseRecorder, name string) string {
+	for _, cookie := range recorder.Result().Cookies() {
+		if cookie.Name == name {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+// expectRedirect fails the test unless the response redirected with the
+// given status to a location containing query.
+func expectRedirect(t *testing.T, code int, location string, status int, query string) {
+	t.Helper()
+	if code != status || !strings.Contains(location, query) {
+		t.Fatalf("expected a %d redirect with %s, got %d %q", status, query, code, location)
+	}
+}
+
+func newID(t *testing.T) uuid.UUID {
+	t.Helper()
+	id, err := uuid.NewV7()
+	if err != nil {
+		t.Fatalf("new id: %v", err)
+	}
+	return id
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bjorndonald/golang-backend-template/internal/bootstrap"
+	"github.com/bjorndonald/golang-backend-template/internal/helpers"
+	"github.com/bjorndonald/golang-backend-template/internal/lockout"
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	errTooManyAttempts = errors.New("too many failed attempts")
+	errAccountLocked   = errors.New("account locked")
+)
+
+// setRetryAfter tells the client how many whole seconds to wait.
+func setRetryAfter(c *gin.Context, wait time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+}
+
+// ipBlocked writes a 429 and reports true while the client address is
+// blocked for failing too often.
+func ipBlocked(c *gin.Context, deps *bootstrap.AppDependencies) bool {
+	return throttled(c, deps, lockout.IPKey(c.ClientIP()))
+}
+
+// stepUpBlocked writes a 429 and reports true while the user is blocked from
+// confirming sensitive actions for failing too often.
+func stepUpBlocked(c *gin.Context, deps *bootstrap.AppDependencies, user *models.User) bool {
+	return throttled(c, deps, lockout.StepUpKey(user.ID.String()))
+}
+
+// throttled writes a 429 and reports true while the counter under key is
+// blocked.
+func throttled(c *gin.Context, deps *bootstrap.AppDependencies, key string) bool {
+	throttle, found, err := deps.ThrottleRepo.Find(key)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return true
+	}
+
+	if !found || throttle.BlockedUntil == nil {
+		return false
+	}
+
+	wait := time.Until(*throttle.BlockedUntil)
+	if wait <= 0 {
+		return false
+	}
+
+	setRetryAfter(c, wait)
+	helpers.ReturnError(c, "Too many failed attempts, try again later", errTooManyAttempts, http.StatusTooManyRequests)
+	return true
+}
+
+// accountLocked writes a 429 and reports true while the account is locked.
+func accountLocked(c *gin.Context, user *models.User) bool {
+	wait := user.LockRemaining()
+	if wait <= 0 {
+		return false
+	}
+
+	setRetryAfter(c, wait)
+	helpers.ReturnError(c, "Account is temporarily locked, try again later", errAccountLocked, http.StatusTooManyRequests)
+	return true
+}
+
+// recordLoginFailure counts a failed attempt against the client address and,
+// when user is not nil, against the account. Crossing a threshold blocks the
+// address or locks the account; the owner is emailed the first time the
+// account locks, and every lock goes in the login history. Failures to
+// record are logged so that the caller can still answer the request.
+func recordLoginFailure(c *gin.Context, deps *bootstrap.AppDependencies, user *models.User) {
+	ip := c.ClientIP()
+
+	throttle, err := deps.ThrottleRepo.RecordFailure(lockout.IPKey(ip), lockout.IPPolicy.Window)
+	if err != nil {
+		log.Printf("could not record failed login from %s: %v", ip, err)
+	} else if delay := lockout.IPPolicy.Delay(throttle.Failures); delay > 0 {
+		if err := deps.ThrottleRepo.Block(throttle.Key, time.Now().Add(delay)); err != nil {
+			log.Printf("could not block %s: %v", ip, err)
+		}
+	}
+
+	if user == nil {
+		return
+	}
+
+	throttle, err = deps.ThrottleRepo.RecordFailure(lockout.AccountKey(user.ID.String()), lockout.AccountPolicy.Window)
+	if err != nil {
+		log.Printf("could not record failed login for %s: %v", user.ID, err)
+		return
+	}
+
+	delay := lockout.AccountPolicy.Delay(throttle.Failures)
+	if delay == 0 {
+		return
+	}
+
+	until := time.Now().Add(delay)
+	user.LockedUntil = &until
+	columns := []string{"LockedUntil"}
+	if user.Status == models.ActiveAccount {
+		user.Status = models.LockedAccount
+		columns = append(columns, "Status")
+	}
+
+	if err := deps.UserRepo.UpdateColumns(user, columns...); err != nil {
+		log.Printf("could not lock account %s: %v", user.ID, err)
+		return
+	}
+	recordLoginEvent(c, deps, user, models.LoginEvent{Type: models.LoginLockedOut, Reason: fmt.Sprintf("%d failed attempts", throttle.Failures)})
+
+	if throttle.Failures == lockout.AccountPolicy.Threshold {
+		go deps.EmailService.SendAccountLockedEmail(user.FirstName, user.Email, ip, until)
+	}
+}
+
+// recordStepUpFailure counts a wrong password or code entered to confirm a
+// sensitive action, blocking further attempts once past the threshold.
+// Failures to record are logged.
+func recordStepUpFailure(deps *bootstrap.AppDependencies, user *models.User) {
+	throttle, err := deps.ThrottleRepo.RecordFailure(lockout.StepUpKey(user.ID.String()), lockout.StepUpPolicy.Window)
+	if err != nil {
+		log.Printf("could not record failed confirmation for %s: %v", user.ID, err)
+		return
+	}
+
+	if delay := lockout.StepUpPolicy.Delay(throttle.Failures); delay > 0 {
+		if err := deps.ThrottleRepo.Block(throttle.Key, time.Now().Add(delay)); err != nil {
+			log.Printf("could not block confirmations for %s: %v", user.ID, err)
+		}
+	}
+}
+
+// resetStepUpFailures clears the user's failed confirmations after a
+// successful one.
+func resetStepUpFailures(deps *bootstrap.AppDependencies, user *models.User) {
+	if err := deps.ThrottleRepo.Reset(lockout.StepUpKey(user.ID.String())); err != nil {
+		log.Printf("could not reset failed confirmations for %s: %v", user.ID, err)
+	}
+}
+
+// unlockAccount clears the failed attempts of the account and lifts its lock.
+func unlockAccount(deps *bootstrap.AppDependencies, user *models.User) error {
+	if err := deps.ThrottleRepo.Reset(lockout.AccountKey(user.ID.String())); err != nil {
+		return err
+	}
+
+	if user.LockedUntil == nil && user.Status != models.LockedAccount {
+		return nil
+	}
+
+	user.LockedUntil = nil
+	columns := []string{"LockedUntil"}
+	if user.Status == models.LockedAccount {
+		user.Status = models.ActiveAccount
+		columns = append(columns, "Status")
+	}
+
+	return deps.UserRepo.UpdateColumns(user, columns...)
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bjorndonald/golang-backend-template/internal/bootstrap"
+	"github.com/bjorndonald/golang-backend-template/internal/helpers"
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+	"github.com/bjorndonald/golang-backend-template/internal/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+)
+
+// Ways of logging in, as recorded on login events.
+const (
+	passwordLogin          = "password"
+	magicLinkLogin         = "magic_link"
+	passkeyLogin           = "passkey"
+	emailVerificationLogin = "email_verification"
+	oauthLoginPrefix       = "oauth:"
+)
+
+// LoginActivity is one page of login events. NextCursor is passed back as
+// the cursor query parameter for the next page and is empty on the last one.
+type LoginActivity struct {
+	Events     []*models.LoginEvent `json:"events"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+}
+
+// recordLoginEvent writes a login event for the request. user is nil when
+// no account matched, in which case event.Email should hold the address
+// that was tried. A successful login also becomes the user's last login.
+// Like recordAudit, failures are logged rather than reported.
+func recordLoginEvent(c *gin.Context, deps *bootstrap.AppDependencies, user *models.User, event models.LoginEvent) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		log.Printf("login event %s: %v", event.Type, err)
+		return
+	}
+
+	event.ID = id
+	if user != nil {
+		event.UserID = user.ID
+		event.Email = user.Email
+	}
+	event.IP = c.ClientIP()
+	event.UserAgent = c.Request.UserAgent()
+	event.CreatedAt = time.Now()
+
+	if location, err := deps.GeoResolver.Resolve(event.IP); err == nil {
+		event.City = location.City
+		event.Region = location.Region
+		event.Country = location.Country
+	}
+
+	if err := deps.LoginEventRepo.Create(&event); err != nil {
+		log.Printf("login event %s for %s: %v", event.Type, event.Email, err)
+		return
+	}
+
+	if user != nil && event.Type == models.LoginSucceeded {
+		user.LastLogin = &event.CreatedAt
+		if err := deps.UserRepo.UpdateColumns(user, "LastLogin"); err != nil {
+			log.Printf("could not update last login of %s: %v", user.ID, err)
+		}
+	}
+}
+
+// listLoginActivity writes the page of events matching filter that the
+// cursor and limit query parameters ask for.
+func listLoginActivity(c *gin.Context, deps *bootstrap.AppDependencies, filter repository.LoginEventFilter) {
+	if cursor := c.Query("cursor"); cursor != "" {
+		before, err := uuid.FromString(cursor)
+		if err != nil {
+			helpers.ReturnError(c, "Invalid cursor", err, http.StatusBadRequest)
+			return
+		}
+		filter.Before = before
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit < 1 {
+		limit = defaultPerPage
+	}
+	if limit > maxPerPage {
+		limit = maxPerPage
+	}
+
+	// One more than asked for tells whether there is another page.
+	filter.Limit = limit + 1
+	events, err := deps.LoginEventRepo.List(filter)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	activity := LoginActivity{Events: events}
+	if len(events) > limit {
+		activity.Events = events[:limit]
+		activity.NextCursor = events[limit-1].ID.String()
+	}
+	if activity.Events == nil {
+		activity.Events = []*models.LoginEvent{}
+	}
+
+	helpers.ReturnJSON(c, "Security activity retrieved", activity, http.StatusOK)
+}
+
+// SecurityActivity is a route handler that lists the authenticated user's login history.
+//
+// @Summary Security activity
+// @Description Lists logins, failed attempts, challenges, lockouts, new devices and password resets on the account, newest first
+// @Tags User
+// @Produce json
+// @Param type query string false "Only events of this type, for example login.failure"
+// @Param cursor query string false "next_cursor from the previous page"
+// @Param limit query int false "Events per page, at most 100"
+// @Security BearerAuth
+// @Success 200 {object} LoginActivity
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /user/security/activity [get]
+func (u *UserHandler) SecurityActivity(c *gin.Context) {
+	user, ok := u.authenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	listLoginActivity(c, u.deps, repository.LoginEventFilter{
+		UserID: user.ID.String(),
+		Type:   c.Query("type"),
+	})
+}
+
+// ListLoginEvents is a route handler that lists login events across all accounts.
+//
+// @Summary List login events
+// @Description Lists login events, newest first, including attempts on addresses with no account
+// @Tags Admin
+// @Produce json
+// @Param user_id query string false "Only events of this user"
+// @Param email query string false "Only events for this email address"
+// @Param type query string false "Only events of this type, for example login.lockout"
+// @Param ip query string false "Only events from this address"
+// @Param cursor query string false "next_cursor from the previous page"
+// @Param limit query int false "Events per page, at most 100"
+// @Security BearerAuth
+// @Success 200 {object} LoginActivity
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /admin/login-events [get]
+func (a *AdminHandler) ListLoginEvents(c *gin.Context) {
+	userID := c.Query("user_id")
+	if userID != "" {
+		if _, err := uuid.FromString(userID); err != nil {
+			helpers.ReturnError(c, "Invalid user ID", err, http.StatusBadRequest)
+			return
+		}
+	}
+
+	listLoginActivity(c, a.deps, repository.LoginEventFilter{
+		UserID: userID,
+		Email:  strings.ToLower(c.Query("email")),
+		Type:   c.Query("type"),
+		IP:     c.Query("ip"),
+	})
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/bjorndonald/golang-backend-template/internal/helpers"
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+	"github.com/bjorndonald/golang-backend-template/internal/otp"
+	"github.com/bjorndonald/golang-backend-template/internal/risk"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	magicLinkCookie      = "magicLinkNonce"
+	magicLinkCookiePath  = "/api/v1/auth/magic-link"
+	magicLinkNonceLength = 32
+)
+
+// magicLinkSent is returned whether or not the email belongs to an account,
+// so the endpoint cannot be used to find out who is registered.
+const magicLinkSent = "If an account exists for this email, a sign-in link has been sent"
+
+// magicLinkNonce returns the browser's magic link nonce, creating one if it
+// does not have one yet. Reusing it keeps the latest link working when the
+// browser asks again during the resend cooldown; once the cooldown has
+// passed, a new link replaces the earlier ones.
+func magicLinkNonce(c *gin.Context) (string, error) {
+	nonce, err := c.Cookie(magicLinkCookie)
+	if err != nil || len(nonce) != magicLinkNonceLength {
+		nonce, err = helpers.GenerateRandomString(magicLinkNonceLength)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	c.SetCookie(magicLinkCookie, nonce, int(helpers.MagicLinkTTL.Seconds()), magicLinkCookiePath, "", true, true)
+	return nonce, nil
+}
+
+// RequestMagicLink is a route handler that emails the user a link to sign in without a password.
+//
+// The link only works in the browser that asked for it and can be used once.
+//
+// @Summary Request a magic link
+// @Description Emails a single use sign-in link. The response is the same whether or not the account exists.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param credentials body EmailInput true "Input (email)"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
+// @Router /auth/magic-link [post]
+func (a *AuthHandler) RequestMagicLink(c *gin.Context) {
+	var input EmailInput
+	validatedReqBody, exists := c.Get("validatedRequestBody")
+
+	if !exists {
+		helpers.ReturnError(c, "Something went wrong", fmt.Errorf(helpers.INVALID_REQUEST_BODY), http.StatusBadRequest)
+		return
+	}
+
+	input, ok := validatedReqBody.(EmailInput)
+	if !ok {
+		helpers.ReturnError(c, "Something went wrong", fmt.Errorf(helpers.REQUEST_BODY_PARSE_ERROR), http.StatusBadRequest)
+		return
+	}
+
+	if ipBlocked(c, a.deps) {
+		return
+	}
+
+	nonce, err := magicLinkNonce(c)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	user, found, err := a.deps.UserRepo.FindByCondition("email = ?", strings.ToLower(input.Email))
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	// Issuing the code writes to the database and signs a token, so it is
+	// done in the background. Otherwise only requests for real accounts
+	// would take that long, and the timing would tell who is registered.
+	if found && user.EmailVerified && user.Status != models.SuspendedAccount {
+		go a.sendMagicLink(user, helpers.HashToken(nonce))
+	}
+
+	helpers.ReturnJSON(c, magicLinkSent, nil, http.StatusOK)
+}
+
+// sendMagicLink issues a magic link code and emails the link to the user.
+// It runs after the request has been answered, so failures are logged. A
+// link sent during the cooldown is still valid, so none is sent then.
+func (a *AuthHandler) sendMagicLink(user *models.User, nonceHash string) {
+	code, err := a.deps.OTPManager.GenerateOTP(user.ID.String(), otp.MagicLinkOTP)
+	if errors.Is(err, otp.ErrResendCooldown) {
+		return
+	}
+	if err != nil {
+		log.Printf("could not issue magic link to %s: %v", user.ID, err)
+		return
+	}
+
+	token, err := helpers.GenerateMagicLinkToken(user.ID.String(), nonceHash, code)
+	if err != nil {
+		log.Printf("could not issue magic link to %s: %v", user.ID, err)
+		return
+	}
+
+	link := publicURL(fmt.Sprintf("%s/callback?token=%s", magicLinkCookiePath, token))
+	a.deps.EmailService.SendMagicLinkEmail(user.FirstName, user.Email, link)
+}
+
+// MagicLinkCallback is a route handler that signs the user in from an emailed magic link.
+//
+// @Summary Sign in with a magic link
+// @Description Checks the link against the browser that requested it and signs the user in, unless the account is locked or a risky device has not been approved
+// @Tags Authentication
+// @Produce json
+// @Param token query string true "Magic link token"
+// @Success 302 {string} string "Redirects to the client URL with a jwt token"
+// @Failure 302 {string} string "Redirects to the client URL with an error code"
+// @Router /auth/magic-link/callback [get]
+func (a *AuthHandler) MagicLinkCallback(c *gin.Context) {
+	clientUrl := constant.ClientUrl
+	redirectError := func(code string) {
+		c.Redirect(http.StatusFound, fmt.Sprintf("%s/auth/signin?error=%s", clientUrl, code))
+	}
+
+	claims, err := helpers.ValidateMagicLinkToken(c.Query("token"))
+	if err != nil {
+		redirectError("magic_link_invalid")
+		return
+	}
+
+	nonce, err := c.Cookie(magicLinkCookie)
+	c.SetCookie(magicLinkCookie, "", -1, magicLinkCookiePath, "", true, true)
+	if err != nil || subtle.ConstantTimeCompare([]byte(helpers.HashToken(nonce)), []byte(claims.Nonce)) != 1 {
+		redirectError("magic_link_device")
+		return
+	}
+
+	outcome, err := a.deps.OTPManager.VerifyOTP(claims.UserId, otp.MagicLinkOTP, claims.Code)
+	if err != nil {
+		log.Println("error: ", err.Error())
+		redirectError("500")
+		return
+	}
+	if outcome != otp.OutcomeOK {
+		redirectError("magic_link_expired")
+		return
+	}
+
+	user, found, err := a.deps.UserRepo.FindByCondition("id = ?", claims.UserId)
+	if err != nil {
+		redirectError("500")
+		return
+	}
+	if !found || user.Status == models.SuspendedAccount {
+		redirectError("magic_link_invalid")
+		return
+	}
+
+	if user.LockRemaining() > 0 {
+		recordLoginEvent(c, a.deps, user, models.LoginEvent{Type: models.LoginFailed, Method: magicLinkLogin, Reason: "account_locked"})
+		redirectError("account_locked")
+		return
+	}
+
+	assessment, fingerprint, location, err := assessRequestLogin(c, a.deps, user)
+	if err != nil {
+		log.Println("error: ", err.Error())
+		redirectError("500")
+		return
+	}
+
+	if assessment.Decision == risk.Approve {
+		approved, err := deviceApproved(c, a.deps, user, fingerprint, location, assessment)
+		if err != nil {
+			log.Println("error: ", err.Error())
+			redirectError("500")
+			return
+		}
+
+		if !approved {
+			recordLoginEvent(c, a.deps, user, models.LoginEvent{Type: models.LoginChallenged, Method: "device_approval", Reason: strings.Join(assessment.Signals, ",")})
+			redirectError("device_approval")
+			return
+		}
+	}
+
+	// The link proves access to the inbox, which is all an email code would
+	// add, so a risky login needs nothing more and only an authenticator app
+	// is still asked for.
+	if user.TOTPEnabled {
+		accessToken, err := helpers.GenerateTwoFactorToken(user.Email, user.FirstName, user.ID.String())
+		if err != nil {
+			redirectError("500")
+			return
+		}
+
+		recordLoginEvent(c, a.deps, user, models.LoginEvent{Type: models.LoginChallenged, Method: string(models.TOTPMethod)})
+		c.Redirect(http.StatusFound, fmt.Sprintf("%s/auth/2fa?token=%s&methods=%s", clientUrl, accessToken, models.TOTPMethod))
+		return
+	}
+
+	accessToken, err := issueTokenPair(c, a.deps, user.Email, user.FirstName, user.ID.String(), nil)
+	if errors.Is(err, errPasswordResetRequired) {
+		redirectError("password_reset_required")
+		return
+	}
+	if err != nil {
+		redirectError("500")
+		return
+	}
+	recordLoginEvent(c, a.deps, user, models.LoginEvent{Type: models.LoginSucceeded, Method: magicLinkLogin})
+
+	c.Redirect(http.StatusFound, fmt.Sprintf("%s/auth/signin?access_token=%s", clientUrl, accessToken))
+}
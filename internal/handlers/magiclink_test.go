@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/bjorndonald/golang-backend-template/internal/helpers"
+	"github.com/bjorndonald/golang-backend-template/internal/otp"
+)
+
+func TestMagicLinkCallbackChecksNonce(t *testing.T) {
+	a := newTestAuth(t)
+	a.router.GET("/callback", a.handler.MagicLinkCallback)
+
+	userID := a.user.ID.String()
+	code, err := a.handler.deps.OTPManager.GenerateOTP(userID, otp.MagicLinkOTP)
+	if err != nil {
+		t.Fatalf("generate code: %v", err)
+	}
+
+	nonce, err := helpers.GenerateRandomString(magicLinkNonceLength)
+	if err != nil {
+		t.Fatalf("nonce: %v", err)
+	}
+	token, err := helpers.GenerateMagicLinkToken(userID, helpers.HashToken(nonce), code)
+	if err != nil {
+		t.Fatalf("magic link token: %v", err)
+	}
+
+	otherNonce, err := helpers.GenerateRandomString(magicLinkNonceLength)
+	if err != nil {
+		t.Fatalf("nonce: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		cookies []*http.Cookie
+	}{
+		{name: "no cookie"},
+		{name: "another browser", cookies: []*http.Cookie{{Name: magicLinkCookie, Value: otherNonce}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := a.do(http.MethodGet, "/callback?token="+token, test.cookies...)
+
+			location := recorder.Header().Get("Location")
+			if recorder.Code != http.StatusFound || !strings.Contains(location, "error=magic_link_device") {
+				t.Fatalf("expected a redirect with magic_link_device, got %d %q", recorder.Code, location)
+			}
+			if responseCookie(recorder, refreshTokenCookie) != "" {
+				t.Errorf("expected no session to be started")
+			}
+		})
+	}
+
+	// Refused links are turned away before the code is checked, so another
+	// browser cannot burn the link for the one that asked for it.
+	if outcome, _ := a.handler.deps.OTPManager.VerifyOTP(userID, otp.MagicLinkOTP, code); outcome != otp.OutcomeOK {
+		t.Errorf("expected the code to still be usable, got %s", outcome)
+	}
+}
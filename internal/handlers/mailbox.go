@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+
+	"github.com/bjorndonald/golang-backend-template/internal/bootstrap"
+	"github.com/bjorndonald/golang-backend-template/internal/helpers"
+	"github.com/bjorndonald/golang-backend-template/internal/mail"
+	"github.com/gin-gonic/gin"
+)
+
+var errMessageNotFound = errors.New("message not found")
+
+var mailboxPage = template.Must(template.New("mailbox").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Mailbox</title></head>
+<body>
+<h1>Mailbox</h1>
+{{if .}}<table>
+<tr><th>Sent</th><th>To</th><th>Subject</th><th></th></tr>
+{{range .}}<tr>
+<td>{{.SentAt.Format "2006-01-02 15:04:05"}}</td>
+<td>{{range $i, $to := .To}}{{if $i}}, {{end}}{{$to}}{{end}}</td>
+<td><a href="/dev/mailbox/{{.ID}}">{{.Subject}}</a></td>
+<td><a href="/dev/mailbox/{{.ID}}/raw">.eml</a></td>
+</tr>
+{{end}}</table>{{else}}<p>No email has been sent.</p>{{end}}
+</body>
+</html>
+`))
+
+// MailboxHandler shows the email kept by the development mail sink.
+type MailboxHandler struct {
+	deps *bootstrap.AppDependencies
+}
+
+func NewMailboxHandler(deps *bootstrap.AppDependencies) *MailboxHandler {
+	return &MailboxHandler{deps: deps}
+}
+
+// ListMail is a route handler that lists the email the development sink has kept.
+//
+// Browsers get a page linking to each message; clients asking for JSON get
+// the messages themselves.
+//
+// @Summary Development mailbox
+// @Description Lists email kept by the sink transport, newest first. Only available outside production when MAIL_SINK_VIEWER is set.
+// @Tags Development
+// @Produce html,json
+// @Success 200 {array} mail.SentMessage
+// @Router /dev/mailbox [get]
+func (m *MailboxHandler) ListMail(c *gin.Context) {
+	messages := m.deps.Mailbox.Messages()
+
+	if c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON) == gin.MIMEJSON {
+		helpers.ReturnJSON(c, "Mailbox retrieved", messages, http.StatusOK)
+		return
+	}
+
+	var page bytes.Buffer
+	if err := mailboxPage.Execute(&page, messages); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	c.Data(http.StatusOK, "text/html; charset=utf-8", page.Bytes())
+}
+
+// ShowMail is a route handler that shows one message from the development mailbox as it would render in an inbox.
+//
+// @Summary Show email
+// @Description Shows the HTML body of a message kept by the sink transport
+// @Tags Development
+// @Produce html
+// @Param id path string true "Message ID"
+// @Success 200 {string} string "The message body"
+// @Failure 404 {object} ErrorResponse
+// @Router /dev/mailbox/{id} [get]
+func (m *MailboxHandler) ShowMail(c *gin.Context) {
+	message, ok := m.message(c)
+	if !ok {
+		return
+	}
+
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(message.HTML))
+}
+
+// DownloadMail is a route handler that downloads one message from the development mailbox as an .eml file.
+//
+// @Summary Download email
+// @Description Downloads a message kept by the sink transport as it would go over the wire
+// @Tags Development
+// @Produce octet-stream
+// @Param id path string true "Message ID"
+// @Success 200 {file} file "The message as an .eml file"
+// @Failure 404 {object} ErrorResponse
+// @Router /dev/mailbox/{id}/raw [get]
+func (m *MailboxHandler) DownloadMail(c *gin.Context) {
+	message, ok := m.message(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.eml"`, message.ID))
+	c.Data(http.StatusOK, "message/rfc822", message.Raw)
+}
+
+// ClearMail is a route handler that empties the development mailbox.
+//
+// @Summary Clear mailbox
+// @Description Removes every message kept in memory by the sink transport. Files already written are kept.
+// @Tags Development
+// @Produce json
+// @Success 200 {object} SuccessResponse
+// @Router /dev/mailbox [delete]
+func (m *MailboxHandler) ClearMail(c *gin.Context) {
+	m.deps.Mailbox.Clear()
+	helpers.ReturnJSON(c, "Mailbox cleared", nil, http.StatusOK)
+}
+
+// message loads the message named in the path.
+func (m *MailboxHandler) message(c *gin.Context) (mail.SentMessage, bool) {
+	message, found := m.deps.Mailbox.Find(c.Param("id"))
+	if !found {
+		helpers.ReturnError(c, "Message not found", errMessageNotFound, http.StatusNotFound)
+		return message, false
+	}
+	return message, true
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/bjorndonald/golang-backend-template/internal/bootstrap"
+	"github.com/bjorndonald/golang-backend-template/internal/helpers"
+	"github.com/bjorndonald/golang-backend-template/internal/otp"
+	"github.com/gin-gonic/gin"
+)
+
+// issueOTP creates a code for subject and purpose. It writes the error
+// response itself, answering 429 with a Retry-After header while the resend
+// cooldown is running, and reports whether the caller can continue.
+func issueOTP(c *gin.Context, deps *bootstrap.AppDependencies, subject string, purpose otp.OTPPurpose) (string, bool) {
+	code, err := deps.OTPManager.GenerateOTP(subject, purpose)
+
+	var cooldown *otp.CooldownError
+	if errors.As(err, &cooldown) {
+		setRetryAfter(c, cooldown.RetryAfter)
+		helpers.ReturnError(c, "Please wait before requesting another code", err, http.StatusTooManyRequests)
+		return "", false
+	}
+
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return "", false
+	}
+
+	return code, true
+}
+
+// checkOTP verifies a code for subject and purpose. It writes the error
+// response itself and reports whether the code was accepted.
+func checkOTP(c *gin.Context, deps *bootstrap.AppDependencies, subject string, purpose otp.OTPPurpose, code string) bool {
+	outcome, err := deps.OTPManager.VerifyOTP(subject, purpose, code)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return false
+	}
+
+	if outcome != otp.OutcomeOK {
+		otpRejected(c, outcome)
+		return false
+	}
+
+	return true
+}
+
+// otpRejected writes the response for a code that was not accepted.
+func otpRejected(c *gin.Context, outcome otp.Outcome) {
+	err := fmt.Errorf("otp %s", outcome)
+
+	switch outcome {
+	case otp.OutcomeExpired:
+		helpers.ReturnError(c, "OTP has expired, request a new one", err, http.StatusBadRequest)
+	case otp.OutcomeLocked:
+		helpers.ReturnError(c, "Too many wrong attempts, request a new OTP", err, http.StatusTooManyRequests)
+	default:
+		helpers.ReturnError(c, "OTP not valid", err, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,451 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/bjorndonald/golang-backend-template/internal/helpers"
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+	"github.com/bjorndonald/golang-backend-template/internal/passkey"
+	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/gofrs/uuid"
+)
+
+const (
+	passkeyRegistrationCookie = "webauthnRegistration"
+	passkeyLoginCookie        = "webauthnLogin"
+	passkeyCookiePath         = "/api/v1/auth/webauthn"
+	passkeyCookieMaxAge       = 5 * 60
+	defaultPasskeyName        = "Passkey"
+)
+
+var (
+	errPasskeysDisabled  = errors.New("passkeys are not configured")
+	errPasskeyCeremony   = errors.New("passkey ceremony expired or missing")
+	errPasskeyCloned     = errors.New("passkey signature counter went backwards")
+	errPasskeyNotFound   = errors.New("passkey not found")
+	errPasskeyUnverified = errors.New("passkey could not be verified")
+)
+
+type RenamePasskeyInput struct {
+	Name string `json:"name" validate:"required,max=64"`
+}
+
+// setCeremonyCookie stores the ceremony challenge encrypted in a short lived
+// cookie so that the finish request can be checked against it.
+func setCeremonyCookie(c *gin.Context, name string, session *webauthn.SessionData) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	value, err := helpers.EncryptString(string(data))
+	if err != nil {
+		return err
+	}
+
+	c.SetCookie(name, value, passkeyCookieMaxAge, passkeyCookiePath, "", true, true)
+	return nil
+}
+
+// takeCeremonyCookie reads and clears the ceremony cookie. A challenge can
+// only be answered once.
+func takeCeremonyCookie(c *gin.Context, name string) (*webauthn.SessionData, error) {
+	value, err := c.Cookie(name)
+	c.SetCookie(name, "", -1, passkeyCookiePath, "", true, true)
+	if err != nil || value == "" {
+		return nil, errPasskeyCeremony
+	}
+
+	data, err := helpers.DecryptString(value)
+	if err != nil {
+		return nil, errPasskeyCeremony
+	}
+
+	var session webauthn.SessionData
+	if err := json.Unmarshal([]byte(data), &session); err != nil {
+		return nil, errPasskeyCeremony
+	}
+
+	return &session, nil
+}
+
+// BeginPasskeyRegistration is a route handler that starts adding a passkey to the authenticated user.
+//
+// This endpoint returns the options to pass to navigator.credentials.create().
+//
+// @Summary Begin passkey registration
+// @Description Returns the WebAuthn credential creation options
+// @Tags Authentication
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} SuccessResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /auth/webauthn/register/begin [post]
+func (a *AuthHandler) BeginPasskeyRegistration(c *gin.Context) {
+	if a.passkeys == nil {
+		helpers.ReturnError(c, "Passkeys are not available", errPasskeysDisabled, http.StatusServiceUnavailable)
+		return
+	}
+
+	account, ok := loadAuthenticatedUser(c, a.deps)
+	if !ok {
+		return
+	}
+
+	credentials, err := a.deps.PasskeyRepo.FindByUserID(account.ID)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	user := passkey.NewUser(account, credentials)
+
+	options, session, err := a.passkeys.BeginRegistration(user, webauthn.WithExclusions(user.Exclusions()))
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := setCeremonyCookie(c, passkeyRegistrationCookie, session); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	helpers.ReturnJSON(c, "Passkey registration started", options, http.StatusOK)
+}
+
+// FinishPasskeyRegistration is a route handler that stores the passkey created by the browser.
+//
+// The request body is the PublicKeyCredential returned by navigator.credentials.create().
+//
+// @Summary Finish passkey registration
+// @Description Verifies the attestation and saves the passkey
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param name query string false "Name shown in the passkey list"
+// @Security BearerAuth
+// @Success 201 {object} models.WebAuthnCredential
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /auth/webauthn/register/finish [post]
+func (a *AuthHandler) FinishPasskeyRegistration(c *gin.Context) {
+	if a.passkeys == nil {
+		helpers.ReturnError(c, "Passkeys are not available", errPasskeysDisabled, http.StatusServiceUnavailable)
+		return
+	}
+
+	session, err := takeCeremonyCookie(c, passkeyRegistrationCookie)
+	if err != nil {
+		helpers.ReturnError(c, "Passkey registration expired", err, http.StatusBadRequest)
+		return
+	}
+
+	account, ok := loadAuthenticatedUser(c, a.deps)
+	if !ok {
+		return
+	}
+
+	credentials, err := a.deps.PasskeyRepo.FindByUserID(account.ID)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	credential, err := a.passkeys.FinishRegistration(passkey.NewUser(account, credentials), *session, c.Request)
+	if err != nil {
+		log.Println("error: ", err.Error())
+		helpers.ReturnError(c, "Passkey could not be verified", errPasskeyUnverified, http.StatusBadRequest)
+		return
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	name := c.Query("name")
+	if name == "" || len(name) > 64 {
+		name = defaultPasskeyName
+	}
+
+	record := passkey.NewRecord(id, account.ID, name, credential)
+	record.CreatedAt = time.Now()
+
+	if err := a.deps.PasskeyRepo.Create(record); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	helpers.ReturnJSON(c, "Passkey added", record, http.StatusCreated)
+}
+
+// BeginPasskeyLogin is a route handler that starts a passwordless login.
+//
+// This endpoint returns the options to pass to navigator.credentials.get(). No email is needed,
+// the browser offers the passkeys it holds for this site.
+//
+// @Summary Begin passkey login
+// @Description Returns the WebAuthn credential request options
+// @Tags Authentication
+// @Produce json
+// @Success 200 {object} SuccessResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /auth/webauthn/login/begin [post]
+func (a *AuthHandler) BeginPasskeyLogin(c *gin.Context) {
+	if a.passkeys == nil {
+		helpers.ReturnError(c, "Passkeys are not available", errPasskeysDisabled, http.StatusServiceUnavailable)
+		return
+	}
+
+	options, session, err := a.passkeys.BeginDiscoverableLogin()
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := setCeremonyCookie(c, passkeyLoginCookie, session); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	helpers.ReturnJSON(c, "Passkey login started", options, http.StatusOK)
+}
+
+// FinishPasskeyLogin is a route handler that logs the user in with a passkey.
+//
+// The request body is the PublicKeyCredential returned by navigator.credentials.get().
+// A passkey already proves possession and user presence, so no emailed OTP is needed.
+//
+// @Summary Finish passkey login
+// @Description Verifies the assertion and returns a jwt token
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Success 200 {object} LoginResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /auth/webauthn/login/finish [post]
+func (a *AuthHandler) FinishPasskeyLogin(c *gin.Context) {
+	if a.passkeys == nil {
+		helpers.ReturnError(c, "Passkeys are not available", errPasskeysDisabled, http.StatusServiceUnavailable)
+		return
+	}
+
+	session, err := takeCeremonyCookie(c, passkeyLoginCookie)
+	if err != nil {
+		helpers.ReturnError(c, "Passkey login expired", err, http.StatusBadRequest)
+		return
+	}
+
+	response, err := protocol.ParseCredentialRequestResponse(c.Request)
+	if err != nil {
+		helpers.ReturnError(c, "Error parsing request", err, http.StatusBadRequest)
+		return
+	}
+
+	var user *passkey.User
+	findUser := func(rawID, userHandle []byte) (webauthn.User, error) {
+		userID, err := passkey.UserID(userHandle)
+		if err != nil {
+			return nil, err
+		}
+
+		account, found, err := a.deps.UserRepo.FindByCondition("id = ?", userID.String())
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, errPasskeyNotFound
+		}
+
+		credentials, err := a.deps.PasskeyRepo.FindByUserID(account.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		user = passkey.NewUser(account, credentials)
+		return user, nil
+	}
+
+	credential, err := a.passkeys.ValidateDiscoverableLogin(findUser, *session, response)
+	if err != nil {
+		log.Println("error: ", err.Error())
+		helpers.ReturnError(c, "Passkey could not be verified", errPasskeyUnverified, http.StatusUnauthorized)
+		return
+	}
+
+	record, found := user.Lookup(credential.ID)
+	if !found {
+		helpers.ReturnError(c, "Passkey could not be verified", errPasskeyNotFound, http.StatusUnauthorized)
+		return
+	}
+
+	// A counter that does not move forward means two copies of the private
+	// key are in use, so the login is refused rather than just flagged.
+	if credential.Authenticator.CloneWarning {
+		log.Printf("passkey %s of user %s reported sign count %d after %d", record.ID, record.UserID, credential.Authenticator.SignCount, record.SignCount)
+		helpers.ReturnError(c, "Passkey could not be verified", errPasskeyCloned, http.StatusUnauthorized)
+		return
+	}
+
+	account := user.Account()
+	if account.Status == models.SuspendedAccount {
+		recordLoginEvent(c, a.deps, account, models.LoginEvent{Type: models.LoginFailed, Method: passkeyLogin, Reason: "account_suspended"})
+		helpers.ReturnError(c, "Account suspended", errAccountSuspended, http.StatusForbidden)
+		return
+	}
+
+	if err := a.deps.PasskeyRepo.RecordUse(record.ID, credential.Authenticator.SignCount, credential.Flags.BackupState); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	accessToken, err := issueTokenPair(c, a.deps, account.Email, account.FirstName, account.ID.String(), nil)
+	if errors.Is(err, errPasswordResetRequired) {
+		helpers.ReturnError(c, "Password reset required", err, http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		helpers.ReturnError(c, "Could not generate token", err, http.StatusInternalServerError)
+		return
+	}
+	recordLoginEvent(c, a.deps, account, models.LoginEvent{Type: models.LoginSucceeded, Method: passkeyLogin})
+
+	helpers.ReturnJSON(c, "Authenticated successfully", map[string]interface{}{
+		"access_token": accessToken,
+		"expires_in":   time.Now().Local().Add(helpers.AccessTokenTTL),
+	}, http.StatusOK)
+}
+
+// ListPasskeys is a route handler that lists the passkeys of the authenticated user.
+//
+// @Summary List passkeys
+// @Description Lists the passkeys the authenticated user can log in with
+// @Tags User
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.WebAuthnCredential
+// @Failure 401 {object} ErrorResponse
+// @Router /user/passkeys [get]
+func (u *UserHandler) ListPasskeys(c *gin.Context) {
+	user, ok := u.authenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	credentials, err := u.deps.PasskeyRepo.FindByUserID(user.ID)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	helpers.ReturnJSON(c, "Passkeys retrieved", credentials, http.StatusOK)
+}
+
+// RenamePasskey is a route handler that renames one of the authenticated user's passkeys.
+//
+// @Summary Rename passkey
+// @Description Changes the name shown for a passkey
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param id path string true "Passkey ID"
+// @Param passkey body RenamePasskeyInput true "New name"
+// @Security BearerAuth
+// @Success 200 {object} SuccessResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /user/passkeys/{id} [patch]
+func (u *UserHandler) RenamePasskey(c *gin.Context) {
+	var input RenamePasskeyInput
+
+	validatedReqBody, exists := c.Get("validatedRequestBody")
+
+	if !exists {
+		helpers.ReturnError(c, "Error parsing request", fmt.Errorf(helpers.INVALID_REQUEST_BODY), http.StatusBadRequest)
+		return
+	}
+
+	input, ok := validatedReqBody.(RenamePasskeyInput)
+	if !ok {
+		helpers.ReturnError(c, "Error parsing request", fmt.Errorf(helpers.REQUEST_BODY_PARSE_ERROR), http.StatusBadRequest)
+		return
+	}
+
+	record, ok := u.ownedPasskey(c)
+	if !ok {
+		return
+	}
+
+	if err := u.deps.PasskeyRepo.Rename(record.ID, input.Name); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	helpers.ReturnJSON(c, "Passkey renamed", nil, http.StatusOK)
+}
+
+// DeletePasskey is a route handler that removes one of the authenticated user's passkeys.
+//
+// @Summary Delete passkey
+// @Description Removes a passkey so it can no longer be used to log in
+// @Tags User
+// @Produce json
+// @Param id path string true "Passkey ID"
+// @Security BearerAuth
+// @Success 200 {object} SuccessResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /user/passkeys/{id} [delete]
+func (u *UserHandler) DeletePasskey(c *gin.Context) {
+	record, ok := u.ownedPasskey(c)
+	if !ok {
+		return
+	}
+
+	if err := u.deps.PasskeyRepo.Delete(record.ID); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	helpers.ReturnJSON(c, "Passkey deleted", nil, http.StatusOK)
+}
+
+// ownedPasskey loads the passkey named in the path if it belongs to the
+// authenticated user.
+func (u *UserHandler) ownedPasskey(c *gin.Context) (*models.WebAuthnCredential, bool) {
+	user, ok := u.authenticatedUser(c)
+	if !ok {
+		return nil, false
+	}
+
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		helpers.ReturnError(c, "Invalid passkey", err, http.StatusBadRequest)
+		return nil, false
+	}
+
+	record, found, err := u.deps.PasskeyRepo.Find(id)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return nil, false
+	}
+
+	if !found || record.UserID != user.ID {
+		helpers.ReturnError(c, "Passkey not found", errPasskeyNotFound, http.StatusNotFound)
+		return nil, false
+	}
+
+	return record, true
+}
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/bjorndonald/golang-backend-template/internal/bootstrap"
+	"github.com/bjorndonald/golang-backend-template/internal/helpers"
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+	"github.com/bjorndonald/golang-backend-template/internal/passwords"
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+)
+
+var (
+	errPasswordMismatch = errors.New("passwords do not match")
+	errPasswordReused   = errors.New("password was used before")
+	errWrongPassword    = errors.New("password does not match")
+)
+
+// ChangePasswordInput replaces the password of a signed in user. The
+// current password, or a code when the account has none, confirms it is
+// the account owner asking.
+type ChangePasswordInput struct {
+	ReauthenticateInput
+	NewPassword        string `json:"new_password" validate:"required,password"`
+	NewPasswordConfirm string `json:"new_password_confirm" validate:"required"`
+}
+
+// PasswordCheckInput is a password to score before it is submitted. The
+// optional details are checked the same way as the account's own.
+type PasswordCheckInput struct {
+	Password  string `json:"password" validate:"required"`
+	Email     string `json:"email"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+}
+
+// PasswordCheckResponse reports how a password fares against the policy.
+// Score runs from 0, too guessable, to 4, very unguessable.
+type PasswordCheckResponse struct {
+	Valid    bool     `json:"valid"`
+	Score    int      `json:"score"`
+	Breached bool     `json:"breached"`
+	Problems []string `json:"problems"`
+}
+
+// weakPassword writes a 400 and reports true if password fails the policy
+// once the user's own details are taken into account. The request
+// validator has already checked it without them.
+func weakPassword(c *gin.Context, user *models.User, password string) bool {
+	result := passwords.Default().Check(password, user.Email, user.FirstName, user.LastName)
+	if result.OK() {
+		return false
+	}
+
+	helpers.ReturnError(c, "Error validating input", fmt.Errorf("Password %s", result.Problems[0]), http.StatusBadRequest)
+	return true
+}
+
+// passwordUsedBefore reports whether password is the user's current one or
+// one of the replaced passwords kept in their history.
+func passwordUsedBefore(deps *bootstrap.AppDependencies, user *models.User, password string) (bool, error) {
+	if passwordMatches(user, password) {
+		return true, nil
+	}
+
+	history, err := deps.PasswordHistoryRepo.Recent(user.ID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, entry := range history {
+		if hashMatches(entry.PasswordHash, password) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// setPassword hashes password onto user and moves the replaced hash into
+// the password history. The caller saves the user.
+func setPassword(deps *bootstrap.AppDependencies, user *models.User, password string) error {
+	hashedPassword, err := helpers.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	if err := deps.PasswordHistoryRepo.Add(user.ID, user.Password); err != nil {
+		return err
+	}
+
+	user.Password = hashedPassword
+	return nil
+}
+
+// ChangePassword is a route handler that replaces the signed in user's password.
+//
+// Every other session is signed out, since one of them could belong to
+// whoever knew the old password, and the user is told by email.
+//
+// @Summary Change password
+// @Description Replaces the password after checking the current one or a fresh code. Other sessions are revoked.
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param credentials body ChangePasswordInput true "Current password or OTP and the new password"
+// @Security BearerAuth
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
+// @Router /user/password [put]
+func (u *UserHandler) ChangePassword(c *gin.Context) {
+	var input ChangePasswordInput
+
+	validatedReqBody, exists := c.Get("validatedRequestBody")
+
+	if !exists {
+		helpers.ReturnError(c, "Error parsing request", fmt.Errorf(helpers.INVALID_REQUEST_BODY), http.StatusBadRequest)
+		return
+	}
+
+	input, ok := validatedReqBody.(ChangePasswordInput)
+	if !ok {
+		helpers.ReturnError(c, "Error parsing request", fmt.Errorf(helpers.REQUEST_BODY_PARSE_ERROR), http.StatusBadRequest)
+		return
+	}
+
+	claims, err := helpers.GetAuthenticatedUser(c)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	user, ok := u.authenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	if !confirmStepUp(c, u.deps, user, input.ReauthenticateInput) {
+		return
+	}
+
+	if input.NewPassword != input.NewPasswordConfirm {
+		helpers.ReturnError(c, "Passwords do not match", errPasswordMismatch, http.StatusBadRequest)
+		return
+	}
+
+	if weakPassword(c, user, input.NewPassword) {
+		return
+	}
+
+	reused, err := passwordUsedBefore(u.deps, user, input.NewPassword)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	if reused {
+		helpers.ReturnError(c, "Please input a different password than one used before.", errPasswordReused, http.StatusBadRequest)
+		return
+	}
+
+	if err := setPassword(u.deps, user, input.NewPassword); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	user.AuthVersion = models.UpToDate
+	if err := u.deps.UserRepo.UpdateColumns(user, "password", "auth_version"); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := revokeAllSessions(u.deps, user.ID, uuid.FromStringOrNil(claims.SessionId)); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	go u.deps.EmailService.SendPasswordChangedEmail(user.FirstName, user.Email, c.ClientIP())
+
+	helpers.ReturnJSON(c, "Password changed", nil, http.StatusOK)
+}
+
+// CheckPassword is a route handler that scores a password against the password policy.
+//
+// It lets a form show the problems as the password is typed. Nothing is
+// stored and the password is only compared with the local breach list.
+//
+// @Summary Check a password
+// @Description Reports the strength score of a password and any policy rules it breaks
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param credentials body PasswordCheckInput true "Password and optional user details"
+// @Success 200 {object} PasswordCheckResponse
+// @Failure 400 {object} ErrorResponse
+// @Router /auth/password/check [post]
+func (a *AuthHandler) CheckPassword(c *gin.Context) {
+	var input PasswordCheckInput
+
+	validatedReqBody, exists := c.Get("validatedRequestBody")
+
+	if !exists {
+		helpers.ReturnError(c, "Error parsing request", fmt.Errorf(helpers.INVALID_REQUEST_BODY), http.StatusBadRequest)
+		return
+	}
+
+	input, ok := validatedReqBody.(PasswordCheckInput)
+	if !ok {
+		helpers.ReturnError(c, "Error parsing request", fmt.Errorf(helpers.REQUEST_BODY_PARSE_ERROR), http.StatusBadRequest)
+		return
+	}
+
+	result := passwords.Default().Check(input.Password, input.Email, input.FirstName, input.LastName)
+
+	problems := make([]string, 0, len(result.Problems))
+	for _, problem := range result.Problems {
+		problems = append(problems, "Password "+problem)
+	}
+
+	helpers.ReturnJSON(c, "Password checked", PasswordCheckResponse{
+		Valid:    result.OK(),
+		Score:    result.Score,
+		Breached: result.Breached,
+		Problems: problems,
+	}, http.StatusOK)
+}
@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bjorndonald/golang-backend-template/internal/bootstrap"
+	"github.com/bjorndonald/golang-backend-template/internal/helpers"
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+	"github.com/bjorndonald/golang-backend-template/internal/otp"
+	"github.com/bjorndonald/golang-backend-template/internal/risk"
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+)
+
+var (
+	errDeviceApproval = errors.New("device needs approval")
+	errEmailCode      = errors.New("emailed code required")
+)
+
+// assessLogin scores a login that got the password right. device is the
+// known device the login comes from, or nil for a device not seen before;
+// newDevice is only set when the user has other devices on record.
+func assessLogin(c *gin.Context, deps *bootstrap.AppDependencies, user *models.User, device *models.UserAgent, newDevice bool, location models.GeoLocation) (risk.Assessment, error) {
+	latest, hasSessions, err := deps.SessionRepo.Latest(user.ID)
+	if err != nil {
+		return risk.Assessment{}, err
+	}
+
+	login := risk.Login{
+		Sighting:      risk.Sighting{Time: time.Now(), Coordinates: location.Location},
+		IP:            c.ClientIP(),
+		NewDevice:     newDevice,
+		TrustedDevice: device != nil && device.Trusted,
+	}
+
+	if hasSessions {
+		login.Previous = &risk.Sighting{Time: latest.CreatedAt, Coordinates: latest.Location}
+	}
+
+	// A country is only new to accounts that have been seen somewhere
+	// before, and an address that could not be placed is in no country.
+	if location.Country != "" {
+		locations, hasLocations, err := deps.LocationRepo.FindByUserID(user.ID)
+		if err != nil {
+			return risk.Assessment{}, err
+		}
+
+		seen := false
+		for _, known := range locations {
+			seen = seen || known.Country == location.Country
+		}
+
+		if !seen && hasSessions {
+			seen, err = deps.SessionRepo.SeenCountry(user.ID, location.Country)
+			if err != nil {
+				return risk.Assessment{}, err
+			}
+		}
+
+		login.NewCountry = !seen && (hasLocations || hasSessions)
+	}
+
+	return deps.RiskPolicy.Assess(login), nil
+}
+
+// assessRequestLogin scores a login that skipped the password form, looking
+// up the device and place the request comes from. The fingerprint and
+// location are returned for deviceApproved.
+func assessRequestLogin(c *gin.Context, deps *bootstrap.AppDependencies, user *models.User) (risk.Assessment, string, models.GeoLocation, error) {
+	location, agent, err := helpers.GetDeviceLocation(c, deps.GeoResolver)
+	if err != nil {
+		log.Printf("could not resolve login location: %v", err)
+	}
+
+	fingerprint, err := currentDeviceFingerprint(c, agent)
+	if err != nil {
+		return risk.Assessment{}, "", location, err
+	}
+
+	device, knownDevice, err := deps.AgentRepo.FindByFingerprint(user.ID, fingerprint)
+	if err != nil {
+		return risk.Assessment{}, "", location, err
+	}
+
+	_, hasDevices, err := deps.AgentRepo.FindByUserID(user.ID)
+	if err != nil {
+		return risk.Assessment{}, "", location, err
+	}
+
+	assessment, err := assessLogin(c, deps, user, device, hasDevices && !knownDevice, location)
+	return assessment, fingerprint, location, err
+}
+
+// deviceApproved reports whether a login that needs approval may go on. It
+// uses up an approval the user gave the device, or else emails them a link
+// to give one.
+func deviceApproved(c *gin.Context, deps *bootstrap.AppDependencies, user *models.User, fingerprint string, location models.GeoLocation, assessment risk.Assessment) (bool, error) {
+	approved, err := deps.DeviceApprovalRepo.ConsumeApproved(user.ID, fingerprint, time.Now().Add(-helpers.DeviceTTL))
+	if err != nil || approved {
+		return approved, err
+	}
+
+	return false, requestDeviceApproval(c, deps, user, fingerprint, location, assessment)
+}
+
+// sendLoginCode emails the user a code for the second step of a login. A
+// code sent during the cooldown is still valid, so a repeated login just
+// skips sending another one.
+func sendLoginCode(deps *bootstrap.AppDependencies, user *models.User) error {
+	code, err := deps.OTPManager.GenerateOTP(user.Email, otp.LoginOTP)
+	if errors.Is(err, otp.ErrResendCooldown) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	go deps.EmailService.SendOTPEmail(user.FirstName, user.Email, code)
+	return nil
+}
+
+// requestDeviceApproval emails the user a link to approve the device a risky
+// login came from. While an earlier link for the device is still pending no
+// new one is sent.
+func requestDeviceApproval(c *gin.Context, deps *bootstrap.AppDependencies, user *models.User, fingerprint string, location models.GeoLocation, assessment risk.Assessment) error {
+	_, pending, err := deps.DeviceApprovalRepo.FindPending(user.ID, fingerprint)
+	if err != nil || pending {
+		return err
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return err
+	}
+
+	approval := &models.DeviceApproval{
+		ID:          id,
+		UserID:      user.ID,
+		Fingerprint: fingerprint,
+		IP:          c.ClientIP(),
+		UserAgent:   c.Request.UserAgent(),
+		City:        location.City,
+		Country:     location.Country,
+		Signals:     strings.Join(assessment.Signals, ","),
+		ExpiresAt:   time.Now().Add(helpers.DeviceTTL),
+		CreatedAt:   time.Now(),
+	}
+
+	if err := deps.DeviceApprovalRepo.Create(approval); err != nil {
+		return err
+	}
+
+	token, err := helpers.GenerateDeviceToken(user.Email, user.FirstName, user.ID.String(), approval.ID.String())
+	if err != nil {
+		return err
+	}
+
+	link := publicURL("/api/v1/auth/verify/device/" + token)
+	go deps.EmailService.SendDeviceApprovalEmail(user.FirstName, user.Email, link, *approval)
+	return nil
+}
+
+// deviceApprovalFromLink loads the pending approval the link in the path
+// was issued for. When there is none it redirects with the reason.
+func (a *AuthHandler) deviceApprovalFromLink(c *gin.Context, redirect func(string)) (*models.DeviceApproval, bool) {
+	claims, err := helpers.ValidateDeviceToken(c.Param("token"))
+	if err != nil {
+		redirect("error=device_approval_invalid")
+		return nil, false
+	}
+
+	id, err := uuid.FromString(claims.Id)
+	if err != nil {
+		redirect("error=device_approval_invalid")
+		return nil, false
+	}
+
+	approval, found, err := a.deps.DeviceApprovalRepo.Find(id)
+	if err != nil {
+		log.Println("error: ", err.Error())
+		redirect("error=500")
+		return nil, false
+	}
+
+	if !found || approval.UserID.String() != claims.UserId || !approval.Pending() {
+		redirect("error=device_approval_expired")
+		return nil, false
+	}
+
+	return approval, true
+}
+
+// ShowDeviceApproval is a route handler that asks the user to confirm the device approval link they followed.
+//
+// Opening the link changes nothing, so a mail scanner fetching it cannot
+// approve the device.
+//
+// @Summary Show device approval
+// @Description Shows where the blocked login came from and a button that approves the device
+// @Tags Authentication
+// @Produce html
+// @Param token path string true "Device approval token"
+// @Success 200 {string} string "The confirmation page"
+// @Failure 302 {string} string "Redirects to the client URL with an error code"
+// @Router /auth/verify/device/{token} [get]
+func (a *AuthHandler) ShowDeviceApproval(c *gin.Context) {
+	redirect := func(query string) {
+		c.Redirect(http.StatusFound, fmt.Sprintf("%s/auth/device?%s", constant.ClientUrl, query))
+	}
+
+	approval, ok := a.deviceApprovalFromLink(c, redirect)
+	if !ok {
+		return
+	}
+
+	place := approval.IP
+	for _, part := range []string{approval.City, approval.Country} {
+		if part != "" {
+			place += ", " + part
+		}
+	}
+
+	renderConfirmation(c, confirmation{
+		Title:  "Approve this device?",
+		Text:   fmt.Sprintf("A login to your account from %s was blocked. Only approve the device if it was you.", place),
+		Button: "Approve device",
+	})
+}
+
+// VerifyDevice is a route handler that approves a device from the link sent after a risky login.
+//
+// Approving does not sign the device in. The user logs in again from it, and
+// that login uses the approval up.
+//
+// @Summary Approve a new device
+// @Description Approves the device a blocked login came from so the next login from it can go on
+// @Tags Authentication
+// @Produce json
+// @Param token path string true "Device approval token"
+// @Success 303 {string} string "Redirects to the client URL with the outcome"
+// @Failure 303 {string} string "Redirects to the client URL with an error code"
+// @Router /auth/verify/device/{token} [post]
+func (a *AuthHandler) VerifyDevice(c *gin.Context) {
+	redirect := func(query string) {
+		c.Redirect(http.StatusSeeOther, fmt.Sprintf("%s/auth/device?%s", constant.ClientUrl, query))
+	}
+
+	approval, ok := a.deviceApprovalFromLink(c, redirect)
+	if !ok {
+		return
+	}
+
+	approved, err := a.deps.DeviceApprovalRepo.MarkApproved(approval.ID)
+	if err != nil {
+		log.Println("error: ", err.Error())
+		redirect("error=500")
+		return
+	}
+
+	if !approved {
+		redirect("error=device_approval_expired")
+		return
+	}
+
+	redirect("status=device_approved")
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bjorndonald/golang-backend-template/internal/helpers"
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+)
+
+func TestDeviceApprovalLinkIsSingleUse(t *testing.T) {
+	a := newTestAuth(t)
+	a.router.GET("/verify/device/:token", a.handler.ShowDeviceApproval)
+	a.router.POST("/verify/device/:token", a.handler.VerifyDevice)
+
+	approval := &models.DeviceApproval{
+		ID:          newID(t),
+		UserID:      a.user.ID,
+		Fingerprint: "fingerprint",
+		IP:          "203.0.113.7",
+		Country:     "NZ",
+		ExpiresAt:   time.Now().Add(helpers.DeviceTTL),
+		CreatedAt:   time.Now(),
+	}
+	a.deviceApprovals.approvals[approval.ID] = approval
+
+	token, err := helpers.GenerateDeviceToken(a.user.Email, a.user.FirstName, a.user.ID.String(), approval.ID.String())
+	if err != nil {
+		t.Fatalf("device token: %v", err)
+	}
+
+	// Opening the link, as a mail scanner would, only shows the page.
+	recorder := a.do(http.MethodGet, "/verify/device/"+token)
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), "203.0.113.7, NZ") {
+		t.Fatalf("expected the confirmation page, got %d", recorder.Code)
+	}
+	if approval.ApprovedAt != nil {
+		t.Fatalf("expected opening the link not to approve the device")
+	}
+
+	recorder = a.do(http.MethodPost, "/verify/device/"+token)
+	expectRedirect(t, recorder.Code, recorder.Header().Get("Location"), http.StatusSeeOther, "status=device_approved")
+
+	if approval.ApprovedAt == nil {
+		t.Fatalf("expected the device to be approved")
+	}
+
+	recorder = a.do(http.MethodPost, "/verify/device/"+token)
+	expectRedirect(t, recorder.Code, recorder.Header().Get("Location"), http.StatusSeeOther, "error=device_approval_expired")
+}
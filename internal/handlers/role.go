@@ -0,0 +1,410 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bjorndonald/golang-backend-template/internal/bootstrap"
+	"github.com/bjorndonald/golang-backend-template/internal/helpers"
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+	"github.com/bjorndonald/golang-backend-template/internal/rbac"
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+)
+
+var (
+	errRoleNotFound    = errors.New("role not found")
+	errBuiltInRole     = errors.New("built in roles cannot be changed or deleted")
+	errCannotGrant     = errors.New("you cannot grant permissions you do not have")
+	errRoleNameTaken   = errors.New("a role with this name already exists")
+	errRoleNotAssigned = errors.New("role is not assigned to this user")
+)
+
+type CreateRoleInput struct {
+	Name        string   `json:"name" validate:"required,max=64"`
+	Description string   `json:"description" validate:"max=255"`
+	Permissions []string `json:"permissions" validate:"dive,required"`
+}
+
+type UpdateRolePermissionsInput struct {
+	Description string   `json:"description" validate:"max=255"`
+	Permissions []string `json:"permissions" validate:"dive,required"`
+}
+
+// UserRoles describes where a user's permissions come from.
+type UserRoles struct {
+	Role        models.AccountRole `json:"role"`
+	Roles       []*models.Role     `json:"roles"`
+	Permissions []string           `json:"permissions"`
+}
+
+// checkGrantable refuses permissions that are malformed or that the
+// authenticated user does not hold, so roles:write cannot be used to hand out
+// more access than the caller has.
+func checkGrantable(c *gin.Context, permissions []string) bool {
+	granted := helpers.GetPermissions(c)
+
+	for _, permission := range permissions {
+		if !rbac.Valid(permission) {
+			helpers.ReturnError(c, "Invalid permission", fmt.Errorf("permission %q is not written as resource:action", permission), http.StatusBadRequest)
+			return false
+		}
+
+		if !rbac.Allows(granted, permission) {
+			helpers.ReturnError(c, "Unauthorized access to resource", errCannotGrant, http.StatusForbidden)
+			return false
+		}
+	}
+	return true
+}
+
+// findRoleParam loads the role named by the :id path parameter.
+func findRoleParam(c *gin.Context, deps *bootstrap.AppDependencies) (*models.Role, bool) {
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		helpers.ReturnError(c, "Role not found", errRoleNotFound, http.StatusNotFound)
+		return nil, false
+	}
+
+	role, found, err := deps.RoleRepo.Find(id)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return nil, false
+	}
+
+	if !found {
+		helpers.ReturnError(c, "Role not found", errRoleNotFound, http.StatusNotFound)
+		return nil, false
+	}
+
+	return role, true
+}
+
+func builtInRole(role *models.Role) bool {
+	_, ok := rbac.DefaultRoles[role.Name]
+	return ok
+}
+
+// ListRoles is a route handler that lists every role.
+//
+// @Summary List roles
+// @Description Lists every role and the permissions it grants
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.Role
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /admin/roles [get]
+func (a *AdminHandler) ListRoles(c *gin.Context) {
+	roles, err := a.deps.RoleRepo.FindAll()
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	helpers.ReturnJSON(c, "Roles retrieved", roles, http.StatusOK)
+}
+
+// CreateRole is a route handler that creates a role.
+//
+// @Summary Create role
+// @Description Creates a role. Only permissions the caller holds can be granted.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param credentials body CreateRoleInput true "Role name, description and permissions"
+// @Security BearerAuth
+// @Success 201 {object} models.Role
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /admin/roles [post]
+func (a *AdminHandler) CreateRole(c *gin.Context) {
+	var input CreateRoleInput
+	validatedReqBody, exists := c.Get("validatedRequestBody")
+
+	if !exists {
+		helpers.ReturnError(c, "Error parsing request", fmt.Errorf(helpers.INVALID_REQUEST_BODY), http.StatusBadRequest)
+		return
+	}
+
+	input, ok := validatedReqBody.(CreateRoleInput)
+	if !ok {
+		helpers.ReturnError(c, "Error parsing request", fmt.Errorf(helpers.REQUEST_BODY_PARSE_ERROR), http.StatusBadRequest)
+		return
+	}
+
+	if !checkGrantable(c, input.Permissions) {
+		return
+	}
+
+	_, found, err := a.deps.RoleRepo.FindByName(input.Name)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	if found {
+		helpers.ReturnError(c, "Role already exists", errRoleNameTaken, http.StatusConflict)
+		return
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	role := &models.Role{
+		ID:          id,
+		Name:        input.Name,
+		Description: input.Description,
+		Permissions: rbac.Merge(input.Permissions),
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	if err := a.deps.RoleRepo.Create(role); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	recordAudit(c, a.deps, models.AuditRoleCreate, "role", role.ID.String(), map[string]interface{}{"name": role.Name, "permissions": role.Permissions})
+
+	helpers.ReturnJSON(c, "Role created", role, http.StatusCreated)
+}
+
+// UpdateRole is a route handler that changes the description and permissions of a role.
+//
+// @Summary Update role
+// @Description Replaces the permissions of a role. The built in admin role cannot be changed.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Role ID"
+// @Param credentials body UpdateRolePermissionsInput true "Description and permissions"
+// @Security BearerAuth
+// @Success 200 {object} models.Role
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /admin/roles/{id} [put]
+func (a *AdminHandler) UpdateRole(c *gin.Context) {
+	var input UpdateRolePermissionsInput
+	validatedReqBody, exists := c.Get("validatedRequestBody")
+
+	if !exists {
+		helpers.ReturnError(c, "Error parsing request", fmt.Errorf(helpers.INVALID_REQUEST_BODY), http.StatusBadRequest)
+		return
+	}
+
+	input, ok := validatedReqBody.(UpdateRolePermissionsInput)
+	if !ok {
+		helpers.ReturnError(c, "Error parsing request", fmt.Errorf(helpers.REQUEST_BODY_PARSE_ERROR), http.StatusBadRequest)
+		return
+	}
+
+	role, ok := findRoleParam(c, a.deps)
+	if !ok {
+		return
+	}
+
+	// Taking permissions away from the admin role could leave nobody able
+	// to give them back.
+	if role.Name == rbac.AdminRoleName {
+		helpers.ReturnError(c, "Role cannot be changed", errBuiltInRole, http.StatusForbidden)
+		return
+	}
+
+	if !checkGrantable(c, input.Permissions) {
+		return
+	}
+
+	role.Description = input.Description
+	role.Permissions = rbac.Merge(input.Permissions)
+	role.UpdatedAt = time.Now()
+
+	if err := a.deps.RoleRepo.Update(role); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	recordAudit(c, a.deps, models.AuditRoleUpdate, "role", role.ID.String(), map[string]interface{}{"name": role.Name, "permissions": role.Permissions})
+
+	helpers.ReturnJSON(c, "Role updated", role, http.StatusOK)
+}
+
+// DeleteRole is a route handler that deletes a role and takes it away from everyone who had it.
+//
+// @Summary Delete role
+// @Description Deletes a role and its assignments. The built in roles cannot be deleted.
+// @Tags Admin
+// @Produce json
+// @Param id path string true "Role ID"
+// @Security BearerAuth
+// @Success 200 {object} SuccessResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /admin/roles/{id} [delete]
+func (a *AdminHandler) DeleteRole(c *gin.Context) {
+	role, ok := findRoleParam(c, a.deps)
+	if !ok {
+		return
+	}
+
+	if builtInRole(role) {
+		helpers.ReturnError(c, "Role cannot be deleted", errBuiltInRole, http.StatusForbidden)
+		return
+	}
+
+	if err := a.deps.RoleRepo.Delete(role.ID); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	recordAudit(c, a.deps, models.AuditRoleDelete, "role", role.ID.String(), map[string]interface{}{"name": role.Name})
+
+	helpers.ReturnJSON(c, "Role deleted", nil, http.StatusOK)
+}
+
+// ListUserRoles is a route handler that shows the roles of a user and the permissions they add up to.
+//
+// @Summary List user roles
+// @Description Returns the user's role column, assigned roles and resolved permissions
+// @Tags Admin
+// @Produce json
+// @Param id path string true "User ID"
+// @Security BearerAuth
+// @Success 200 {object} UserRoles
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /admin/users/{id}/roles [get]
+func (a *AdminHandler) ListUserRoles(c *gin.Context) {
+	user, ok := findUserParam(c, a.deps)
+	if !ok {
+		return
+	}
+
+	roles, err := a.deps.RoleRepo.FindByUserID(user.ID)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	permissions, err := a.deps.RoleRepo.PermissionsForUser(user)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	helpers.ReturnJSON(c, "Roles retrieved", UserRoles{Role: user.Role, Roles: roles, Permissions: permissions}, http.StatusOK)
+}
+
+// AssignRole is a route handler that gives a user a role.
+//
+// @Summary Assign role
+// @Description Gives the user a role by name. Only roles whose permissions the caller holds can be assigned.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param id path string true "User ID"
+// @Param credentials body UpdateRoleInput true "Role name"
+// @Security BearerAuth
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /admin/users/{id}/roles [post]
+func (a *AdminHandler) AssignRole(c *gin.Context) {
+	var input UpdateRoleInput
+	validatedReqBody, exists := c.Get("validatedRequestBody")
+
+	if !exists {
+		helpers.ReturnError(c, "Error parsing request", fmt.Errorf(helpers.INVALID_REQUEST_BODY), http.StatusBadRequest)
+		return
+	}
+
+	input, ok := validatedReqBody.(UpdateRoleInput)
+	if !ok {
+		helpers.ReturnError(c, "Error parsing request", fmt.Errorf(helpers.REQUEST_BODY_PARSE_ERROR), http.StatusBadRequest)
+		return
+	}
+
+	user, ok := findUserParam(c, a.deps)
+	if !ok {
+		return
+	}
+
+	role, found, err := a.deps.RoleRepo.FindByName(input.Role)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	if !found {
+		helpers.ReturnError(c, "Role not found", errRoleNotFound, http.StatusNotFound)
+		return
+	}
+
+	if !checkGrantable(c, role.Permissions) {
+		return
+	}
+
+	if err := a.deps.RoleRepo.Assign(user.ID, role.ID); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	recordAudit(c, a.deps, models.AuditRoleAssign, "user", user.ID.String(), map[string]interface{}{"role": role.Name})
+
+	helpers.ReturnJSON(c, "Role assigned", nil, http.StatusOK)
+}
+
+// UnassignRole is a route handler that takes a role away from a user.
+//
+// A role given by the user's role column cannot be taken away here.
+//
+// @Summary Unassign role
+// @Description Removes a role assignment from the user
+// @Tags Admin
+// @Produce json
+// @Param id path string true "User ID"
+// @Param role path string true "Role name"
+// @Security BearerAuth
+// @Success 200 {object} SuccessResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /admin/users/{id}/roles/{role} [delete]
+func (a *AdminHandler) UnassignRole(c *gin.Context) {
+	user, ok := findUserParam(c, a.deps)
+	if !ok {
+		return
+	}
+
+	roles, err := a.deps.RoleRepo.FindByUserID(user.ID)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	for _, role := range roles {
+		if role.Name != c.Param("role") {
+			continue
+		}
+
+		if err := a.deps.RoleRepo.Unassign(user.ID, role.ID); err != nil {
+			helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+			return
+		}
+
+		recordAudit(c, a.deps, models.AuditRoleUnassign, "user", user.ID.String(), map[string]interface{}{"role": role.Name})
+
+		helpers.ReturnJSON(c, "Role unassigned", nil, http.StatusOK)
+		return
+	}
+
+	helpers.ReturnError(c, "Role not found", errRoleNotAssigned, http.StatusNotFound)
+}
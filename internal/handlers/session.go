@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/bjorndonald/golang-backend-template/internal/helpers"
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+)
+
+// ListSessions is a route handler that lists the active sessions of the authenticated user.
+//
+// @Summary List sessions
+// @Description Lists the devices the authenticated user is currently logged in on
+// @Tags User
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.Session
+// @Failure 401 {object} ErrorResponse
+// @Router /user/sessions [get]
+func (u *UserHandler) ListSessions(c *gin.Context) {
+	claims, err := helpers.GetAuthenticatedUser(c)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	userID, err := uuid.FromString(claims.UserId)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusUnauthorized)
+		return
+	}
+
+	sessions, err := u.deps.SessionRepo.FindActiveByUserID(userID)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	for _, session := range sessions {
+		session.Current = session.ID.String() == claims.SessionId
+	}
+
+	helpers.ReturnJSON(c, "Sessions retrieved", sessions, http.StatusOK)
+}
+
+// RevokeSession is a route handler that logs the authenticated user out of one session.
+//
+// @Summary Revoke session
+// @Description Revokes one of the authenticated user's sessions
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param id path string true "Session ID"
+// @Security BearerAuth
+// @Success 200 {object} SuccessResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /user/sessions/{id} [delete]
+func (u *UserHandler) RevokeSession(c *gin.Context) {
+	claims, err := helpers.GetAuthenticatedUser(c)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	userID, err := uuid.FromString(claims.UserId)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusUnauthorized)
+		return
+	}
+
+	sessionID, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		helpers.ReturnError(c, "Invalid session", err, http.StatusBadRequest)
+		return
+	}
+
+	session, found, err := u.deps.SessionRepo.Find(sessionID)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	if !found || session.UserID != userID {
+		helpers.ReturnError(c, "Session not found", fmt.Errorf("session not found"), http.StatusNotFound)
+		return
+	}
+
+	if err := revokeSession(u.deps, userID, sessionID); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	helpers.ReturnJSON(c, "Session revoked", nil, http.StatusOK)
+}
+
+// RevokeOtherSessions is a route handler that logs the authenticated user out of every other session.
+//
+// @Summary Revoke other sessions
+// @Description Revokes all of the authenticated user's sessions except the current one
+// @Tags User
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} SuccessResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /user/sessions [delete]
+func (u *UserHandler) RevokeOtherSessions(c *gin.Context) {
+	claims, err := helpers.GetAuthenticatedUser(c)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	userID, err := uuid.FromString(claims.UserId)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusUnauthorized)
+		return
+	}
+
+	if err := revokeAllSessions(u.deps, userID, uuid.FromStringOrNil(claims.SessionId)); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	helpers.ReturnJSON(c, "Other sessions revoked", nil, http.StatusOK)
+}
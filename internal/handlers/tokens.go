@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/bjorndonald/golang-backend-template/internal/bootstrap"
+	"github.com/bjorndonald/golang-backend-template/internal/helpers"
+	"github.com/bjorndonald/golang-backend-template/internal/manager"
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+)
+
+const refreshTokenCookie = "refreshToken"
+
+var errPasswordResetRequired = errors.New("password reset required")
+
+// issueTokenPair mints an access token and a persisted refresh token, sets the
+// refresh token cookie and returns the access token. When parent is nil a new
+// session is started, otherwise the new refresh token is rotated from parent
+// and stays in its session.
+//
+// No session is started for a user who has to reset their password, so
+// every way of logging in honours a forced reset; errPasswordResetRequired
+// is returned instead.
+func issueTokenPair(c *gin.Context, deps *bootstrap.AppDependencies, email, name, userID string, parent *models.RefreshToken) (string, error) {
+	userUUID, err := uuid.FromString(userID)
+	if err != nil {
+		return "", err
+	}
+
+	if parent == nil {
+		user, found, err := deps.UserRepo.FindByCondition("id = ?", userID)
+		if err != nil {
+			return "", err
+		}
+		if found && user.AuthVersion == models.Outdated {
+			return "", errPasswordResetRequired
+		}
+	}
+
+	tokenID, err := uuid.NewV7()
+	if err != nil {
+		return "", err
+	}
+
+	expiresAt := time.Now().Add(helpers.RefreshTokenTTL)
+
+	record := &models.RefreshToken{
+		ID:        tokenID,
+		UserID:    userUUID,
+		Device:    c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}
+
+	if parent != nil {
+		record.FamilyID = parent.FamilyID
+		record.ParentID = &parent.ID
+
+		if err := deps.SessionRepo.Extend(parent.FamilyID, expiresAt); err != nil {
+			return "", err
+		}
+	} else {
+		session, err := startSession(c, deps, userUUID, expiresAt)
+		if err != nil {
+			return "", err
+		}
+		record.FamilyID = session.ID
+	}
+
+	refreshToken, err := helpers.GenerateRefreshToken(email, name, userID, tokenID.String())
+	if err != nil {
+		return "", err
+	}
+	record.TokenHash = helpers.HashToken(refreshToken)
+
+	if err := deps.RefreshTokenRepo.Create(record); err != nil {
+		return "", err
+	}
+
+	accessToken, err := helpers.GenerateAccessToken(email, name, userID, record.FamilyID.String())
+	if err != nil {
+		return "", err
+	}
+
+	c.SetCookie(refreshTokenCookie, refreshToken, int(helpers.RefreshTokenTTL.Seconds()), "/", "", true, true)
+
+	return accessToken, nil
+}
+
+// startSession records a new logged in session for the requesting device.
+func startSession(c *gin.Context, deps *bootstrap.AppDependencies, userID uuid.UUID, expiresAt time.Time) (*models.Session, error) {
+	sessionID, err := uuid.NewV7()
+	if err != nil {
+		return nil, err
+	}
+
+	// The device is still known when the location lookup fails, so a
+	// missing location should not stop the user from logging in.
+	location, agent, err := helpers.GetDeviceLocation(c, deps.GeoResolver)
+	if err != nil {
+		log.Printf("could not resolve session location: %v", err)
+	}
+
+	// Only a completed login makes a device or place known, so a password
+	// alone cannot make an attacker's device look familiar.
+	device, err := rememberDevice(c, deps, userID, agent, location)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := rememberLocation(c, deps, userID, location); err != nil {
+		return nil, err
+	}
+
+	session := &models.Session{
+		ID:          sessionID,
+		UserID:      userID,
+		DeviceID:    device.ID,
+		IP:          c.ClientIP(),
+		Platform:    agent.Platform,
+		OS:          agent.OS,
+		BrowserName: agent.BrowserName,
+		Mobile:      agent.Mobile,
+		Model:       agent.Model,
+		City:        location.City,
+		Region:      location.Region,
+		Country:     location.Country,
+		Location:    location.Location,
+		CreatedAt:   time.Now(),
+		LastSeenAt:  time.Now(),
+		ExpiresAt:   expiresAt,
+	}
+
+	if err := deps.SessionRepo.Create(session); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// revokeSession revokes a session and its refresh tokens and tells any live
+// listener of the user that the session has ended.
+func revokeSession(deps *bootstrap.AppDependencies, userID, sessionID uuid.UUID) error {
+	if err := deps.SessionRepo.Revoke(sessionID); err != nil {
+		return err
+	}
+
+	if err := deps.RefreshTokenRepo.RevokeFamily(sessionID); err != nil {
+		return err
+	}
+
+	deps.StreamManager.SubmitSession(userID.String(), sessionID.String(), manager.Logout)
+	return nil
+}
+
+// revokeAllSessions revokes every session of the user except keep and tells
+// any live listener about each one. Pass uuid.Nil to revoke all of them.
+func revokeAllSessions(deps *bootstrap.AppDependencies, userID, keep uuid.UUID) error {
+	sessions, err := deps.SessionRepo.FindActiveByUserID(userID)
+	if err != nil {
+		return err
+	}
+
+	if err := deps.SessionRepo.RevokeAllForUser(userID, keep); err != nil {
+		return err
+	}
+
+	if err := deps.RefreshTokenRepo.RevokeAllForUser(userID, keep); err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if session.ID != keep {
+			deps.StreamManager.SubmitSession(userID.String(), session.ID.String(), manager.Logout)
+		}
+	}
+
+	return nil
+}
+
+func clearRefreshToken(c *gin.Context) {
+	c.SetCookie(refreshTokenCookie, "", -1, "/", "", true, true)
+}
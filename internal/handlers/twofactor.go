@@ -0,0 +1,711 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bjorndonald/golang-backend-template/internal/bootstrap"
+	"github.com/bjorndonald/golang-backend-template/internal/helpers"
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+	"github.com/bjorndonald/golang-backend-template/internal/otp"
+	"github.com/bjorndonald/golang-backend-template/internal/passwords"
+	"github.com/bjorndonald/golang-backend-template/internal/recovery"
+	"github.com/bjorndonald/golang-backend-template/internal/totp"
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+)
+
+type TwoFactorVerifyInput struct {
+	Token  string `json:"token" validate:"required"`
+	OTP    string `json:"otp" validate:"required"`
+	Method string `json:"method" validate:"omitempty,oneof=email totp recovery"`
+	// EmailOTP is the emailed code a risky login needs on top of a TOTP or
+	// recovery code.
+	EmailOTP string `json:"email_otp"`
+}
+
+// ReauthenticateInput confirms a sensitive action with the current password
+// or a fresh second factor code.
+type ReauthenticateInput struct {
+	Password string `json:"password" validate:"required_without=OTP"`
+	OTP      string `json:"otp" validate:"required_without=Password"`
+}
+
+type TwoFactorPreferencesInput struct {
+	EmailOTPEnabled *bool `json:"email_otp_enabled" validate:"required"`
+}
+
+// twoFactorMethodsQuery renders the user's second factors for the 2FA page URL.
+func twoFactorMethodsQuery(user *models.User) string {
+	var methods []string
+	for _, method := range user.TwoFactorMethods() {
+		methods = append(methods, string(method))
+	}
+	return strings.Join(methods, ",")
+}
+
+// verifyTOTPCode checks an authenticator app code for the user and records
+// the time step it used so the same code cannot be replayed.
+func verifyTOTPCode(deps *bootstrap.AppDependencies, user *models.User, code string) (bool, error) {
+	secret, err := helpers.DecryptString(user.TOTPSecret)
+	if err != nil {
+		return false, err
+	}
+
+	step, valid := totp.Validate(secret, code, time.Now(), user.TOTPLastStep)
+	if !valid {
+		return false, nil
+	}
+
+	user.TOTPLastStep = step
+	if err := deps.UserRepo.UpdateColumns(user, "TOTPLastStep"); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// passwordMatches reports whether password is the user's current password.
+func passwordMatches(user *models.User, password string) bool {
+	return hashMatches(user.Password, password)
+}
+
+// hashMatches reports whether password produced hash, whichever algorithm
+// made it.
+func hashMatches(hash, password string) bool {
+	match, _, err := passwords.CurrentHasher().Verify(password, hash)
+	return err == nil && match
+}
+
+// verifyStepUp checks the password or code the user entered to confirm a
+// sensitive action. Codes are checked against the authenticator app first
+// and then against an emailed OTP.
+func verifyStepUp(deps *bootstrap.AppDependencies, user *models.User, input ReauthenticateInput) (bool, error) {
+	if input.Password != "" {
+		return passwordMatches(user, input.Password), nil
+	}
+
+	if user.TOTPEnabled {
+		valid, err := verifyTOTPCode(deps, user, input.OTP)
+		if err != nil || valid {
+			return valid, err
+		}
+	}
+
+	outcome, err := deps.OTPManager.VerifyOTP(user.Email, otp.StepUpOTP, input.OTP)
+	return outcome == otp.OutcomeOK, err
+}
+
+// confirmStepUp checks the password or code confirming a sensitive action,
+// writing the error response itself when it cannot. Wrong attempts are
+// counted, and too many block confirmations for a while.
+func confirmStepUp(c *gin.Context, deps *bootstrap.AppDependencies, user *models.User, input ReauthenticateInput) bool {
+	if stepUpBlocked(c, deps, user) {
+		return false
+	}
+
+	confirmed, err := verifyStepUp(deps, user, input)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return false
+	}
+
+	if !confirmed {
+		recordStepUpFailure(deps, user)
+		helpers.ReturnError(c, "Could not confirm your identity", fmt.Errorf("invalid password or otp"), http.StatusUnauthorized)
+		return false
+	}
+
+	resetStepUpFailures(deps, user)
+	return true
+}
+
+// issueRecoveryCodes replaces the user's recovery codes with a new set and
+// returns the plain codes, which are never stored.
+func issueRecoveryCodes(deps *bootstrap.AppDependencies, user *models.User) ([]string, error) {
+	codes, err := recovery.Generate(recovery.Count)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]*models.RecoveryCode, 0, len(codes))
+	for _, code := range codes {
+		id, err := uuid.NewV7()
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, &models.RecoveryCode{
+			ID:        id,
+			UserID:    user.ID,
+			CodeHash:  recovery.Hash(user.ID.String(), code),
+			CreatedAt: time.Now(),
+		})
+	}
+
+	if err := deps.RecoveryCodeRepo.Replace(user.ID, records); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// issueFirstRecoveryCodes gives a user with a second factor their first set
+// of recovery codes. It returns nil when they were given codes before.
+func issueFirstRecoveryCodes(deps *bootstrap.AppDependencies, user *models.User) ([]string, error) {
+	issued, err := deps.RecoveryCodeRepo.Issued(user.ID)
+	if err != nil || issued {
+		return nil, err
+	}
+	return issueRecoveryCodes(deps, user)
+}
+
+// Verify2FA is a route handler that completes a login with a second factor.
+//
+// This endpoint accepts an authenticator app code or an emailed OTP, depending on what the user enabled,
+// or one of the user's recovery codes. When no method is given the user's preferred method is used.
+// Logins the risk policy challenged also need the emailed code, in email_otp unless it is the method.
+//
+// @Summary Verify second factor
+// @Description Exchanges the pending login token and a TOTP or email code for an access token. The first such login also returns the user's recovery codes, once.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param credentials body TwoFactorVerifyInput true "Pending token, code and method"
+// @Success 200 {string} string "Returns 'success' "
+// @Failure 400 {string} string "Returns error message"
+// @Router /auth/2fa/verify [post]
+func (a *AuthHandler) Verify2FA(c *gin.Context) {
+	var input TwoFactorVerifyInput
+
+	validatedReqBody, exists := c.Get("validatedRequestBody")
+
+	if !exists {
+		helpers.ReturnError(c, "Error parsing request", fmt.Errorf(helpers.INVALID_REQUEST_BODY), http.StatusBadRequest)
+		return
+	}
+
+	input, ok := validatedReqBody.(TwoFactorVerifyInput)
+	if !ok {
+		helpers.ReturnError(c, "Error parsing request", fmt.Errorf(helpers.REQUEST_BODY_PARSE_ERROR), http.StatusBadRequest)
+		return
+	}
+
+	a.completeTwoFactor(c, input.Token, models.TwoFactorMethod(input.Method), input.OTP, input.EmailOTP)
+}
+
+// completeTwoFactor checks the second factor for the pending login token and
+// starts a session when it is valid. A step up token also needs emailCode
+// unless the second factor is the emailed code itself.
+func (a *AuthHandler) completeTwoFactor(c *gin.Context, token string, method models.TwoFactorMethod, code, emailCode string) {
+	claims, err := helpers.ValidateTwoFactorToken(token)
+	if err != nil {
+		helpers.ReturnError(c, "Login token is not valid", err, http.StatusUnauthorized)
+		return
+	}
+
+	user, found, err := a.deps.UserRepo.FindByCondition("id = ?", claims.UserId)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	if !found {
+		helpers.ReturnError(c, "Something went wrong", fmt.Errorf("user not found"), http.StatusNotFound)
+		return
+	}
+
+	if ipBlocked(c, a.deps) || accountLocked(c, user) {
+		return
+	}
+
+	methods := user.TwoFactorMethods()
+	if method == "" && len(methods) > 0 {
+		method = methods[0]
+	}
+	if method == "" && claims.StepUp {
+		method = models.EmailOTPMethod
+	}
+
+	if claims.StepUp && method != models.EmailOTPMethod {
+		if emailCode == "" {
+			helpers.ReturnError(c, "Enter the code sent to your email as well", errEmailCode, http.StatusBadRequest)
+			return
+		}
+
+		outcome, err := a.deps.OTPManager.VerifyOTP(user.Email, otp.LoginOTP, emailCode)
+		if err != nil {
+			helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+			return
+		}
+
+		if outcome != otp.OutcomeOK {
+			recordLoginEvent(c, a.deps, user, models.LoginEvent{Type: models.LoginFailed, Method: string(models.EmailOTPMethod), Reason: "invalid_code"})
+			recordLoginFailure(c, a.deps, user)
+			otpRejected(c, outcome)
+			return
+		}
+	}
+
+	var valid bool
+
+	switch method {
+	case models.TOTPMethod:
+		if !user.TOTPEnabled {
+			helpers.ReturnError(c, "Authenticator app is not enabled", fmt.Errorf("totp not enabled"), http.StatusBadRequest)
+			return
+		}
+
+		valid, err = verifyTOTPCode(a.deps, user, code)
+		if err != nil {
+			helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+			return
+		}
+	case models.EmailOTPMethod:
+		if !user.EmailOTPEnabled && !claims.StepUp {
+			helpers.ReturnError(c, "Email codes are not enabled", fmt.Errorf("email otp not enabled"), http.StatusBadRequest)
+			return
+		}
+
+		outcome, err := a.deps.OTPManager.VerifyOTP(user.Email, otp.LoginOTP, code)
+		if err != nil {
+			helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+			return
+		}
+
+		if outcome != otp.OutcomeOK {
+			recordLoginEvent(c, a.deps, user, models.LoginEvent{Type: models.LoginFailed, Method: string(models.EmailOTPMethod), Reason: "invalid_code"})
+			recordLoginFailure(c, a.deps, user)
+			otpRejected(c, outcome)
+			return
+		}
+		valid = true
+	case models.RecoveryCodeMethod:
+		valid, err = a.deps.RecoveryCodeRepo.Consume(user.ID, recovery.Hash(user.ID.String(), code))
+		if err != nil {
+			helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+			return
+		}
+
+		if valid {
+			remaining, err := a.deps.RecoveryCodeRepo.CountUnused(user.ID)
+			if err != nil {
+				helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+				return
+			}
+
+			go a.deps.EmailService.SendRecoveryCodeUsedEmail(user.FirstName, user.Email, remaining)
+		}
+	default:
+		helpers.ReturnError(c, "Unknown two factor method", fmt.Errorf("unknown method %q", method), http.StatusBadRequest)
+		return
+	}
+
+	if !valid {
+		recordLoginEvent(c, a.deps, user, models.LoginEvent{Type: models.LoginFailed, Method: string(method), Reason: "invalid_code"})
+		recordLoginFailure(c, a.deps, user)
+		helpers.ReturnJSON(c, "OTP not valid", nil, http.StatusBadRequest)
+		return
+	}
+
+	if err := unlockAccount(a.deps, user); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	accessToken, err := issueTokenPair(c, a.deps, user.Email, user.FirstName, user.ID.String(), nil)
+	if errors.Is(err, errPasswordResetRequired) {
+		helpers.ReturnError(c, "Password reset required", err, http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		helpers.ReturnError(c, "Could not generate token", err, http.StatusInternalServerError)
+		return
+	}
+	recordLoginEvent(c, a.deps, user, models.LoginEvent{Type: models.LoginSucceeded, Method: string(method)})
+
+	response := map[string]interface{}{
+		"access_token": accessToken,
+		"expires_in":   time.Now().Local().Add(helpers.AccessTokenTTL),
+	}
+
+	// Email codes are on from sign up, so most users first get their
+	// recovery codes here rather than when setting up an authenticator app.
+	codes, err := issueFirstRecoveryCodes(a.deps, user)
+	if err != nil {
+		log.Printf("could not issue recovery codes to %s: %v", user.ID, err)
+	} else if codes != nil {
+		response["recovery_codes"] = codes
+	}
+
+	helpers.ReturnJSON(c, "Authenticated successfully", response, http.StatusOK)
+}
+
+// authenticatedUser loads the user behind the access token, writing the
+// error response itself when it cannot.
+func (u *UserHandler) authenticatedUser(c *gin.Context) (*models.User, bool) {
+	return loadAuthenticatedUser(c, u.deps)
+}
+
+// loadAuthenticatedUser is authenticatedUser for handlers that are not on
+// UserHandler.
+func loadAuthenticatedUser(c *gin.Context, deps *bootstrap.AppDependencies) (*models.User, bool) {
+	claims, err := helpers.GetAuthenticatedUser(c)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return nil, false
+	}
+
+	user, found, err := deps.UserRepo.FindByCondition("id = ?", claims.UserId)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return nil, false
+	}
+
+	if !found {
+		helpers.ReturnError(c, "Something went wrong", fmt.Errorf("user not found"), http.StatusNotFound)
+		return nil, false
+	}
+
+	return user, true
+}
+
+// SetupTOTP is a route handler that starts authenticator app enrollment.
+//
+// This endpoint creates a new secret and returns it as an otpauth:// URI and a QR code.
+// The secret only becomes active once confirmed with a code.
+//
+// @Summary Start authenticator app setup
+// @Description Returns a TOTP secret, otpauth:// URI and QR code PNG
+// @Tags User
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} totp.Enrollment
+// @Failure 401 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /user/2fa/totp/setup [post]
+func (u *UserHandler) SetupTOTP(c *gin.Context) {
+	user, ok := u.authenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	if user.TOTPEnabled {
+		helpers.ReturnError(c, "Authenticator app already enabled", fmt.Errorf("totp already enabled"), http.StatusConflict)
+		return
+	}
+
+	enrollment, err := totp.Generate(constant.TOTPIssuer, user.Email)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	secret, err := helpers.EncryptString(enrollment.Secret)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	user.TOTPSecret = secret
+	user.TOTPLastStep = 0
+
+	if err := u.deps.UserRepo.UpdateColumns(user, "TOTPSecret", "TOTPLastStep"); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	helpers.ReturnJSON(c, "Scan the QR code with your authenticator app", enrollment, http.StatusOK)
+}
+
+// ConfirmTOTP is a route handler that finishes authenticator app enrollment.
+//
+// @Summary Confirm authenticator app setup
+// @Description Enables TOTP once the user proves the app produces valid codes and returns a new set of recovery codes
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param credentials body OtpInput true "Code from the authenticator app"
+// @Security BearerAuth
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Router /user/2fa/totp/confirm [post]
+func (u *UserHandler) ConfirmTOTP(c *gin.Context) {
+	var input OtpInput
+
+	validatedReqBody, exists := c.Get("validatedRequestBody")
+
+	if !exists {
+		helpers.ReturnError(c, "Error parsing request", fmt.Errorf(helpers.INVALID_REQUEST_BODY), http.StatusBadRequest)
+		return
+	}
+
+	input, ok := validatedReqBody.(OtpInput)
+	if !ok {
+		helpers.ReturnError(c, "Error parsing request", fmt.Errorf(helpers.REQUEST_BODY_PARSE_ERROR), http.StatusBadRequest)
+		return
+	}
+
+	user, ok := u.authenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	if user.TOTPEnabled {
+		helpers.ReturnError(c, "Authenticator app already enabled", fmt.Errorf("totp already enabled"), http.StatusConflict)
+		return
+	}
+
+	if user.TOTPSecret == "" {
+		helpers.ReturnError(c, "Start the authenticator app setup first", fmt.Errorf("no pending totp enrollment"), http.StatusBadRequest)
+		return
+	}
+
+	valid, err := verifyTOTPCode(u.deps, user, input.OTP)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	if !valid {
+		helpers.ReturnJSON(c, "OTP not valid", nil, http.StatusBadRequest)
+		return
+	}
+
+	user.TOTPEnabled = true
+
+	if err := u.deps.UserRepo.UpdateColumns(user, "TOTPEnabled"); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	codes, err := issueRecoveryCodes(u.deps, user)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	helpers.ReturnJSON(c, "Authenticator app enabled", map[string]interface{}{
+		"recovery_codes": codes,
+	}, http.StatusOK)
+}
+
+// DisableTOTP is a route handler that removes the authenticator app.
+//
+// Email codes are turned back on so the account keeps a second factor.
+//
+// @Summary Disable authenticator app
+// @Description Disables TOTP after checking a current code
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param credentials body OtpInput true "Code from the authenticator app"
+// @Security BearerAuth
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
+// @Router /user/2fa/totp [delete]
+func (u *UserHandler) DisableTOTP(c *gin.Context) {
+	var input OtpInput
+
+	validatedReqBody, exists := c.Get("validatedRequestBody")
+
+	if !exists {
+		helpers.ReturnError(c, "Error parsing request", fmt.Errorf(helpers.INVALID_REQUEST_BODY), http.StatusBadRequest)
+		return
+	}
+
+	input, ok := validatedReqBody.(OtpInput)
+	if !ok {
+		helpers.ReturnError(c, "Error parsing request", fmt.Errorf(helpers.REQUEST_BODY_PARSE_ERROR), http.StatusBadRequest)
+		return
+	}
+
+	user, ok := u.authenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	if !user.TOTPEnabled {
+		helpers.ReturnError(c, "Authenticator app is not enabled", fmt.Errorf("totp not enabled"), http.StatusBadRequest)
+		return
+	}
+
+	if stepUpBlocked(c, u.deps, user) {
+		return
+	}
+
+	valid, err := verifyTOTPCode(u.deps, user, input.OTP)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	if !valid {
+		recordStepUpFailure(u.deps, user)
+		helpers.ReturnJSON(c, "OTP not valid", nil, http.StatusBadRequest)
+		return
+	}
+
+	resetStepUpFailures(u.deps, user)
+
+	user.TOTPEnabled = false
+	user.TOTPSecret = ""
+	user.TOTPLastStep = 0
+	user.EmailOTPEnabled = true
+
+	if err := u.deps.UserRepo.UpdateColumns(user, "TOTPEnabled", "TOTPSecret", "TOTPLastStep", "EmailOTPEnabled"); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	helpers.ReturnJSON(c, "Authenticator app disabled", nil, http.StatusOK)
+}
+
+// UpdateTwoFactorPreferences is a route handler that updates which second factors the user accepts.
+//
+// @Summary Update two factor preferences
+// @Description Turns emailed login codes on or off. At least one method must stay enabled. Turning codes on returns recovery codes if the user has never had any.
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param credentials body TwoFactorPreferencesInput true "Preferences"
+// @Security BearerAuth
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Router /user/2fa [put]
+func (u *UserHandler) UpdateTwoFactorPreferences(c *gin.Context) {
+	var input TwoFactorPreferencesInput
+
+	validatedReqBody, exists := c.Get("validatedRequestBody")
+
+	if !exists {
+		helpers.ReturnError(c, "Error parsing request", fmt.Errorf(helpers.INVALID_REQUEST_BODY), http.StatusBadRequest)
+		return
+	}
+
+	input, ok := validatedReqBody.(TwoFactorPreferencesInput)
+	if !ok {
+		helpers.ReturnError(c, "Error parsing request", fmt.Errorf(helpers.REQUEST_BODY_PARSE_ERROR), http.StatusBadRequest)
+		return
+	}
+
+	user, ok := u.authenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	if !*input.EmailOTPEnabled && !user.TOTPEnabled {
+		helpers.ReturnError(c, "At least one two factor method must stay enabled", fmt.Errorf("no two factor method left"), http.StatusBadRequest)
+		return
+	}
+
+	user.EmailOTPEnabled = *input.EmailOTPEnabled
+
+	if err := u.deps.UserRepo.UpdateColumns(user, "EmailOTPEnabled"); err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"methods": user.TwoFactorMethods(),
+	}
+
+	if user.EmailOTPEnabled {
+		codes, err := issueFirstRecoveryCodes(u.deps, user)
+		if err != nil {
+			helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+			return
+		}
+		if codes != nil {
+			response["recovery_codes"] = codes
+		}
+	}
+
+	helpers.ReturnJSON(c, "Two factor preferences updated", response, http.StatusOK)
+}
+
+// RecoveryCodesStatus is a route handler that reports how many recovery codes the user has left.
+//
+// @Summary Recovery code status
+// @Description Returns the number of unused recovery codes
+// @Tags User
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} SuccessResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /user/2fa/recovery-codes [get]
+func (u *UserHandler) RecoveryCodesStatus(c *gin.Context) {
+	user, ok := u.authenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	remaining, err := u.deps.RecoveryCodeRepo.CountUnused(user.ID)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	helpers.ReturnJSON(c, "Recovery codes retrieved", map[string]interface{}{
+		"remaining": remaining,
+	}, http.StatusOK)
+}
+
+// RegenerateRecoveryCodes is a route handler that replaces the user's recovery codes.
+//
+// The request must be confirmed with the current password or a fresh TOTP or emailed code.
+// Previously issued codes stop working.
+//
+// @Summary Regenerate recovery codes
+// @Description Issues ten new single use recovery codes
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param credentials body ReauthenticateInput true "Password or OTP"
+// @Security BearerAuth
+// @Success 200 {object} SuccessResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
+// @Router /user/2fa/recovery-codes [post]
+func (u *UserHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var input ReauthenticateInput
+
+	validatedReqBody, exists := c.Get("validatedRequestBody")
+
+	if !exists {
+		helpers.ReturnError(c, "Error parsing request", fmt.Errorf(helpers.INVALID_REQUEST_BODY), http.StatusBadRequest)
+		return
+	}
+
+	input, ok := validatedReqBody.(ReauthenticateInput)
+	if !ok {
+		helpers.ReturnError(c, "Error parsing request", fmt.Errorf(helpers.REQUEST_BODY_PARSE_ERROR), http.StatusBadRequest)
+		return
+	}
+
+	user, ok := u.authenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	if !confirmStepUp(c, u.deps, user, input) {
+		return
+	}
+
+	codes, err := issueRecoveryCodes(u.deps, user)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+		return
+	}
+
+	helpers.ReturnJSON(c, "Recovery codes generated", map[string]interface{}{
+		"recovery_codes": codes,
+	}, http.StatusOK)
+}
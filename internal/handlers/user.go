@@ -27,7 +27,7 @@ func NewUserHandler(deps *bootstrap.AppDependencies,
 ) *UserHandler {
 	return &UserHandler{
 		deps:          deps,
-		streamManager: manager.NewGameManager(),
+		streamManager: deps.StreamManager,
 	}
 }
 
@@ -47,7 +47,6 @@ type UserProfile struct {
 }
 
 type UpdateUserProfileInput struct {
-	Email       string `json:"email" validate:"required"`
 	FirstName   string `json:"first_name" validate:"required"`
 	LastName    string `json:"last_name" validate:"required"`
 	Bio         string `json:"bio" validate:"required"`
@@ -59,14 +58,14 @@ type UpdateRoleInput struct {
 }
 
 type UserListResponse struct {
-	Message int               `json:"message"`
-	Success bool              `json:"success"`
+	Message string            `json:"message"`
+	Status  bool              `json:"status"`
 	Data    []models.UserInfo `json:"data"`
 }
 
 type UserResponse struct {
-	Message int             `json:"message"`
-	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Status  bool            `json:"status"`
 	Data    models.UserInfo `json:"data"`
 }
 
@@ -100,31 +99,17 @@ func (u *UserHandler) UpdateUserProfile(c *gin.Context) {
 		return
 	}
 
-	claims, err := helpers.GetAuthenticatedUser(c)
-	if err != nil {
-		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
-		return
-	}
-
-	user, found, err := u.deps.UserRepo.FindByCondition("email = ?", claims.Email)
-	if err != nil {
-		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
-		return
-	}
-
-	if !found {
-		helpers.ReturnError(c, "Something went wrong", fmt.Errorf("user not found"), http.StatusNotFound)
+	user, ok := u.authenticatedUser(c)
+	if !ok {
 		return
 	}
 
 	user.FirstName = input.FirstName
 	user.LastName = input.LastName
-	user.Email = input.Email
 	user.Bio = input.Bio
 	user.PhoneNumber = input.PhoneNumber
 
-	_, err = u.deps.UserRepo.Save(user)
-	if err != nil {
+	if _, err := u.deps.UserRepo.Save(user); err != nil {
 		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
 		return
 	}
@@ -160,27 +145,14 @@ func (u *UserHandler) UpdateUserPhoto(c *gin.Context) {
 		return
 	}
 
-	claims, err := helpers.GetAuthenticatedUser(c)
-	if err != nil {
-		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
-		return
-	}
-
-	user, found, err := u.deps.UserRepo.FindByCondition("email = ?", claims.Email)
-	if err != nil {
-		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
-		return
-	}
-
-	if !found {
-		helpers.ReturnError(c, "Something went wrong", fmt.Errorf("user not found"), http.StatusNotFound)
+	user, ok := u.authenticatedUser(c)
+	if !ok {
 		return
 	}
 
 	user.Photo = imageUrl
 
-	_, err = u.deps.UserRepo.Save(user)
-	if err != nil {
+	if _, err := u.deps.UserRepo.Save(user); err != nil {
 		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
 		return
 	}
@@ -243,26 +215,20 @@ func (u *UserHandler) UserProfile(c *gin.Context) {
 // @Success 200 {object} SuccessResponse
 // @Failure 401 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse "Retry-After gives the seconds until a new code can be sent"
 // @Router /user/otp [post]
 func (a *UserHandler) SendOTP(c *gin.Context) {
-	claims, err := helpers.GetAuthenticatedUser(c)
-	if err != nil {
-		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
-		return
-	}
-
-	user, found, err := a.deps.UserRepo.FindByCondition("email = ?", claims.Email)
-	if err != nil {
-		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+	user, ok := a.authenticatedUser(c)
+	if !ok {
 		return
 	}
 
-	if !found {
-		helpers.ReturnError(c, "Something went wrong", fmt.Errorf("user not found"), http.StatusNotFound)
+	code, ok := issueOTP(c, a.deps, user.Email, otp.StepUpOTP)
+	if !ok {
 		return
 	}
 
-	go a.deps.EmailService.SendOTPEmail(user.FirstName, user.Email)
+	go a.deps.EmailService.SendOTPEmail(user.FirstName, user.Email, code)
 
 	helpers.ReturnJSON(c, "OTP sent successfully", nil, http.StatusOK)
 }
@@ -297,27 +263,12 @@ func (a *UserHandler) VerifyOTP(c *gin.Context) {
 		return
 	}
 
-	claims, err := helpers.GetAuthenticatedUser(c)
-	if err != nil {
-		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
-		return
-	}
-
-	user, found, err := a.deps.UserRepo.FindByCondition("email = ?", claims.Email)
-	if err != nil {
-		helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
-		return
-	}
-
-	if !found {
-		helpers.ReturnError(c, "Something went wrong", fmt.Errorf("user not found"), http.StatusNotFound)
+	user, ok := a.authenticatedUser(c)
+	if !ok {
 		return
 	}
 
-	valid := otp.OTPManage.VerifyOTP(user.Email, input.OTP)
-
-	if !valid {
-		helpers.ReturnJSON(c, "OTP not valid", nil, http.StatusBadRequest)
+	if !checkOTP(c, a.deps, user.Email, otp.StepUpOTP, input.OTP) {
 		return
 	}
 
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/bjorndonald/golang-backend-template/internal/bootstrap"
+	"github.com/gin-gonic/gin"
+)
+
+type WellKnownHandler struct {
+	deps *bootstrap.AppDependencies
+}
+
+func NewWellKnownHandler(deps *bootstrap.AppDependencies) *WellKnownHandler {
+	return &WellKnownHandler{deps: deps}
+}
+
+// JWKS is a route handler that publishes the public keys tokens are signed with.
+//
+// Other services use it to verify access tokens without sharing a secret.
+// Tokens signed with the HMAC secret cannot be verified this way.
+//
+// @Summary JSON Web Key Set
+// @Description Lists the public keys that verify issued tokens, matched by the kid header
+// @Tags Authentication
+// @Produce json
+// @Success 200 {object} keys.JWKSet
+// @Router /.well-known/jwks.json [get]
+func (w *WellKnownHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, w.deps.Keys.JWKS())
+}
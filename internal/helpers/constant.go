@@ -1,6 +1,20 @@
 package helpers
 
+import "time"
+
 const (
 	INVALID_REQUEST_BODY     = "invalid request body"
 	REQUEST_BODY_PARSE_ERROR = "request body parse error"
 )
+
+const (
+	AccessTokenTTL  = time.Minute * 15
+	RefreshTokenTTL = time.Hour
+	MagicLinkTTL    = time.Minute * 15
+	ResetTokenTTL   = time.Minute * 15
+	TwoFactorTTL    = time.Minute * 10
+	ImpersonateTTL  = time.Minute * 10
+	EmailChangeTTL  = time.Hour
+	EmailRevertTTL  = time.Hour * 24 * 7
+	DeviceTTL       = time.Minute * 30
+)
@@ -0,0 +1,71 @@
+package helpers
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+)
+
+var errEncryptionKeyMissing = errors.New("encryption key is not configured")
+
+func newGCM(key string) (cipher.AEAD, error) {
+	if key == "" {
+		return nil, errEncryptionKeyMissing
+	}
+
+	// The configured key can be any passphrase, it is stretched to the
+	// 32 bytes AES-256 expects.
+	sum := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// EncryptString encrypts a secret with AES-GCM using the configured
+// encryption key. The nonce is prepended to the base64 encoded output.
+func EncryptString(plaintext string) (string, error) {
+	gcm, err := newGCM(constant.EncryptionKey)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptString reverses EncryptString.
+func DecryptString(ciphertext string) (string, error) {
+	gcm, err := newGCM(constant.EncryptionKey)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", err
+	}
+
+	if len(data) < gcm.NonceSize() {
+		return "", errors.New("ciphertext too short")
+	}
+
+	nonce, sealed := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
@@ -0,0 +1,35 @@
+package helpers
+
+import "testing"
+
+func TestEncryptString(t *testing.T) {
+	constant.EncryptionKey = "test-encryption-key"
+
+	ciphertext, err := EncryptString("JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+
+	if ciphertext == "JBSWY3DPEHPK3PXP" {
+		t.Errorf("Expected ciphertext to differ from plaintext")
+	}
+
+	plaintext, err := DecryptString(ciphertext)
+	if err != nil {
+		t.Fatalf("Failed to decrypt: %v", err)
+	}
+
+	if plaintext != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("Expected JBSWY3DPEHPK3PXP, got %s", plaintext)
+	}
+
+	constant.EncryptionKey = "another-key"
+	if _, err := DecryptString(ciphertext); err == nil {
+		t.Errorf("Expected decryption with the wrong key to fail")
+	}
+
+	constant.EncryptionKey = ""
+	if _, err := EncryptString("secret"); err == nil {
+		t.Errorf("Expected encryption without a key to fail")
+	}
+}
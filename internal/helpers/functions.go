@@ -3,31 +3,32 @@ package helpers
 import (
 	"bytes"
 	"context"
-	"encoding/json"
-	"errors"
 	"fmt"
 	"html/template"
 	"log"
-	"net/http"
 
 	// "mime/multipart"
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"math/big"
 
 	"github.com/bjorndonald/golang-backend-template/constants"
+	"github.com/bjorndonald/golang-backend-template/internal/geoip"
 	"github.com/bjorndonald/golang-backend-template/internal/models"
+	"github.com/bjorndonald/golang-backend-template/internal/passwords"
 	"github.com/cloudinary/cloudinary-go"
 	"github.com/cloudinary/cloudinary-go/api/admin"
 	"github.com/cloudinary/cloudinary-go/api/uploader"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt"
 	"github.com/mssola/user_agent"
-	"golang.org/x/crypto/bcrypt"
 )
 
 const (
@@ -165,25 +166,9 @@ func ReturnError(c *gin.Context, message string, err error, status int) {
 	log.Println("message: ", message)
 }
 
+// HashPassword hashes password with the configured password hasher.
 func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 4)
-	return string(bytes), err
-}
-
-func ValidateToken(tokenString string) (*AuthTokenJwtClaim, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &AuthTokenJwtClaim{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(constant.JWTSecretKey), nil
-	})
-	if err != nil {
-		return nil, err
-	}
-
-	claims, ok := token.Claims.(*AuthTokenJwtClaim)
-	if !ok || !token.Valid {
-		return nil, errors.New("invalid token")
-	}
-
-	return claims, nil
+	return passwords.CurrentHasher().Hash(password)
 }
 
 func GeneratePassword() (string, error) {
@@ -210,42 +195,25 @@ func GeneratePassword() (string, error) {
 	return token, nil
 }
 
-// GenerateToken generates a jwt token
-func GenerateAccessToken(JWTSecretKey, email, name, userid string) (signedToken string, err error) {
-	claims := &AuthTokenJwtClaim{
-		Email:  email,
-		Name:   name,
-		UserId: userid,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Local().Add(time.Minute * 15).Unix(),
-			IssuedAt:  time.Now().Unix(),
-		},
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err = token.SignedString([]byte(JWTSecretKey))
-	if err != nil {
-		return
-	}
-	return
+// HashToken returns the hex encoded SHA-256 digest of a token. It is used to
+// store high-entropy secrets such as refresh tokens without keeping them in
+// plain text.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
 
-// GenerateToken generates a jwt token
-func GenerateRefreshToken(JWTSecretKey, email, name, userid string) (signedToken string, err error) {
-	claims := &AuthTokenJwtClaim{
-		Email:  email,
-		Name:   name,
-		UserId: userid,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Local().Add(time.Hour).Unix(),
-			IssuedAt:  time.Now().Unix(),
-		},
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err = token.SignedString([]byte(JWTSecretKey))
-	if err != nil {
-		return
-	}
-	return
+// DeviceFingerprint identifies a device from its device cookie and the parts
+// of its user agent that do not change when the browser updates.
+func DeviceFingerprint(deviceID string, agent models.UserAgent) string {
+	return HashToken(strings.Join([]string{
+		deviceID,
+		agent.Platform,
+		agent.OS,
+		agent.BrowserName,
+		agent.Model,
+		strconv.FormatBool(agent.Mobile),
+	}, "|"))
 }
 
 func ParseTemplateFile(filename string, mapping interface{}) (string, error) {
@@ -315,6 +283,9 @@ func GetBaseURL(c *gin.Context) string {
 	return baseURL
 }
 
+// GetAuthenticatedUser returns the claims JWTMiddleware accepted. When an
+// admin is impersonating the user, claims.Act names the admin and handlers
+// can refuse anything the admin should not do on the user's behalf.
 func GetAuthenticatedUser(c *gin.Context) (*AuthTokenJwtClaim, error) {
 
 	var claims *AuthTokenJwtClaim
@@ -334,15 +305,27 @@ func GetAuthenticatedUser(c *gin.Context) (*AuthTokenJwtClaim, error) {
 	return claims, nil
 }
 
-func GetDeviceLocation(c *gin.Context) (models.GeoLocation, models.UserAgent, error) {
-	var location models.GeoLocation
-	var agent models.UserAgent
+// GetAPIToken returns the personal access token or service key the request
+// was authenticated with. It reports false for requests made with a session.
+func GetAPIToken(c *gin.Context) (*models.APIToken, bool) {
+	value, exists := c.Get("apiToken")
+	if !exists {
+		return nil, false
+	}
+
+	token, ok := value.(*models.APIToken)
+	return token, ok
+}
+
+// GetDeviceLocation describes the requesting device and resolves where the
+// client address is. The device is returned even when the lookup fails.
+func GetDeviceLocation(c *gin.Context, resolver geoip.GeoResolver) (models.GeoLocation, models.UserAgent, error) {
 	userAgent := c.GetHeader("User-Agent")
 
 	ua := user_agent.New(userAgent)
 	browserName, _ := ua.Browser()
 
-	agent = models.UserAgent{
+	agent := models.UserAgent{
 		Platform:    ua.Platform(),
 		OS:          ua.OS(),
 		BrowserName: browserName,
@@ -350,25 +333,18 @@ func GetDeviceLocation(c *gin.Context) (models.GeoLocation, models.UserAgent, er
 		Model:       ua.Model(),
 	}
 
-	response, err := http.Get("https://api.ipify.org?format=json")
-	if err != nil {
-		return location, agent, err
-	}
-	defer response.Body.Close()
-
-	var result map[string]string
-	json.NewDecoder(response.Body).Decode(&result)
-
-	clientIP := result["ip"]
+	location, err := resolver.Resolve(c.ClientIP())
+	return location, agent, err
+}
 
-	response, err = http.Get("https://ipinfo.io/" + clientIP + "/json")
-	if err != nil {
-		return location, agent, err
+// GetPermissions returns the permissions JWTMiddleware resolved for the
+// request.
+func GetPermissions(c *gin.Context) []string {
+	value, exists := c.Get("permissions")
+	if !exists {
+		return nil
 	}
-	defer response.Body.Close()
 
-	if err := json.NewDecoder(response.Body).Decode(&location); err != nil {
-		return location, agent, err
-	}
-	return location, agent, nil
+	permissions, _ := value.([]string)
+	return permissions
 }
@@ -0,0 +1,28 @@
+package helpers
+
+import (
+	"testing"
+
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+)
+
+func TestDeviceFingerprint(t *testing.T) {
+	chrome := models.UserAgent{Platform: "Windows", OS: "Windows 10", BrowserName: "Chrome"}
+
+	fingerprint := DeviceFingerprint("device-id", chrome)
+	if fingerprint != DeviceFingerprint("device-id", chrome) {
+		t.Fatal("fingerprint is not stable")
+	}
+
+	// The cookie alone does not make the device: copying it into another
+	// browser gives another fingerprint.
+	firefox := chrome
+	firefox.BrowserName = "Firefox"
+	if DeviceFingerprint("device-id", firefox) == fingerprint {
+		t.Error("fingerprint ignores the browser")
+	}
+
+	if DeviceFingerprint("other-device", chrome) == fingerprint {
+		t.Error("fingerprint ignores the device cookie")
+	}
+}
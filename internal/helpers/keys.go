@@ -0,0 +1,70 @@
+package helpers
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/bjorndonald/golang-backend-template/constants"
+	"github.com/bjorndonald/golang-backend-template/internal/keys"
+)
+
+var (
+	keyManager     *keys.Manager
+	keyManagerOnce sync.Once
+)
+
+// KeyManager returns the keys tokens are signed and verified with. They are
+// loaded from the config the first time they are needed.
+func KeyManager() *keys.Manager {
+	keyManagerOnce.Do(func() {
+		m, err := LoadKeyManager(constant)
+		if err != nil {
+			log.Fatalf("error loading signing keys: %v", err)
+		}
+		keyManager = m
+	})
+	return keyManager
+}
+
+// SetKeyManager replaces the keys tokens are signed and verified with.
+// Tests use it to sign with a key of their own instead of the config's.
+func SetKeyManager(m *keys.Manager) {
+	keyManagerOnce.Do(func() {})
+	keyManager = m
+}
+
+// LoadKeyManager builds a key manager from the config. The JWT secret, when
+// set, is kept as the key for tokens without a kid so that tokens issued
+// before key IDs existed stay valid. Every PEM key in JWT_KEYS_DIR verifies
+// tokens and JWT_SIGNING_KEY_ID picks the one that signs new tokens.
+func LoadKeyManager(cfg *constants.Config) (*keys.Manager, error) {
+	m := keys.NewManager()
+
+	if cfg.JWTSecretKey != "" {
+		m.Add(keys.NewHMACKey(cfg.JWTSecretKeyID, []byte(cfg.JWTSecretKey)))
+		if err := m.SetFallbackKey(cfg.JWTSecretKeyID); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.JWTKeysDir != "" {
+		loaded, err := keys.LoadDir(cfg.JWTKeysDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range loaded {
+			m.Add(key)
+		}
+	}
+
+	signingKeyID := cfg.JWTSigningKeyID
+	if signingKeyID == "" {
+		signingKeyID = cfg.JWTSecretKeyID
+	}
+
+	if err := m.SetSigningKey(signingKeyID); err != nil {
+		return nil, fmt.Errorf("JWT_SIGNING_KEY_ID: %w", err)
+	}
+	return m, nil
+}
@@ -13,10 +13,56 @@ type IError struct {
 	Value string
 }
 
+// TokenType says what a token may be used for. Every consumer checks it, so
+// a token minted for one step of a flow cannot be used for another.
+type TokenType string
+
+const (
+	AccessTokenType      TokenType = "access"
+	RefreshTokenType     TokenType = "refresh"
+	ResetTokenType       TokenType = "reset"
+	TwoFactorTokenType   TokenType = "2fa_pending"
+	MagicLinkTokenType   TokenType = "magic_link"
+	EmailChangeTokenType TokenType = "email_change"
+	EmailRevertTokenType TokenType = "email_revert"
+	DeviceTokenType      TokenType = "device_approval"
+)
+
 type AuthTokenJwtClaim struct {
-	Email  string
-	Name   string
+	Email     string
+	Name      string
+	UserId    string
+	SessionId string    `json:",omitempty"`
+	Type      TokenType `json:"typ"`
+	// Act is set on impersonation tokens and names the admin behind them.
+	Act *Actor `json:"act,omitempty"`
+	// StepUp is set on pending login tokens for logins that looked risky.
+	// They also need a code sent to the user's email address.
+	StepUp bool `json:"step_up,omitempty"`
+	jwt.StandardClaims
+}
+
+// Actor is the act claim of RFC 8693: the party acting on behalf of the
+// token's subject.
+type Actor struct {
+	Sub   string `json:"sub"`
+	Email string `json:"email,omitempty"`
+}
+
+// Impersonating reports whether the token was issued to an admin acting as
+// the user, in which case Act says who the admin is.
+func (c *AuthTokenJwtClaim) Impersonating() bool {
+	return c.Act != nil
+}
+
+// MagicLinkClaim is carried by an emailed sign-in link. Nonce is the hash of
+// the cookie set on the browser that asked for the link and Code is the
+// single use code that was issued with it.
+type MagicLinkClaim struct {
 	UserId string
+	Nonce  string
+	Code   string
+	Type   TokenType `json:"typ"`
 	jwt.StandardClaims
 }
 
@@ -0,0 +1,263 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/golang-jwt/jwt"
+)
+
+var ErrWrongTokenType = errors.New("token is not valid for this use")
+
+// audience returns the aud claim for a token type. Only access tokens are
+// meant for other services, every other kind is addressed back to the
+// issuer so that nothing verifying through the JWKS accepts it.
+func audience(tokenType TokenType) string {
+	if tokenType == AccessTokenType {
+		return constant.JWTAudience
+	}
+	return constant.JWTIssuer
+}
+
+// standardClaims fills in the registered claims shared by every token. A
+// random jti is used unless tokenID is given.
+func standardClaims(tokenType TokenType, tokenID string, ttl time.Duration) (jwt.StandardClaims, error) {
+	if tokenID == "" {
+		id, err := uuid.NewV7()
+		if err != nil {
+			return jwt.StandardClaims{}, err
+		}
+		tokenID = id.String()
+	}
+
+	now := time.Now()
+	return jwt.StandardClaims{
+		Id:        tokenID,
+		Issuer:    constant.JWTIssuer,
+		Audience:  audience(tokenType),
+		ExpiresAt: now.Add(ttl).Unix(),
+		IssuedAt:  now.Unix(),
+	}, nil
+}
+
+func generateAuthToken(tokenType TokenType, ttl time.Duration, email, name, userid, sessionID, tokenID string) (string, *AuthTokenJwtClaim, error) {
+	standard, err := standardClaims(tokenType, tokenID, ttl)
+	if err != nil {
+		return "", nil, err
+	}
+
+	claims := &AuthTokenJwtClaim{
+		Email:          email,
+		Name:           name,
+		UserId:         userid,
+		SessionId:      sessionID,
+		Type:           tokenType,
+		StandardClaims: standard,
+	}
+
+	signed, err := KeyManager().Sign(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, claims, nil
+}
+
+// checkRegisteredClaims makes sure a token was issued here, for the audience
+// its type is sent to, and has a jti.
+func checkRegisteredClaims(claims jwt.StandardClaims, tokenType TokenType) error {
+	if !claims.VerifyIssuer(constant.JWTIssuer, true) {
+		return errors.New("token has the wrong issuer")
+	}
+	if !claims.VerifyAudience(audience(tokenType), true) {
+		return errors.New("token has the wrong audience")
+	}
+	if claims.Id == "" {
+		return errors.New("token has no id")
+	}
+	return nil
+}
+
+func validateAuthToken(tokenString string, tokenType TokenType) (*AuthTokenJwtClaim, error) {
+	token, err := KeyManager().Parse(tokenString, &AuthTokenJwtClaim{})
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*AuthTokenJwtClaim)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	if claims.Type != tokenType {
+		return nil, fmt.Errorf("%w: got a %q token", ErrWrongTokenType, claims.Type)
+	}
+
+	if err := checkRegisteredClaims(claims.StandardClaims, tokenType); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// GenerateAccessToken generates a jwt access token for a logged in session.
+func GenerateAccessToken(email, name, userid, sessionID string) (string, error) {
+	signed, _, err := generateAuthToken(AccessTokenType, AccessTokenTTL, email, name, userid, sessionID, "")
+	return signed, err
+}
+
+// GenerateRefreshToken generates a jwt refresh token. tokenID is stored as the
+// jti so the token can be matched to its server-side record.
+func GenerateRefreshToken(email, name, userid, tokenID string) (string, error) {
+	signed, _, err := generateAuthToken(RefreshTokenType, RefreshTokenTTL, email, name, userid, "", tokenID)
+	return signed, err
+}
+
+// GenerateTwoFactorToken generates the token handed out after the first
+// factor. It can only be traded for a session by passing the second factor.
+func GenerateTwoFactorToken(email, name, userid string) (string, error) {
+	signed, _, err := generateAuthToken(TwoFactorTokenType, TwoFactorTTL, email, name, userid, "", "")
+	return signed, err
+}
+
+// GenerateStepUpTwoFactorToken generates the pending login token for a risky
+// login. Whatever second factor the user passes, an emailed code is needed
+// as well.
+func GenerateStepUpTwoFactorToken(email, name, userid string) (string, error) {
+	standard, err := standardClaims(TwoFactorTokenType, "", TwoFactorTTL)
+	if err != nil {
+		return "", err
+	}
+
+	claims := &AuthTokenJwtClaim{
+		Email:          email,
+		Name:           name,
+		UserId:         userid,
+		Type:           TwoFactorTokenType,
+		StepUp:         true,
+		StandardClaims: standard,
+	}
+
+	return KeyManager().Sign(claims)
+}
+
+// GenerateResetToken generates the token that allows one password reset.
+func GenerateResetToken(email, name, userid string) (string, error) {
+	signed, _, err := generateAuthToken(ResetTokenType, ResetTokenTTL, email, name, userid, "", "")
+	return signed, err
+}
+
+// GenerateImpersonationToken generates an access token for userid that
+// carries actor in its act claim. It belongs to no session, so it cannot be
+// refreshed and simply runs out.
+func GenerateImpersonationToken(email, name, userid string, actor Actor) (string, *AuthTokenJwtClaim, error) {
+	standard, err := standardClaims(AccessTokenType, "", ImpersonateTTL)
+	if err != nil {
+		return "", nil, err
+	}
+
+	claims := &AuthTokenJwtClaim{
+		Email:          email,
+		Name:           name,
+		UserId:         userid,
+		Type:           AccessTokenType,
+		Act:            &actor,
+		StandardClaims: standard,
+	}
+
+	signed, err := KeyManager().Sign(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, claims, nil
+}
+
+func ValidateAccessToken(tokenString string) (*AuthTokenJwtClaim, error) {
+	return validateAuthToken(tokenString, AccessTokenType)
+}
+
+func ValidateRefreshToken(tokenString string) (*AuthTokenJwtClaim, error) {
+	return validateAuthToken(tokenString, RefreshTokenType)
+}
+
+func ValidateTwoFactorToken(tokenString string) (*AuthTokenJwtClaim, error) {
+	return validateAuthToken(tokenString, TwoFactorTokenType)
+}
+
+// ValidateResetToken checks a reset token. It does not check whether the
+// token was used already; the caller has to consume its jti.
+func ValidateResetToken(tokenString string) (*AuthTokenJwtClaim, error) {
+	return validateAuthToken(tokenString, ResetTokenType)
+}
+
+// GenerateEmailChangeToken generates the token in the link sent to the new
+// address. changeID is stored as the jti.
+func GenerateEmailChangeToken(newEmail, name, userid, changeID string) (string, error) {
+	signed, _, err := generateAuthToken(EmailChangeTokenType, EmailChangeTTL, newEmail, name, userid, "", changeID)
+	return signed, err
+}
+
+// GenerateEmailRevertToken generates the token in the undo link sent to the
+// old address. changeID is stored as the jti.
+func GenerateEmailRevertToken(oldEmail, name, userid, changeID string) (string, error) {
+	signed, _, err := generateAuthToken(EmailRevertTokenType, EmailRevertTTL, oldEmail, name, userid, "", changeID)
+	return signed, err
+}
+
+func ValidateEmailChangeToken(tokenString string) (*AuthTokenJwtClaim, error) {
+	return validateAuthToken(tokenString, EmailChangeTokenType)
+}
+
+func ValidateEmailRevertToken(tokenString string) (*AuthTokenJwtClaim, error) {
+	return validateAuthToken(tokenString, EmailRevertTokenType)
+}
+
+// GenerateDeviceToken generates the token in the link that approves a new
+// device. approvalID is stored as the jti.
+func GenerateDeviceToken(email, name, userid, approvalID string) (string, error) {
+	signed, _, err := generateAuthToken(DeviceTokenType, DeviceTTL, email, name, userid, "", approvalID)
+	return signed, err
+}
+
+func ValidateDeviceToken(tokenString string) (*AuthTokenJwtClaim, error) {
+	return validateAuthToken(tokenString, DeviceTokenType)
+}
+
+// GenerateMagicLinkToken signs the token put in an emailed sign-in link.
+func GenerateMagicLinkToken(userid, nonceHash, code string) (string, error) {
+	standard, err := standardClaims(MagicLinkTokenType, "", MagicLinkTTL)
+	if err != nil {
+		return "", err
+	}
+
+	return KeyManager().Sign(&MagicLinkClaim{
+		UserId:         userid,
+		Nonce:          nonceHash,
+		Code:           code,
+		Type:           MagicLinkTokenType,
+		StandardClaims: standard,
+	})
+}
+
+func ValidateMagicLinkToken(tokenString string) (*MagicLinkClaim, error) {
+	token, err := KeyManager().Parse(tokenString, &MagicLinkClaim{})
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*MagicLinkClaim)
+	if !ok || !token.Valid || claims.Nonce == "" || claims.Code == "" {
+		return nil, errors.New("invalid token")
+	}
+
+	if claims.Type != MagicLinkTokenType {
+		return nil, fmt.Errorf("%w: got a %q token", ErrWrongTokenType, claims.Type)
+	}
+
+	if err := checkRegisteredClaims(claims.StandardClaims, MagicLinkTokenType); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
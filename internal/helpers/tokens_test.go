@@ -0,0 +1,213 @@
+package helpers
+
+import (
+	"errors"
+	"testing"
+)
+
+func useTestKeys(t *testing.T) {
+	constant.JWTSecretKey = "test-secret"
+	constant.JWTSecretKeyID = "test"
+	constant.JWTKeysDir = ""
+	constant.JWTSigningKeyID = ""
+
+	m, err := LoadKeyManager(constant)
+	if err != nil {
+		t.Fatalf("LoadKeyManager: %v", err)
+	}
+	SetKeyManager(m)
+}
+
+func TestTokensOnlyValidateAsTheirOwnType(t *testing.T) {
+	useTestKeys(t)
+
+	access, err := GenerateAccessToken("a@b.c", "A", "user", "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refresh, err := GenerateRefreshToken("a@b.c", "A", "user", "refresh-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending, err := GenerateTwoFactorToken("a@b.c", "A", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reset, err := GenerateResetToken("a@b.c", "A", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	change, err := GenerateEmailChangeToken("new@b.c", "A", "user", "change-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	revert, err := GenerateEmailRevertToken("a@b.c", "A", "user", "change-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	device, err := GenerateDeviceToken("a@b.c", "A", "user", "approval-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokens := map[TokenType]string{
+		AccessTokenType:      access,
+		RefreshTokenType:     refresh,
+		TwoFactorTokenType:   pending,
+		ResetTokenType:       reset,
+		EmailChangeTokenType: change,
+		EmailRevertTokenType: revert,
+		DeviceTokenType:      device,
+	}
+	validators := map[TokenType]func(string) (*AuthTokenJwtClaim, error){
+		AccessTokenType:      ValidateAccessToken,
+		RefreshTokenType:     ValidateRefreshToken,
+		TwoFactorTokenType:   ValidateTwoFactorToken,
+		ResetTokenType:       ValidateResetToken,
+		EmailChangeTokenType: ValidateEmailChangeToken,
+		EmailRevertTokenType: ValidateEmailRevertToken,
+		DeviceTokenType:      ValidateDeviceToken,
+	}
+
+	for minted, token := range tokens {
+		for expected, validate := range validators {
+			claims, err := validate(token)
+			if minted == expected {
+				if err != nil {
+					t.Errorf("%s token rejected by its own validator: %v", minted, err)
+					continue
+				}
+				if claims.Type != minted || claims.Id == "" || claims.Issuer != constant.JWTIssuer {
+					t.Errorf("%s token has unexpected claims %+v", minted, claims)
+				}
+				continue
+			}
+			if !errors.Is(err, ErrWrongTokenType) {
+				t.Errorf("%s token accepted as %s token, err = %v", minted, expected, err)
+			}
+		}
+	}
+}
+
+func TestTokenClaims(t *testing.T) {
+	useTestKeys(t)
+
+	refresh, err := GenerateRefreshToken("a@b.c", "A", "user", "refresh-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := ValidateRefreshToken(refresh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Id != "refresh-id" {
+		t.Errorf("refresh jti = %q, expected refresh-id", claims.Id)
+	}
+	if claims.Audience != constant.JWTIssuer {
+		t.Errorf("refresh audience = %q, expected %q", claims.Audience, constant.JWTIssuer)
+	}
+
+	access, err := GenerateAccessToken("a@b.c", "A", "user", "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err = ValidateAccessToken(access)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Audience != constant.JWTAudience {
+		t.Errorf("access audience = %q, expected %q", claims.Audience, constant.JWTAudience)
+	}
+
+	// A token from another issuer sharing the keys is refused.
+	defer func(issuer string) { constant.JWTIssuer = issuer }(constant.JWTIssuer)
+	constant.JWTIssuer = "someone-else"
+	if _, err := ValidateAccessToken(access); err == nil {
+		t.Error("access token from another issuer was accepted")
+	}
+}
+
+func TestMagicLinkToken(t *testing.T) {
+	useTestKeys(t)
+
+	token, err := GenerateMagicLinkToken("user", "nonce-hash", "code")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := ValidateMagicLinkToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.UserId != "user" || claims.Nonce != "nonce-hash" || claims.Code != "code" {
+		t.Errorf("unexpected magic link claims %+v", claims)
+	}
+
+	access, err := GenerateAccessToken("a@b.c", "A", "user", "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateMagicLinkToken(access); err == nil {
+		t.Error("access token accepted as a magic link")
+	}
+}
+
+func TestImpersonationTokenCarriesActor(t *testing.T) {
+	useTestKeys(t)
+
+	signed, _, err := GenerateImpersonationToken("a@b.c", "A", "user", Actor{Sub: "admin", Email: "admin@b.c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := ValidateAccessToken(signed)
+	if err != nil {
+		t.Fatalf("ValidateAccessToken: %v", err)
+	}
+	if !claims.Impersonating() || claims.Act.Sub != "admin" || claims.UserId != "user" {
+		t.Errorf("claims = %+v, expected user impersonated by admin", claims)
+	}
+	if claims.SessionId != "" {
+		t.Errorf("impersonation token belongs to session %q", claims.SessionId)
+	}
+
+	access, err := GenerateAccessToken("a@b.c", "A", "user", "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err = ValidateAccessToken(access)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Impersonating() {
+		t.Errorf("access token has actor %+v", claims.Act)
+	}
+}
+
+func TestStepUpTwoFactorToken(t *testing.T) {
+	useTestKeys(t)
+
+	stepUp, err := GenerateStepUpTwoFactorToken("a@b.c", "A", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := ValidateTwoFactorToken(stepUp)
+	if err != nil {
+		t.Fatalf("ValidateTwoFactorToken: %v", err)
+	}
+	if !claims.StepUp {
+		t.Error("step up token does not ask for an emailed code")
+	}
+
+	pending, err := GenerateTwoFactorToken("a@b.c", "A", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err = ValidateTwoFactorToken(pending)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.StepUp {
+		t.Error("pending login token asks for an emailed code")
+	}
+}
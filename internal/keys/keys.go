@@ -0,0 +1,262 @@
+package keys
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt"
+)
+
+var (
+	ErrUnknownKey     = errors.New("unknown signing key")
+	ErrNoSigningKey   = errors.New("no signing key configured")
+	ErrMethodMismatch = errors.New("token algorithm does not match its key")
+	ErrVerifyOnly     = errors.New("key can only verify tokens")
+)
+
+// Key is one signing key. Keys loaded from a public key can only verify
+// tokens, which is how a retired key is kept around until the tokens it
+// signed have expired.
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// NewHMACKey returns an HS256 key. HMAC keys are never published in the JWKS.
+func NewHMACKey(id string, secret []byte) *Key {
+	return &Key{ID: id, Method: jwt.SigningMethodHS256, signKey: secret, verifyKey: secret}
+}
+
+// NewRSAKey returns an RS256 key.
+func NewRSAKey(id string, private *rsa.PrivateKey) *Key {
+	return &Key{ID: id, Method: jwt.SigningMethodRS256, signKey: private, verifyKey: &private.PublicKey}
+}
+
+// NewEd25519Key returns an EdDSA key.
+func NewEd25519Key(id string, private ed25519.PrivateKey) *Key {
+	return &Key{ID: id, Method: jwt.SigningMethodEdDSA, signKey: private, verifyKey: private.Public()}
+}
+
+// ParsePEM reads an RSA or Ed25519 key, private or public, from PEM data.
+func ParsePEM(id string, data []byte) (*Key, error) {
+	if private, err := jwt.ParseRSAPrivateKeyFromPEM(data); err == nil {
+		return NewRSAKey(id, private), nil
+	}
+	if private, err := jwt.ParseEdPrivateKeyFromPEM(data); err == nil {
+		if private, ok := private.(ed25519.PrivateKey); ok {
+			return NewEd25519Key(id, private), nil
+		}
+	}
+	if public, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+		return &Key{ID: id, Method: jwt.SigningMethodRS256, verifyKey: public}, nil
+	}
+	if public, err := jwt.ParseEdPublicKeyFromPEM(data); err == nil {
+		if public, ok := public.(ed25519.PublicKey); ok {
+			return &Key{ID: id, Method: jwt.SigningMethodEdDSA, verifyKey: public}, nil
+		}
+	}
+	return nil, fmt.Errorf("key %q is not an RSA or Ed25519 PEM key", id)
+}
+
+// LoadDir reads every .pem file in dir. The file name without its extension
+// is used as the key ID.
+func LoadDir(dir string) ([]*Key, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*Key
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := ParsePEM(strings.TrimSuffix(filepath.Base(path), ".pem"), data)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// CanSign reports whether the private half of the key is available.
+func (k *Key) CanSign() bool {
+	return k.signKey != nil
+}
+
+// Manager holds the keys tokens are signed and verified with. One key signs
+// new tokens and every key verifies, so a new key can be rolled out before
+// it signs anything and an old one kept until its tokens expire.
+type Manager struct {
+	mu       sync.RWMutex
+	keys     map[string]*Key
+	signing  string
+	fallback string
+}
+
+// NewManager creates a Manager holding keys.
+func NewManager(keys ...*Key) *Manager {
+	m := &Manager{keys: make(map[string]*Key, len(keys))}
+	for _, key := range keys {
+		m.keys[key.ID] = key
+	}
+	return m
+}
+
+// Add adds key, replacing any key with the same ID.
+func (m *Manager) Add(key *Key) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys[key.ID] = key
+}
+
+// Remove drops a key. Tokens it signed stop verifying.
+func (m *Manager) Remove(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.keys, id)
+	if m.signing == id {
+		m.signing = ""
+	}
+	if m.fallback == id {
+		m.fallback = ""
+	}
+}
+
+// SetSigningKey makes the key with id sign new tokens.
+func (m *Manager) SetSigningKey(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, ok := m.keys[id]
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownKey, id)
+	}
+	if !key.CanSign() {
+		return fmt.Errorf("%w: %q", ErrVerifyOnly, id)
+	}
+	m.signing = id
+	return nil
+}
+
+// SetFallbackKey names the key used for tokens without a kid header, which
+// is how tokens signed before keys had IDs keep working.
+func (m *Manager) SetFallbackKey(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.keys[id]; !ok {
+		return fmt.Errorf("%w %q", ErrUnknownKey, id)
+	}
+	m.fallback = id
+	return nil
+}
+
+// SigningKeyID returns the ID of the key new tokens are signed with.
+func (m *Manager) SigningKeyID() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.signing
+}
+
+// Sign signs claims with the signing key and stamps its ID in the kid header.
+func (m *Manager) Sign(claims jwt.Claims) (string, error) {
+	m.mu.RLock()
+	key, ok := m.keys[m.signing]
+	m.mu.RUnlock()
+	if !ok {
+		return "", ErrNoSigningKey
+	}
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.signKey)
+}
+
+// Keyfunc picks the key named by the token's kid header. It is meant to be
+// passed to jwt.Parse and refuses a token whose algorithm is not the key's,
+// so a public key can never be used as an HMAC secret.
+func (m *Manager) Keyfunc(token *jwt.Token) (interface{}, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	id, _ := token.Header["kid"].(string)
+	if id == "" {
+		id = m.fallback
+	}
+
+	key, ok := m.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, id)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, ErrMethodMismatch
+	}
+	return key.verifyKey, nil
+}
+
+// Parse parses and verifies tokenString into claims.
+func (m *Manager) Parse(tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenString, claims, m.Keyfunc)
+}
+
+// JWK is a public key in JSON Web Key form.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKSet is the document served at /.well-known/jwks.json.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public half of every asymmetric key, sorted by ID.
+func (m *Manager) JWKS() JWKSet {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range m.keys {
+		jwk := JWK{Kid: key.ID, Use: "sig", Alg: key.Method.Alg()}
+
+		switch public := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
@@ -0,0 +1,212 @@
+package keys
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+func claims() *jwt.StandardClaims {
+	return &jwt.StandardClaims{Subject: "user", ExpiresAt: time.Now().Add(time.Minute).Unix()}
+}
+
+func rsaKey(t *testing.T, id string) *Key {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	return NewRSAKey(id, private)
+}
+
+func edKey(t *testing.T, id string) *Key {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	return NewEd25519Key(id, private)
+}
+
+func TestSignAndParse(t *testing.T) {
+	for _, key := range []*Key{NewHMACKey("hs", []byte("secret")), rsaKey(t, "rs"), edKey(t, "ed")} {
+		m := NewManager(key)
+		if err := m.SetSigningKey(key.ID); err != nil {
+			t.Fatalf("SetSigningKey(%q): %v", key.ID, err)
+		}
+
+		signed, err := m.Sign(claims())
+		if err != nil {
+			t.Fatalf("Sign with %q: %v", key.ID, err)
+		}
+
+		token, err := m.Parse(signed, &jwt.StandardClaims{})
+		if err != nil {
+			t.Fatalf("Parse with %q: %v", key.ID, err)
+		}
+		if token.Header["kid"] != key.ID {
+			t.Errorf("kid = %v, expected %q", token.Header["kid"], key.ID)
+		}
+		if token.Method.Alg() != key.Method.Alg() {
+			t.Errorf("alg = %q, expected %q", token.Method.Alg(), key.Method.Alg())
+		}
+	}
+}
+
+func TestRotationKeepsOldTokens(t *testing.T) {
+	old, next := rsaKey(t, "2024"), edKey(t, "2025")
+	m := NewManager(old, next)
+	if err := m.SetSigningKey(old.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	before, err := m.Sign(claims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.SetSigningKey(next.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := m.Sign(claims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, signed := range []string{before, after} {
+		if _, err := m.Parse(signed, &jwt.StandardClaims{}); err != nil {
+			t.Errorf("Parse after rotation: %v", err)
+		}
+	}
+
+	m.Remove(old.ID)
+	if _, err := m.Parse(before, &jwt.StandardClaims{}); err == nil {
+		t.Error("token signed with a removed key still verifies")
+	}
+}
+
+func TestTokenWithoutKidUsesFallback(t *testing.T) {
+	secret := []byte("secret")
+	m := NewManager(NewHMACKey("default", secret), rsaKey(t, "rs"))
+	if err := m.SetSigningKey("rs"); err != nil {
+		t.Fatal(err)
+	}
+
+	legacy, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims()).SignedString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.Parse(legacy, &jwt.StandardClaims{}); err == nil {
+		t.Error("token without kid verified with no fallback key set")
+	}
+
+	if err := m.SetFallbackKey("default"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Parse(legacy, &jwt.StandardClaims{}); err != nil {
+		t.Errorf("Parse legacy token: %v", err)
+	}
+}
+
+func TestRejectsAlgorithmConfusion(t *testing.T) {
+	key := rsaKey(t, "rs")
+	m := NewManager(key)
+
+	public := x509.MarshalPKCS1PublicKey(key.verifyKey.(*rsa.PublicKey))
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, claims())
+	forged.Header["kid"] = key.ID
+	signed, err := forged.SignedString(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = m.Parse(signed, &jwt.StandardClaims{})
+	var validation *jwt.ValidationError
+	if !errors.As(err, &validation) || validation.Inner != ErrMethodMismatch {
+		t.Errorf("Parse forged token error = %v, expected %v", err, ErrMethodMismatch)
+	}
+}
+
+func TestVerifyOnlyKeyCannotSign(t *testing.T) {
+	key := rsaKey(t, "rs")
+	m := NewManager(&Key{ID: "old", Method: key.Method, verifyKey: key.verifyKey})
+
+	if err := m.SetSigningKey("old"); !errors.Is(err, ErrVerifyOnly) {
+		t.Errorf("SetSigningKey error = %v, expected %v", err, ErrVerifyOnly)
+	}
+	if _, err := m.Sign(claims()); !errors.Is(err, ErrNoSigningKey) {
+		t.Errorf("Sign error = %v, expected %v", err, ErrNoSigningKey)
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	m := NewManager(NewHMACKey("hs", []byte("secret")), rsaKey(t, "rs"), edKey(t, "ed"))
+
+	set := m.JWKS()
+	if len(set.Keys) != 2 {
+		t.Fatalf("JWKS has %d keys, expected 2", len(set.Keys))
+	}
+
+	ed, rs := set.Keys[0], set.Keys[1]
+	if ed.Kid != "ed" || ed.Kty != "OKP" || ed.Crv != "Ed25519" || ed.Alg != "EdDSA" || ed.X == "" {
+		t.Errorf("unexpected Ed25519 JWK %+v", ed)
+	}
+	if rs.Kid != "rs" || rs.Kty != "RSA" || rs.Alg != "RS256" || rs.N == "" || rs.E != "AQAB" {
+		t.Errorf("unexpected RSA JWK %+v", rs)
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+
+	rsaPrivate, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, filepath.Join(dir, "current.pem"), "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaPrivate))
+
+	edPublic, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(edPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, filepath.Join(dir, "retired.pem"), "PUBLIC KEY", der)
+
+	loaded, err := LoadDir(dir)
+	if err != nil {
+		t.Fatalf("LoadDir: %v", err)
+	}
+	if len(loaded) != 2 {
+		t.Fatalf("LoadDir returned %d keys, expected 2", len(loaded))
+	}
+
+	byID := map[string]*Key{}
+	for _, key := range loaded {
+		byID[key.ID] = key
+	}
+	if key := byID["current"]; key == nil || !key.CanSign() || key.Method != jwt.SigningMethodRS256 {
+		t.Errorf("current key = %+v, expected a signing RS256 key", key)
+	}
+	if key := byID["retired"]; key == nil || key.CanSign() || key.Method != jwt.SigningMethodEdDSA {
+		t.Errorf("retired key = %+v, expected a verify only EdDSA key", key)
+	}
+}
+
+func writePEM(t *testing.T, path, kind string, der []byte) {
+	data := pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,76 @@
+package lockout
+
+import "time"
+
+// Policy describes how failed attempts against one key are throttled. The
+// first Threshold failures within Window are free; every failure after that
+// blocks the key for BaseDelay, doubling each time up to MaxDelay.
+type Policy struct {
+	Threshold int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	Window    time.Duration
+}
+
+var (
+	// AccountPolicy locks an account after repeated wrong passwords or second
+	// factor codes, whichever address they come from.
+	AccountPolicy = Policy{
+		Threshold: 5,
+		BaseDelay: time.Minute,
+		MaxDelay:  time.Hour,
+		Window:    24 * time.Hour,
+	}
+
+	// IPPolicy blocks a client address that fails against many accounts. It
+	// is looser than AccountPolicy so that users behind a shared address are
+	// not blocked by each other's typos.
+	IPPolicy = Policy{
+		Threshold: 20,
+		BaseDelay: time.Minute,
+		MaxDelay:  time.Hour,
+		Window:    time.Hour,
+	}
+
+	// StepUpPolicy limits wrong passwords and codes entered to confirm a
+	// sensitive action. It has its own counter, so someone holding a stolen
+	// access token cannot lock the owner out of logging in.
+	StepUpPolicy = Policy{
+		Threshold: 5,
+		BaseDelay: time.Minute,
+		MaxDelay:  time.Hour,
+		Window:    24 * time.Hour,
+	}
+)
+
+// Delay returns how long the key should be blocked after the given number of
+// consecutive failures. It is zero while failures is within the threshold.
+func (p Policy) Delay(failures int) time.Duration {
+	if failures < p.Threshold {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := p.Threshold; i < failures; i++ {
+		delay *= 2
+		if delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return delay
+}
+
+// AccountKey and IPKey name the counters kept for an account and for a
+// client address.
+func AccountKey(userID string) string {
+	return "account:" + userID
+}
+
+func IPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// StepUpKey names the counter of failed confirmations by a signed in user.
+func StepUpKey(userID string) string {
+	return "step_up:" + userID
+}
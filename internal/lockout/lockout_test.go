@@ -0,0 +1,30 @@
+package lockout
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPolicyDelay(t *testing.T) {
+	policy := Policy{Threshold: 3, BaseDelay: time.Minute, MaxDelay: 10 * time.Minute}
+
+	tests := []struct {
+		failures int
+		delay    time.Duration
+	}{
+		{failures: 0, delay: 0},
+		{failures: 2, delay: 0},
+		{failures: 3, delay: time.Minute},
+		{failures: 4, delay: 2 * time.Minute},
+		{failures: 5, delay: 4 * time.Minute},
+		{failures: 6, delay: 8 * time.Minute},
+		{failures: 7, delay: 10 * time.Minute},
+		{failures: 100, delay: 10 * time.Minute},
+	}
+
+	for _, test := range tests {
+		if delay := policy.Delay(test.failures); delay != test.delay {
+			t.Errorf("Delay(%d) = %s, expected %s", test.failures, delay, test.delay)
+		}
+	}
+}
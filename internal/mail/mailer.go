@@ -0,0 +1,93 @@
+// Package mail delivers email through a configurable transport.
+package mail
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	netmail "net/mail"
+	"strings"
+	"time"
+)
+
+var ErrInvalidHeader = errors.New("invalid email header")
+
+// Message is an HTML email from one sender to one or more recipients.
+type Message struct {
+	From     string
+	FromName string
+	To       []string
+	Subject  string
+	HTML     string
+}
+
+// Mailer hands a message to a transport. A nil error means the transport
+// took the message, not that it was delivered.
+type Mailer interface {
+	Send(message Message) error
+}
+
+// Sender is the From header value, with the display name when there is one.
+func (m Message) Sender() string {
+	return (&netmail.Address{Name: m.FromName, Address: m.From}).String()
+}
+
+// Render encodes the message as an RFC 5322 email sent at date, with a
+// quoted-printable HTML body. Headers containing line breaks are refused so
+// that user input cannot add headers of its own.
+func (m Message) Render(date time.Time) ([]byte, error) {
+	for _, value := range append([]string{m.From, m.FromName, m.Subject}, m.To...) {
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidHeader, value)
+		}
+	}
+
+	id, err := messageID(m.From)
+	if err != nil {
+		return nil, err
+	}
+
+	var to []string
+	for _, address := range m.To {
+		to = append(to, (&netmail.Address{Address: address}).String())
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", m.Sender())
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: %s\r\n", id)
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/html; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	body := quotedprintable.NewWriter(&buf)
+	if _, err := body.Write([]byte(m.HTML)); err != nil {
+		return nil, err
+	}
+	if err := body.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// messageID makes a random Message-ID at the sender's domain.
+func messageID(from string) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+
+	domain := "localhost"
+	if at := strings.LastIndex(from, "@"); at >= 0 && at < len(from)-1 {
+		domain = from[at+1:]
+	}
+
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(random), domain), nil
+}
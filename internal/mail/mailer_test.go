@@ -0,0 +1,68 @@
+package mail
+
+import (
+	"errors"
+	"io"
+	"mime"
+	netmail "net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderIsAParsableEmail(t *testing.T) {
+	message := Message{
+		From:     "noreply@example.com",
+		FromName: "Golang Template",
+		To:       []string{"ada@example.com", "bob@example.com"},
+		Subject:  "Vérifiez votre compte",
+		HTML:     "<p>" + strings.Repeat("long line ", 20) + "</p>",
+	}
+
+	raw, err := message.Render(time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+
+	parsed, err := netmail.ReadMessage(strings.NewReader(string(raw)))
+	if err != nil {
+		t.Fatalf("rendered message does not parse: %v", err)
+	}
+
+	from, err := parsed.Header.AddressList("From")
+	if err != nil || len(from) != 1 || from[0].Name != "Golang Template" || from[0].Address != "noreply@example.com" {
+		t.Errorf("unexpected From %v (%v)", from, err)
+	}
+
+	to, err := parsed.Header.AddressList("To")
+	if err != nil || len(to) != 2 {
+		t.Errorf("expected two recipients, got %v (%v)", to, err)
+	}
+
+	if subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject")); err != nil || subject != message.Subject {
+		t.Errorf("expected subject %q, got %q (%v)", message.Subject, subject, err)
+	}
+
+	if !strings.HasSuffix(parsed.Header.Get("Message-ID"), "@example.com>") {
+		t.Errorf("expected a Message-ID at the sender's domain, got %q", parsed.Header.Get("Message-ID"))
+	}
+
+	body, _ := io.ReadAll(parsed.Body)
+	for _, line := range strings.Split(string(body), "\r\n") {
+		if len(line) > 76 {
+			t.Fatalf("body line longer than 76 characters: %q", line)
+		}
+	}
+}
+
+func TestRenderRefusesHeaderInjection(t *testing.T) {
+	message := Message{
+		From:    "noreply@example.com",
+		To:      []string{"ada@example.com\r\nBcc: eve@example.com"},
+		Subject: "Hello",
+	}
+
+	if _, err := message.Render(time.Now()); !errors.Is(err, ErrInvalidHeader) {
+		t.Fatalf("expected ErrInvalidHeader, got %v", err)
+	}
+}
@@ -0,0 +1,19 @@
+package mail
+
+import (
+	"github.com/bjorndonald/golang-backend-template/resend"
+)
+
+// ResendMailer sends through the Resend API.
+type ResendMailer struct {
+	client *resend.Client
+}
+
+func NewResendMailer(client *resend.Client) *ResendMailer {
+	return &ResendMailer{client: client}
+}
+
+func (m *ResendMailer) Send(message Message) error {
+	_, err := m.client.Send(message.To, message.From, message.FromName, message.Subject, message.HTML)
+	return err
+}
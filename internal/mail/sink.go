@@ -0,0 +1,102 @@
+package mail
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SentMessage is a message the sink took, with its rendered form.
+type SentMessage struct {
+	ID     string    `json:"id"`
+	SentAt time.Time `json:"sent_at"`
+	Message
+	Raw []byte `json:"-"`
+}
+
+// Sink keeps messages instead of delivering them, for development and
+// tests. The last size messages stay in memory for the mailbox viewer, and
+// when dir is set every message is also written there as an .eml file.
+type Sink struct {
+	dir  string
+	size int
+	now  func() time.Time
+
+	mu       sync.Mutex
+	sent     int
+	messages []SentMessage
+}
+
+// NewSink returns a sink keeping size messages, creating dir if it is set.
+func NewSink(dir string, size int) (*Sink, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Sink{dir: dir, size: size, now: time.Now}, nil
+}
+
+func (s *Sink) Send(message Message) error {
+	sentAt := s.now()
+	raw, err := message.Render(sentAt)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sent++
+	sent := SentMessage{ID: strconv.Itoa(s.sent), SentAt: sentAt, Message: message, Raw: raw}
+
+	if s.dir != "" {
+		name := fmt.Sprintf("%s-%06d.eml", sentAt.UTC().Format("20060102T150405"), s.sent)
+		if err := os.WriteFile(filepath.Join(s.dir, name), raw, 0o640); err != nil {
+			return err
+		}
+	}
+
+	s.messages = append(s.messages, sent)
+	if len(s.messages) > s.size {
+		s.messages = s.messages[len(s.messages)-s.size:]
+	}
+	return nil
+}
+
+// Messages lists the messages in memory, newest first.
+func (s *Sink) Messages() []SentMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	messages := make([]SentMessage, 0, len(s.messages))
+	for i := len(s.messages) - 1; i >= 0; i-- {
+		messages = append(messages, s.messages[i])
+	}
+	return messages
+}
+
+// Find returns the message in memory with the given ID.
+func (s *Sink) Find(id string) (SentMessage, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, message := range s.messages {
+		if message.ID == id {
+			return message, true
+		}
+	}
+	return SentMessage{}, false
+}
+
+// Clear empties the in-memory inbox. Files already written are kept.
+func (s *Sink) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.messages = nil
+}
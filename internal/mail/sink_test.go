@@ -0,0 +1,63 @@
+package mail
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSinkKeepsNewestMessages(t *testing.T) {
+	sink, err := NewSink("", 2)
+	if err != nil {
+		t.Fatalf("new sink: %v", err)
+	}
+
+	for _, subject := range []string{"one", "two", "three"} {
+		if err := sink.Send(Message{From: "noreply@example.com", To: []string{"ada@example.com"}, Subject: subject}); err != nil {
+			t.Fatalf("send %s: %v", subject, err)
+		}
+	}
+
+	messages := sink.Messages()
+	if len(messages) != 2 || messages[0].Subject != "three" || messages[1].Subject != "two" {
+		t.Fatalf("expected three and two, newest first, got %+v", messages)
+	}
+
+	if _, found := sink.Find(messages[1].ID); !found {
+		t.Errorf("expected to find message %s", messages[1].ID)
+	}
+	if _, found := sink.Find("1"); found {
+		t.Errorf("expected the oldest message to be dropped")
+	}
+
+	sink.Clear()
+	if len(sink.Messages()) != 0 {
+		t.Errorf("expected an empty inbox after Clear")
+	}
+}
+
+func TestSinkWritesEmlFiles(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	sink, err := NewSink(dir, 10)
+	if err != nil {
+		t.Fatalf("new sink: %v", err)
+	}
+
+	if err := sink.Send(Message{From: "noreply@example.com", To: []string{"ada@example.com"}, Subject: "Hello", HTML: "<p>Hi</p>"}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected one .eml file, got %v (%v)", files, err)
+	}
+
+	raw, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if !strings.Contains(string(raw), "Subject: Hello\r\n") {
+		t.Errorf("expected the rendered message in the file, got %q", raw)
+	}
+}
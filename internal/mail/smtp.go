@@ -0,0 +1,138 @@
+package mail
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// TLSMode is how an SMTP connection is encrypted.
+type TLSMode string
+
+const (
+	// StartTLS upgrades a plain connection and refuses servers that do not
+	// offer STARTTLS. It is the usual mode on port 587.
+	StartTLS TLSMode = "starttls"
+	// ImplicitTLS connects over TLS from the start, usually on port 465.
+	ImplicitTLS TLSMode = "tls"
+	// NoTLS never encrypts. net/smtp then only sends the password to
+	// localhost, so it is meant for a relay on the same machine.
+	NoTLS TLSMode = "none"
+)
+
+var (
+	ErrNoStartTLS = errors.New("smtp server does not support STARTTLS")
+	ErrNoAuth     = errors.New("smtp server does not support AUTH")
+)
+
+// SMTPConfig is where and how to reach an SMTP server. Without a Username
+// messages are sent without authenticating.
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	TLS      TLSMode
+	Timeout  time.Duration
+}
+
+// SMTPMailer sends each message over a new connection to an SMTP server.
+type SMTPMailer struct {
+	config    SMTPConfig
+	tlsConfig *tls.Config
+}
+
+func NewSMTPMailer(config SMTPConfig) (*SMTPMailer, error) {
+	switch config.TLS {
+	case StartTLS, ImplicitTLS, NoTLS:
+	default:
+		return nil, fmt.Errorf("unknown smtp tls mode %q", config.TLS)
+	}
+
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+
+	return &SMTPMailer{
+		config:    config,
+		tlsConfig: &tls.Config{ServerName: config.Host, MinVersion: tls.VersionTLS12},
+	}, nil
+}
+
+func (m *SMTPMailer) Send(message Message) error {
+	body, err := message.Render(time.Now())
+	if err != nil {
+		return err
+	}
+
+	address := net.JoinHostPort(m.config.Host, strconv.Itoa(m.config.Port))
+	dialer := &net.Dialer{Timeout: m.config.Timeout}
+
+	var conn net.Conn
+	if m.config.TLS == ImplicitTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, m.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return err
+	}
+
+	// One deadline covers the whole exchange, so a stalled server cannot
+	// hold the sending goroutine forever.
+	if err := conn.SetDeadline(time.Now().Add(m.config.Timeout)); err != nil {
+		conn.Close()
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, m.config.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if m.config.TLS == StartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return ErrNoStartTLS
+		}
+		if err := client.StartTLS(m.tlsConfig); err != nil {
+			return err
+		}
+	}
+
+	if m.config.Username != "" {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return ErrNoAuth
+		}
+		if err := client.Auth(smtp.PlainAuth("", m.config.Username, m.config.Password, m.config.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(message.From); err != nil {
+		return err
+	}
+	for _, to := range message.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+
+	data, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := data.Write(body); err != nil {
+		return err
+	}
+	if err := data.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
@@ -0,0 +1,103 @@
+package mail
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+// fakeSMTPServer accepts one connection and speaks just enough SMTP to take
+// a message. It offers no extensions, so neither STARTTLS nor AUTH. Commands
+// and the message data are sent on the returned channel when the client quits.
+func fakeSMTPServer(t *testing.T) (host string, port int, received chan []string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	received = make(chan []string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var lines []string
+		defer func() { received <- lines }()
+
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+		reply("220 localhost ESMTP")
+		inData := false
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			lines = append(lines, line)
+
+			switch {
+			case inData && line == ".":
+				inData = false
+				reply("250 queued")
+			case inData:
+			case line == "DATA":
+				inData = true
+				reply("354 go ahead")
+			case line == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+
+	address := listener.Addr().(*net.TCPAddr)
+	return address.IP.String(), address.Port, received
+}
+
+func TestSMTPMailerSends(t *testing.T) {
+	host, port, received := fakeSMTPServer(t)
+	mailer, err := NewSMTPMailer(SMTPConfig{Host: host, Port: port, TLS: NoTLS})
+	if err != nil {
+		t.Fatalf("new mailer: %v", err)
+	}
+
+	err = mailer.Send(Message{From: "noreply@example.com", To: []string{"ada@example.com"}, Subject: "Hello", HTML: "<p>Hi</p>"})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	session := strings.Join(<-received, "\n")
+	for _, want := range []string{"MAIL FROM:<noreply@example.com>", "RCPT TO:<ada@example.com>", "Subject: Hello", "<p>Hi</p>"} {
+		if !strings.Contains(session, want) {
+			t.Errorf("expected %q in the SMTP session:\n%s", want, session)
+		}
+	}
+}
+
+func TestSMTPMailerRequiresStartTLS(t *testing.T) {
+	host, port, _ := fakeSMTPServer(t)
+	mailer, err := NewSMTPMailer(SMTPConfig{Host: host, Port: port, TLS: StartTLS})
+	if err != nil {
+		t.Fatalf("new mailer: %v", err)
+	}
+
+	err = mailer.Send(Message{From: "noreply@example.com", To: []string{"ada@example.com"}, Subject: "Hello"})
+	if !errors.Is(err, ErrNoStartTLS) {
+		t.Fatalf("expected ErrNoStartTLS, got %v", err)
+	}
+}
+
+func TestNewSMTPMailerRejectsUnknownTLSMode(t *testing.T) {
+	if _, err := NewSMTPMailer(SMTPConfig{Host: "localhost", Port: 25, TLS: TLSMode("ssl")}); err == nil {
+		t.Fatalf("expected an error for an unknown tls mode")
+	}
+}
@@ -5,8 +5,9 @@ import (
 )
 
 type Message struct {
-	UserId string     `json:"userId"`
-	Action ActionType `json:"action"`
+	UserId    string     `json:"userId"`
+	SessionId string     `json:"sessionId,omitempty"`
+	Action    ActionType `json:"action"`
 }
 
 type Listener struct {
@@ -111,3 +112,13 @@ func (m *Manager) Submit(userId string, action ActionType) {
 	}
 	m.messages <- msg
 }
+
+// SubmitSession sends an action that only concerns one of the user's sessions.
+func (m *Manager) SubmitSession(userId, sessionId string, action ActionType) {
+	msg := &Message{
+		UserId:    userId,
+		SessionId: sessionId,
+		Action:    action,
+	}
+	m.messages <- msg
+}
@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/bjorndonald/golang-backend-template/internal/apitoken"
+	"github.com/bjorndonald/golang-backend-template/internal/helpers"
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+	"github.com/bjorndonald/golang-backend-template/internal/rbac"
+	"github.com/bjorndonald/golang-backend-template/internal/repository"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// authenticateAPIToken checks a personal access token or service key and
+// returns claims for its owner, writing the error response itself when the
+// token cannot be used for this request.
+func authenticateAPIToken(c *gin.Context, db *gorm.DB, kind apitoken.Kind, secret string) (*helpers.AuthTokenJwtClaim, bool) {
+	tokenRepo := repository.NewAPITokenRepository(db)
+
+	token, found, err := tokenRepo.FindByHash(apitoken.Hash(secret))
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusUnauthorized)
+		c.Abort()
+		return nil, false
+	}
+
+	if !found || token.Kind != string(kind) || !token.Active() {
+		helpers.ReturnJSON(c, "Invalid or expired API token", nil, http.StatusUnauthorized)
+		c.Abort()
+		return nil, false
+	}
+
+	user, found, err := repository.NewUserRepository(db).FindByCondition("id = ?", token.UserID.String())
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusUnauthorized)
+		c.Abort()
+		return nil, false
+	}
+
+	if !found || user.Status == models.SuspendedAccount {
+		helpers.ReturnJSON(c, "Invalid or expired API token", nil, http.StatusUnauthorized)
+		c.Abort()
+		return nil, false
+	}
+
+	permissions, err := repository.NewRoleRepository(db).PermissionsForUser(user)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusUnauthorized)
+		c.Abort()
+		return nil, false
+	}
+
+	if kind == apitoken.Service && !rbac.Allows(permissions, rbac.APIKeysWrite) {
+		helpers.ReturnJSON(c, "Invalid or expired API token", nil, http.StatusUnauthorized)
+		c.Abort()
+		return nil, false
+	}
+
+	scopes := apitoken.SplitScopes(token.Scopes)
+	if !apitoken.Allows(scopes, apitoken.ScopeForMethod(c.Request.Method)) {
+		helpers.ReturnError(c, "API token does not have the scope for this request", errors.New("insufficient scope"), http.StatusForbidden)
+		c.Abort()
+		return nil, false
+	}
+
+	if token.LastUsedAt == nil || time.Since(*token.LastUsedAt) > time.Minute {
+		if err := tokenRepo.RecordUse(token.ID, c.ClientIP()); err != nil {
+			log.Println("error: ", err.Error())
+		}
+	}
+
+	// Only the admin scope carries the owner's role permissions, so a
+	// token without it can reach their own account and nothing else.
+	if !apitoken.Allows(scopes, apitoken.AdminScope) {
+		permissions = nil
+	}
+
+	c.Set("apiToken", token)
+	c.Set("permissions", permissions)
+
+	return &helpers.AuthTokenJwtClaim{
+		Email:  user.Email,
+		Name:   user.FirstName,
+		UserId: user.ID.String(),
+		Type:   helpers.AccessTokenType,
+	}, true
+}
+
+// RequireSession refuses requests made with an API token or by an admin
+// impersonating the user. It guards the routes that manage credentials, so
+// neither a leaked token nor support staff can change how the account is
+// signed in to.
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := helpers.GetAPIToken(c); ok {
+			helpers.ReturnError(c, "This action needs a logged in session", errors.New("api tokens cannot be used here"), http.StatusForbidden)
+			c.Abort()
+			return
+		}
+
+		if claims, err := helpers.GetAuthenticatedUser(c); err == nil && claims.Impersonating() {
+			helpers.ReturnError(c, "This action cannot be taken while impersonating", errors.New("not allowed while impersonating"), http.StatusForbidden)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/bjorndonald/golang-backend-template/internal/helpers"
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+	"github.com/bjorndonald/golang-backend-template/internal/rbac"
+	"github.com/bjorndonald/golang-backend-template/internal/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+// checkImpersonator makes sure the admin named in an impersonation token
+// still exists and may still impersonate, so taking the permission away
+// ends impersonation straight away instead of when the token runs out.
+func checkImpersonator(c *gin.Context, db *gorm.DB, claims *helpers.AuthTokenJwtClaim) bool {
+	actor, found, err := repository.NewUserRepository(db).FindByCondition("id = ?", claims.Act.Sub)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusUnauthorized)
+		c.Abort()
+		return false
+	}
+
+	if !found || actor.Status == models.SuspendedAccount {
+		helpers.ReturnJSON(c, "Impersonation has ended", nil, http.StatusUnauthorized)
+		c.Abort()
+		return false
+	}
+
+	permissions, err := repository.NewRoleRepository(db).PermissionsForUser(actor)
+	if err != nil {
+		helpers.ReturnError(c, "Something went wrong", err, http.StatusUnauthorized)
+		c.Abort()
+		return false
+	}
+
+	if !rbac.Allows(permissions, rbac.UsersImpersonate) {
+		helpers.ReturnJSON(c, "Impersonation has ended", nil, http.StatusUnauthorized)
+		c.Abort()
+		return false
+	}
+
+	return true
+}
+
+// logImpersonatedRequest writes a request made under impersonation to the
+// audit log once it has been handled, so the entry has its status.
+func logImpersonatedRequest(c *gin.Context, db *gorm.DB, claims *helpers.AuthTokenJwtClaim) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		log.Println("error: ", err.Error())
+		return
+	}
+
+	entry := &models.AuditLog{
+		ID:         id,
+		ActorID:    uuid.FromStringOrNil(claims.Act.Sub),
+		Action:     models.AuditImpersonatedRequest,
+		TargetType: "user",
+		TargetID:   claims.UserId,
+		Details: map[string]interface{}{
+			"method":   c.Request.Method,
+			"path":     c.FullPath(),
+			"status":   c.Writer.Status(),
+			"token_id": claims.Id,
+		},
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		CreatedAt: time.Now(),
+	}
+
+	if err := repository.NewAuditLogRepository(db).Create(entry); err != nil {
+		log.Println("error: ", err.Error())
+	}
+}
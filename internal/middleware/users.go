@@ -1,21 +1,23 @@
 package middleware
 
 import (
-	"errors"
+	"fmt"
 	"log"
 	"mime/multipart"
 	"net/http"
 
 	"github.com/bjorndonald/golang-backend-template/constants"
+	"github.com/bjorndonald/golang-backend-template/internal/apitoken"
 	"github.com/bjorndonald/golang-backend-template/internal/helpers"
-	"github.com/bjorndonald/golang-backend-template/internal/models"
+	"github.com/bjorndonald/golang-backend-template/internal/rbac"
 	"github.com/bjorndonald/golang-backend-template/internal/repository"
 	"github.com/bjorndonald/golang-backend-template/internal/utils"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt"
+	"github.com/gofrs/uuid"
 	"gorm.io/gorm"
 
 	"strings"
+	"time"
 )
 
 type AppError struct {
@@ -35,30 +37,22 @@ var (
 	constant = constants.New()
 )
 
-func OnlyAdmin(db *gorm.DB) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		claims, _ := c.Get("claims")
-		claimsData, ok := claims.(*helpers.AuthTokenJwtClaim)
-		if !ok {
-			helpers.ReturnJSON(c, "Could not read access token", errors.New("Invalid access token"), http.StatusUnauthorized)
-			c.Abort()
-
-			return
-		}
+// OnlyAdmin lets through users whose roles grant every permission. Routes
+// that only need part of that should use RequirePermission instead.
+func OnlyAdmin() gin.HandlerFunc {
+	return RequirePermission(rbac.All)
+}
 
-		user, _, err := repository.NewUserRepository(db).FindByCondition("id", claimsData.UserId)
-		if err != nil {
-			helpers.ReturnError(c, "Could not retrieve possible admin user", err, http.StatusUnauthorized)
-			c.Abort()
-			return
-		}
-		if user.Role != models.AdminRole {
-			helpers.ReturnJSON(c, "Unauthorized access to resource", errors.New("User does not have acces to this request. Please contact admin."), http.StatusUnauthorized)
+// RequirePermission refuses requests from users whose roles do not grant
+// permission. It has to run after JWTMiddleware.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !rbac.Allows(helpers.GetPermissions(c), permission) {
+			helpers.ReturnError(c, "Unauthorized access to resource", fmt.Errorf("missing permission %q", permission), http.StatusForbidden)
 			c.Abort()
 			return
 		}
 
-		c.Set("role", user.Role)
 		c.Next()
 	}
 }
@@ -88,32 +82,67 @@ func JWTMiddleware(db *gorm.DB) gin.HandlerFunc {
 
 			return
 		}
-		log.Println(tokenString)
-		// Parse and validate the JWT token
-		token, err := jwt.ParseWithClaims(tokenString, &helpers.AuthTokenJwtClaim{}, func(token *jwt.Token) (interface{}, error) {
-			// Provide the same JWT secret key used for signing the tokens
-			return []byte(constant.JWTSecretKey), nil
-		})
-		if err != nil || !token.Valid {
-			helpers.ReturnError(c, "Expired Authorization or Access Token", err, http.StatusUnauthorized)
-			c.Abort()
 
+		// Personal access tokens and service keys are opaque and looked up
+		// by hash instead of being parsed
+		if kind, ok := apitoken.KindOf(tokenString); ok {
+			claims, ok := authenticateAPIToken(c, db, kind, tokenString)
+			if !ok {
+				return
+			}
+
+			c.Set("claims", claims)
+			c.Next()
 			return
 		}
 
-		// Extract the claims from the token
-		claims, ok := token.Claims.(*helpers.AuthTokenJwtClaim)
-		if !ok {
-			helpers.ReturnJSON(c, "Invalid claims", nil, http.StatusUnauthorized)
+		// Parse and validate the JWT token against whichever key signed it
+		claims, err := helpers.ValidateAccessToken(tokenString)
+		if err != nil {
+			helpers.ReturnError(c, "Expired Authorization or Access Token", err, http.StatusUnauthorized)
 			c.Abort()
 
 			return
 		}
 
+		// Tokens minted for a logged in session stop working as soon as the
+		// session is revoked
+		if claims.SessionId != "" {
+			sessionID, err := uuid.FromString(claims.SessionId)
+			if err != nil {
+				helpers.ReturnJSON(c, "Invalid claims", nil, http.StatusUnauthorized)
+				c.Abort()
+
+				return
+			}
+
+			sessionRepo := repository.NewSessionRepository(db)
+			session, found, err := sessionRepo.Find(sessionID)
+			if err != nil {
+				helpers.ReturnError(c, "Something went wrong", err, http.StatusUnauthorized)
+				c.Abort()
+
+				return
+			}
+
+			if !found || session.RevokedAt != nil {
+				helpers.ReturnJSON(c, "Session has been revoked", nil, http.StatusUnauthorized)
+				c.Abort()
+
+				return
+			}
+
+			if time.Since(session.LastSeenAt) > time.Minute {
+				if err := sessionRepo.Touch(sessionID); err != nil {
+					log.Println("error: ", err.Error())
+				}
+			}
+		}
+
 		// Attach the claims to the request context for further use
 		c.Set("claims", claims)
 
-		_, found, err := repository.NewUserRepository(db).FindByCondition("email", claims.Email)
+		user, found, err := repository.NewUserRepository(db).FindByCondition("id = ?", claims.UserId)
 
 		if err != nil {
 			helpers.ReturnError(c, "Something went wrong", err, http.StatusUnauthorized)
@@ -129,6 +158,26 @@ func JWTMiddleware(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		permissions, err := repository.NewRoleRepository(db).PermissionsForUser(user)
+		if err != nil {
+			helpers.ReturnError(c, "Something went wrong", err, http.StatusInternalServerError)
+			c.Abort()
+
+			return
+		}
+
+		c.Set("permissions", permissions)
+
+		if claims.Impersonating() {
+			if !checkImpersonator(c, db, claims) {
+				return
+			}
+
+			c.Next()
+			logImpersonatedRequest(c, db, claims)
+			return
+		}
+
 		// Proceed to the next middleware or route handler
 		c.Next()
 	}
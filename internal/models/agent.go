@@ -1,13 +1,30 @@
 package models
 
-import "github.com/gofrs/uuid"
+import (
+	"time"
 
+	"github.com/gofrs/uuid"
+)
+
+// UserAgent is a device the user has logged in from. Fingerprint ties it to
+// the browser's device cookie and the parts of its user agent that survive
+// browser updates. Trusted devices are not challenged for an emailed code
+// when a login from them looks risky.
 type UserAgent struct {
 	ID          uuid.UUID `json:"id,omitempty"`
-	UserID      uuid.UUID `json:"user_id,omitempty"`
+	UserID      uuid.UUID `json:"user_id,omitempty" gorm:"index"`
+	Fingerprint string    `json:"-" gorm:"index"`
+	Name        string    `json:"name"`
 	Platform    string    `json:"platform"`
 	OS          string    `json:"os"`
 	BrowserName string    `json:"browser_name"`
 	Mobile      bool      `json:"mobile"`
 	Model       string    `json:"model"`
+	IP          string    `json:"ip"`
+	City        string    `json:"city"`
+	Country     string    `json:"country"`
+	Trusted     bool      `json:"trusted"`
+	FirstSeenAt time.Time `json:"first_seen_at"`
+	LastSeenAt  time.Time `json:"last_seen_at"`
+	Current     bool      `json:"current" gorm:"-"`
 }
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// APIToken is a long lived credential for scripts and services. Personal
+// tokens act as the user who made them; service keys are made by an admin
+// and stop working if the owner is no longer one. Only the hash of the
+// secret is kept, Hint is its first few characters.
+type APIToken struct {
+	ID         uuid.UUID  `json:"id" gorm:"type:uuid;primaryKey"`
+	UserID     uuid.UUID  `json:"user_id" gorm:"type:uuid;index"`
+	Kind       string     `json:"kind" gorm:"index"`
+	Name       string     `json:"name"`
+	Hint       string     `json:"hint"`
+	TokenHash  string     `json:"-" gorm:"uniqueIndex"`
+	Scopes     string     `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP string     `json:"last_used_ip,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Active reports whether the token can still be used.
+func (t *APIToken) Active() bool {
+	if t.RevokedAt != nil {
+		return false
+	}
+	return t.ExpiresAt == nil || time.Now().Before(*t.ExpiresAt)
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// Actions written to the audit log.
+const (
+	AuditUserSuspend            = "user.suspend"
+	AuditUserReactivate         = "user.reactivate"
+	AuditUserForcePasswordReset = "user.force_password_reset"
+	AuditUserChangeRole         = "user.change_role"
+	AuditUserResendVerification = "user.resend_verification"
+	AuditUserSoftDelete         = "user.soft_delete"
+	AuditUserHardDelete         = "user.hard_delete"
+	AuditUserUnlock             = "user.unlock"
+	AuditUserImpersonate        = "user.impersonate"
+	AuditImpersonatedRequest    = "impersonation.request"
+	AuditRoleCreate             = "role.create"
+	AuditRoleUpdate             = "role.update"
+	AuditRoleDelete             = "role.delete"
+	AuditRoleAssign             = "role.assign"
+	AuditRoleUnassign           = "role.unassign"
+	AuditAPIKeyCreate           = "api_key.create"
+	AuditAPIKeyRevoke           = "api_key.revoke"
+)
+
+// AuditLog records one action an admin took. Rows are never updated or
+// deleted, and they outlive the users they mention.
+type AuditLog struct {
+	ID         uuid.UUID              `json:"id" gorm:"type:uuid;primaryKey"`
+	ActorID    uuid.UUID              `json:"actor_id" gorm:"type:uuid;index"`
+	Action     string                 `json:"action" gorm:"index"`
+	TargetType string                 `json:"target_type"`
+	TargetID   string                 `json:"target_id" gorm:"index"`
+	Details    map[string]interface{} `json:"details,omitempty" gorm:"serializer:json"`
+	IP         string                 `json:"ip"`
+	UserAgent  string                 `json:"user_agent"`
+	CreatedAt  time.Time              `json:"created_at" gorm:"index"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// DeviceApproval is a login from an unfamiliar device that the user has to
+// approve from an emailed link. Fingerprint identifies the device the login
+// came from; once approved, the next login from it can go on, and that uses
+// the approval up.
+type DeviceApproval struct {
+	ID          uuid.UUID  `json:"id" gorm:"type:uuid;primaryKey"`
+	UserID      uuid.UUID  `json:"user_id" gorm:"type:uuid;index"`
+	Fingerprint string     `json:"-" gorm:"index"`
+	IP          string     `json:"ip"`
+	UserAgent   string     `json:"user_agent"`
+	City        string     `json:"city"`
+	Country     string     `json:"country"`
+	Signals     string     `json:"signals"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	ApprovedAt  *time.Time `json:"approved_at,omitempty"`
+	UsedAt      *time.Time `json:"used_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// Pending reports whether the approval link can still be followed.
+func (d *DeviceApproval) Pending() bool {
+	return d.ApprovedAt == nil && time.Now().Before(d.ExpiresAt)
+}
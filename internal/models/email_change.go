@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// EmailChange is a request to move an account to a new email address. The
+// address is only swapped once the link sent to NewEmail is followed, and
+// the link sent to OldEmail can undo the change until it expires.
+type EmailChange struct {
+	ID          uuid.UUID  `json:"id" gorm:"type:uuid;primaryKey"`
+	UserID      uuid.UUID  `json:"user_id" gorm:"type:uuid;index"`
+	OldEmail    string     `json:"old_email"`
+	NewEmail    string     `json:"new_email"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	ConfirmedAt *time.Time `json:"confirmed_at,omitempty"`
+	RevertedAt  *time.Time `json:"reverted_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// Pending reports whether the change can still be confirmed.
+func (e *EmailChange) Pending() bool {
+	return e.ConfirmedAt == nil && e.RevertedAt == nil && time.Now().Before(e.ExpiresAt)
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// Identity links a user to an account at an external OAuth provider. A user
+// can have one identity per provider.
+type Identity struct {
+	ID             uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
+	UserID         uuid.UUID `json:"user_id" gorm:"type:uuid;index"`
+	Provider       string    `json:"provider" gorm:"uniqueIndex:idx_identity_provider_user"`
+	ProviderUserID string    `json:"provider_user_id" gorm:"uniqueIndex:idx_identity_provider_user"`
+	Email          string    `json:"email"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
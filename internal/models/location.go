@@ -1,13 +1,21 @@
 package models
 
-import "github.com/gofrs/uuid"
+import (
+	"time"
 
+	"github.com/gofrs/uuid"
+)
+
+// GeoLocation is a place the user has logged in from, or the place an IP
+// address resolves to.
 type GeoLocation struct {
-	ID       uuid.UUID `json:"id,omitempty"`
-	UserID   uuid.UUID `json:"user_id,omitempty"`
-	IP       string    `json:"ip"`
-	City     string    `json:"city"`
-	Region   string    `json:"region"`
-	Country  string    `json:"country"`
-	Location string    `json:"loc"`
+	ID          uuid.UUID `json:"id,omitempty"`
+	UserID      uuid.UUID `json:"user_id,omitempty" gorm:"index"`
+	IP          string    `json:"ip"`
+	City        string    `json:"city"`
+	Region      string    `json:"region"`
+	Country     string    `json:"country"`
+	Location    string    `json:"loc"`
+	FirstSeenAt time.Time `json:"first_seen_at"`
+	LastSeenAt  time.Time `json:"last_seen_at"`
 }
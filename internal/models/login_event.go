@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+type LoginEventType string
+
+// Kinds of login event.
+const (
+	LoginSucceeded     LoginEventType = "login.success"
+	LoginFailed        LoginEventType = "login.failure"
+	LoginChallenged    LoginEventType = "login.challenge"
+	LoginLockedOut     LoginEventType = "login.lockout"
+	LoginNewDevice     LoginEventType = "login.new_device"
+	LoginPasswordReset LoginEventType = "password.reset"
+)
+
+// LoginEvent records one step of a login, or something that happened to the
+// account's credentials. UserID is nil for attempts on an email address
+// with no account, and Email keeps the address that was tried. Events are
+// never updated, and their UUIDv7 IDs sort by time.
+type LoginEvent struct {
+	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey"`
+	UserID    uuid.UUID      `json:"user_id" gorm:"type:uuid;index"`
+	Email     string         `json:"email" gorm:"index"`
+	Type      LoginEventType `json:"type" gorm:"index"`
+	Method    string         `json:"method,omitempty"`
+	Reason    string         `json:"reason,omitempty"`
+	IP        string         `json:"ip" gorm:"index"`
+	UserAgent string         `json:"user_agent"`
+	City      string         `json:"city"`
+	Region    string         `json:"region"`
+	Country   string         `json:"country"`
+	CreatedAt time.Time      `json:"created_at"`
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// LoginThrottle counts consecutive failed logins for an account or a client
+// address. Key is built with the lockout package.
+type LoginThrottle struct {
+	Key           string     `json:"key" gorm:"primaryKey"`
+	Failures      int        `json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	BlockedUntil  *time.Time `json:"blocked_until,omitempty"`
+}
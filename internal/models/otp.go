@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// OneTimePassword is an emailed code waiting to be redeemed. Key combines the
+// purpose and the subject the code was sent to. Token is the hash of the
+// code, so the table cannot be used to sign in.
+type OneTimePassword struct {
+	Key       string    `json:"-" gorm:"primaryKey"`
+	Subject   string    `json:"subject"`
+	Purpose   string    `json:"purpose"`
+	Token     string    `json:"-"`
+	Attempts  int       `json:"attempts"`
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// PasswordHistory keeps the hash of a password the user has replaced, so
+// it cannot be chosen again for a while.
+type PasswordHistory struct {
+	ID           uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
+	UserID       uuid.UUID `json:"user_id" gorm:"type:uuid;index"`
+	PasswordHash string    `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+}
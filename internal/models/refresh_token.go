@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// RefreshToken is the server-side record of an issued refresh token. Only the
+// hash of the token is stored. Tokens rotated from the same login share a
+// FamilyID so the whole chain can be revoked when a used token is replayed.
+type RefreshToken struct {
+	ID        uuid.UUID  `json:"id" gorm:"type:uuid;primaryKey"`
+	UserID    uuid.UUID  `json:"user_id" gorm:"type:uuid;index"`
+	FamilyID  uuid.UUID  `json:"family_id" gorm:"type:uuid;index"`
+	ParentID  *uuid.UUID `json:"parent_id,omitempty" gorm:"type:uuid"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex"`
+	Device    string     `json:"device"`
+	IP        string     `json:"ip"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
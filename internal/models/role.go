@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// Role is a named set of permissions. See the rbac package for how
+// permissions are written and matched.
+type Role struct {
+	ID          uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
+	Name        string    `json:"name" gorm:"uniqueIndex"`
+	Description string    `json:"description"`
+	Permissions []string  `json:"permissions" gorm:"serializer:json"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// RoleAssignment gives a role to a user. A user also holds the role named by
+// their Role column, which is how accounts from before roles existed keep
+// their access.
+type RoleAssignment struct {
+	UserID    uuid.UUID `json:"user_id" gorm:"type:uuid;primaryKey"`
+	RoleID    uuid.UUID `json:"role_id" gorm:"type:uuid;primaryKey;index"`
+	CreatedAt time.Time `json:"created_at"`
+}
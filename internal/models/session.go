@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// Session is a single logged in device. The session ID doubles as the family
+// ID of the refresh tokens rotated from that login.
+type Session struct {
+	ID          uuid.UUID  `json:"id" gorm:"type:uuid;primaryKey"`
+	UserID      uuid.UUID  `json:"user_id" gorm:"type:uuid;index"`
+	DeviceID    uuid.UUID  `json:"device_id" gorm:"type:uuid;index"`
+	IP          string     `json:"ip"`
+	Platform    string     `json:"platform"`
+	OS          string     `json:"os"`
+	BrowserName string     `json:"browser_name"`
+	Mobile      bool       `json:"mobile"`
+	Model       string     `json:"model"`
+	City        string     `json:"city"`
+	Region      string     `json:"region"`
+	Country     string     `json:"country"`
+	Location    string     `json:"location"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastSeenAt  time.Time  `json:"last_seen_at"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	Current     bool       `json:"current" gorm:"-"`
+}
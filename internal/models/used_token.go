@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// UsedToken records the jti of a single use token once it has been spent.
+// Rows can be dropped after ExpiresAt because the token no longer verifies.
+type UsedToken struct {
+	ID        string    `json:"id" gorm:"primaryKey"`
+	UsedAt    time.Time `json:"used_at"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+}
@@ -5,11 +5,13 @@ import (
 	"time"
 
 	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
 )
 
 type AccountRole string
 type AccountStatus string
 type AuthVersion string
+type TwoFactorMethod string
 
 const (
 	UserRole  AccountRole = "user"
@@ -19,17 +21,25 @@ const (
 const (
 	ActiveAccount    AccountStatus = "Active"
 	SuspendedAccount AccountStatus = "Suspended"
+	LockedAccount    AccountStatus = "Locked"
 	InactiveAccount  AccountStatus = "Inactive"
 	DeletedAccount   AccountStatus = "Inactive"
 
+	// Outdated makes the next login ask for a password reset. It is only
+	// set when a reset is forced; weak password hashes are upgraded on
+	// login instead.
 	Outdated AuthVersion = "Outdated"
 	UpToDate AuthVersion = "Up To Date"
+
+	EmailOTPMethod     TwoFactorMethod = "email"
+	TOTPMethod         TwoFactorMethod = "totp"
+	RecoveryCodeMethod TwoFactorMethod = "recovery"
 )
 
 type User struct {
 	Email         string        `json:"email"`
 	Password      string        `json:"password"`
-	LastLogin     string        `json:"last_login"`
+	LastLogin     *time.Time    `json:"last_login" gorm:"column:last_login_at"`
 	IP            string        `json:"ip"`
 	Photo         string        `json:"photo"`
 	AuthVersion   AuthVersion   `json:"auth_version" default:"Up To Date"`
@@ -42,13 +52,25 @@ type User struct {
 	LastName      string        `json:"last_name" validate:"required"`
 	Bio           string        `json:"bio"`
 	Status        AccountStatus `json:"status"`
+	LockedUntil   *time.Time    `json:"locked_until,omitempty"`
 	CreatedAt     time.Time     `json:"created_at"`
 	UpdatedAt     time.Time     `json:"updated_at"`
+
+	// DeletedAt is set when an admin soft deletes the account. Soft deleted
+	// users are left out of every query that does not ask for them.
+	DeletedAt gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
+
+	// Two factor preferences. TOTPSecret is encrypted at rest and is set
+	// before TOTPEnabled while an enrollment waits for confirmation.
+	EmailOTPEnabled bool   `json:"email_otp_enabled" gorm:"default:true"`
+	TOTPEnabled     bool   `json:"totp_enabled"`
+	TOTPSecret      string `json:"-"`
+	TOTPLastStep    int64  `json:"-"`
 }
 
 type UserInfo struct {
 	Email         string        `json:"email"`
-	LastLogin     string        `json:"last_login"`
+	LastLogin     *time.Time    `json:"last_login"`
 	IP            string        `json:"ip"`
 	Photo         string        `json:"photo"`
 	ID            uuid.UUID     `json:"id" validate:"required"`
@@ -61,6 +83,33 @@ type UserInfo struct {
 	Status        AccountStatus `json:"status"`
 	CreatedAt     time.Time     `json:"created_at"`
 	UpdatedAt     time.Time     `json:"updated_at"`
+	DeletedAt     *time.Time    `json:"deleted_at,omitempty"`
+}
+
+// TwoFactorMethods lists the second factors the user can log in with, the
+// preferred one first.
+func (u *User) TwoFactorMethods() []TwoFactorMethod {
+	var methods []TwoFactorMethod
+	if u.TOTPEnabled {
+		methods = append(methods, TOTPMethod)
+	}
+	if u.EmailOTPEnabled {
+		methods = append(methods, EmailOTPMethod)
+	}
+	return methods
+}
+
+// LockRemaining reports how long the account stays locked after too many
+// failed logins. It is zero once the lock has run out, even if Status still
+// says Locked.
+func (u *User) LockRemaining() time.Duration {
+	if u.LockedUntil == nil {
+		return 0
+	}
+	if remaining := time.Until(*u.LockedUntil); remaining > 0 {
+		return remaining
+	}
+	return 0
 }
 
 func (u *User) MarshalJSON() ([]byte, error) {
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// WebAuthnCredential is a passkey registered by the user. SignCount holds the
+// last signature counter reported by the authenticator so that a cloned key
+// can be detected.
+type WebAuthnCredential struct {
+	ID              uuid.UUID  `json:"id" gorm:"type:uuid;primaryKey"`
+	UserID          uuid.UUID  `json:"user_id" gorm:"type:uuid;index"`
+	Name            string     `json:"name"`
+	CredentialID    []byte     `json:"-" gorm:"uniqueIndex"`
+	PublicKey       []byte     `json:"-"`
+	AttestationType string     `json:"-"`
+	AAGUID          []byte     `json:"-"`
+	Transports      string     `json:"transports"`
+	SignCount       uint32     `json:"-"`
+	BackupEligible  bool       `json:"backup_eligible"`
+	BackupState     bool       `json:"backup_state"`
+	CreatedAt       time.Time  `json:"created_at"`
+	LastUsedAt      *time.Time `json:"last_used_at,omitempty"`
+}
@@ -0,0 +1,207 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/bjorndonald/golang-backend-template/constants"
+	"golang.org/x/oauth2"
+)
+
+const (
+	Google = "google"
+	Github = "github"
+)
+
+var ErrUnknownProvider = errors.New("unknown oauth provider")
+
+// Profile is the account information returned by a provider after login.
+type Profile struct {
+	ProviderUserID string
+	Email          string
+	EmailVerified  bool
+	FirstName      string
+	LastName       string
+	Photo          string
+}
+
+// Provider wraps the oauth2 configuration of a single identity provider
+// together with the endpoint used to read the user's profile.
+type Provider struct {
+	Name       string
+	Config     *oauth2.Config
+	ProfileURL string
+	fetch      func(ctx context.Context, client *http.Client, profileURL string) (*Profile, error)
+}
+
+// NewProviders builds the providers that have a client ID configured.
+// Endpoints default to the public ones and can be overridden from config.
+func NewProviders(c *constants.Config) map[string]*Provider {
+	providers := map[string]*Provider{}
+
+	if c.GoogleClientID != "" {
+		providers[Google] = &Provider{
+			Name: Google,
+			Config: &oauth2.Config{
+				ClientID:     c.GoogleClientID,
+				ClientSecret: c.GoogleClientSecret,
+				Endpoint: oauth2.Endpoint{
+					AuthURL:  c.GoogleAuthURL,
+					TokenURL: c.GoogleTokenURL,
+				},
+				RedirectURL: callbackURL(c.OAuthRedirectBaseURL, Google),
+				Scopes:      []string{"openid", "email", "profile"},
+			},
+			ProfileURL: c.GoogleUserInfoURL,
+			fetch:      fetchGoogleProfile,
+		}
+	}
+
+	if c.GithubClientID != "" {
+		providers[Github] = &Provider{
+			Name: Github,
+			Config: &oauth2.Config{
+				ClientID:     c.GithubClientID,
+				ClientSecret: c.GithubClientSecret,
+				Endpoint: oauth2.Endpoint{
+					AuthURL:  c.GithubAuthURL,
+					TokenURL: c.GithubTokenURL,
+				},
+				RedirectURL: callbackURL(c.OAuthRedirectBaseURL, Github),
+				Scopes:      []string{"read:user", "user:email"},
+			},
+			ProfileURL: c.GithubAPIURL,
+			fetch:      fetchGithubProfile,
+		}
+	}
+
+	return providers
+}
+
+func callbackURL(baseURL, provider string) string {
+	return fmt.Sprintf("%s/api/v1/auth/oauth/%s/callback", strings.TrimSuffix(baseURL, "/"), provider)
+}
+
+// AuthCodeURL returns the provider login URL for the given state and PKCE verifier.
+func (p *Provider) AuthCodeURL(state, verifier string) string {
+	return p.Config.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier))
+}
+
+// Exchange trades the authorization code for a token and reads the user's profile.
+func (p *Provider) Exchange(ctx context.Context, code, verifier string) (*Profile, error) {
+	token, err := p.Config.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, err
+	}
+
+	return p.fetch(ctx, p.Config.Client(ctx, token), p.ProfileURL)
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", url, res.StatusCode)
+	}
+
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+func fetchGoogleProfile(ctx context.Context, client *http.Client, profileURL string) (*Profile, error) {
+	var info struct {
+		Sub           string `json:"sub"`
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		GivenName     string `json:"given_name"`
+		FamilyName    string `json:"family_name"`
+		Picture       string `json:"picture"`
+	}
+
+	if err := getJSON(ctx, client, profileURL, &info); err != nil {
+		return nil, err
+	}
+
+	if info.Sub == "" {
+		return nil, errors.New("google profile has no subject")
+	}
+
+	return &Profile{
+		ProviderUserID: info.Sub,
+		Email:          strings.ToLower(info.Email),
+		EmailVerified:  info.EmailVerified,
+		FirstName:      info.GivenName,
+		LastName:       info.FamilyName,
+		Photo:          info.Picture,
+	}, nil
+}
+
+func fetchGithubProfile(ctx context.Context, client *http.Client, apiURL string) (*Profile, error) {
+	var user struct {
+		ID        int64  `json:"id"`
+		Login     string `json:"login"`
+		Name      string `json:"name"`
+		AvatarURL string `json:"avatar_url"`
+	}
+
+	apiURL = strings.TrimSuffix(apiURL, "/")
+	if err := getJSON(ctx, client, apiURL+"/user", &user); err != nil {
+		return nil, err
+	}
+
+	if user.ID == 0 {
+		return nil, errors.New("github profile has no id")
+	}
+
+	// The public profile email may be empty or unverified, so the primary
+	// verified address is read from the emails endpoint instead.
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getJSON(ctx, client, apiURL+"/user/emails", &emails); err != nil {
+		return nil, err
+	}
+
+	profile := &Profile{
+		ProviderUserID: fmt.Sprint(user.ID),
+		Photo:          user.AvatarURL,
+	}
+
+	for _, e := range emails {
+		if e.Primary {
+			profile.Email = strings.ToLower(e.Email)
+			profile.EmailVerified = e.Verified
+			break
+		}
+	}
+
+	name := strings.TrimSpace(user.Name)
+	if name == "" {
+		name = user.Login
+	}
+	first, last, _ := strings.Cut(name, " ")
+	profile.FirstName = first
+	profile.LastName = last
+
+	return profile, nil
+}
+
+// GenerateVerifier returns a new PKCE code verifier.
+func GenerateVerifier() string {
+	return oauth2.GenerateVerifier()
+}
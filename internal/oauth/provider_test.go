@@ -0,0 +1,143 @@
+package oauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/bjorndonald/golang-backend-template/constants"
+)
+
+// newFakeServer starts a minimal OAuth server that checks the PKCE verifier
+// sent to the token endpoint against the challenge from the login URL.
+func newFakeServer(t *testing.T, challenge *string) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatalf("Failed to parse token request: %v", err)
+		}
+
+		sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+		if base64.RawURLEncoding.EncodeToString(sum[:]) != *challenge {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "fake-token",
+			"token_type":   "Bearer",
+		})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"sub":            "google-1",
+			"email":          "Jane@Example.com",
+			"email_verified": true,
+			"given_name":     "Jane",
+			"family_name":    "Doe",
+		})
+	})
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":    42,
+			"login": "janedoe",
+			"name":  "Jane Doe",
+		})
+	})
+	mux.HandleFunc("/user/emails", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]map[string]interface{}{
+			{"email": "other@example.com", "primary": false, "verified": true},
+			{"email": "jane@example.com", "primary": true, "verified": true},
+		})
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestProvider_Exchange(t *testing.T) {
+	var challenge string
+	server := newFakeServer(t, &challenge)
+	defer server.Close()
+
+	providers := NewProviders(&constants.Config{
+		GoogleClientID:       "google-client",
+		GoogleAuthURL:        server.URL + "/auth",
+		GoogleTokenURL:       server.URL + "/token",
+		GoogleUserInfoURL:    server.URL + "/userinfo",
+		GithubClientID:       "github-client",
+		GithubAuthURL:        server.URL + "/auth",
+		GithubTokenURL:       server.URL + "/token",
+		GithubAPIURL:         server.URL,
+		OAuthRedirectBaseURL: "http://localhost:8000",
+	})
+
+	tests := []struct {
+		name     string
+		provider string
+		userID   string
+	}{
+		{name: "Google", provider: Google, userID: "google-1"},
+		{name: "Github", provider: Github, userID: "42"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			provider, ok := providers[test.provider]
+			if !ok {
+				t.Fatalf("Provider %s not configured", test.provider)
+			}
+
+			verifier := GenerateVerifier()
+			loginURL, err := url.Parse(provider.AuthCodeURL("state", verifier))
+			if err != nil {
+				t.Fatalf("Failed to parse login URL: %v", err)
+			}
+			challenge = loginURL.Query().Get("code_challenge")
+
+			if loginURL.Query().Get("state") != "state" {
+				t.Errorf("Expected state in login URL, got %s", loginURL.String())
+			}
+
+			profile, err := provider.Exchange(context.Background(), "code", verifier)
+			if err != nil {
+				t.Fatalf("Failed to exchange code: %v", err)
+			}
+
+			if profile.ProviderUserID != test.userID {
+				t.Errorf("Expected provider user ID %s, got %s", test.userID, profile.ProviderUserID)
+			}
+
+			if profile.Email != "jane@example.com" || !profile.EmailVerified {
+				t.Errorf("Expected verified jane@example.com, got %s (verified=%v)", profile.Email, profile.EmailVerified)
+			}
+
+			if profile.FirstName != "Jane" || profile.LastName != "Doe" {
+				t.Errorf("Expected Jane Doe, got %s %s", profile.FirstName, profile.LastName)
+			}
+
+			if _, err := provider.Exchange(context.Background(), "code", GenerateVerifier()); err == nil {
+				t.Errorf("Expected exchange with the wrong verifier to fail")
+			}
+		})
+	}
+}
+
+func TestNewProviders_SkipsUnconfigured(t *testing.T) {
+	providers := NewProviders(&constants.Config{GithubClientID: "github-client"})
+
+	if _, ok := providers[Google]; ok {
+		t.Errorf("Expected google to be skipped without a client ID")
+	}
+
+	if _, ok := providers[Github]; !ok {
+		t.Errorf("Expected github to be configured")
+	}
+}
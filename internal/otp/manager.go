@@ -2,99 +2,304 @@ package otp
 
 import (
 	"crypto/rand"
+	"crypto/subtle"
+	"errors"
 	"fmt"
-	"sync"
+	"math/big"
+	"strconv"
+	"strings"
 	"time"
-)
 
-var OTPManage *OTPManager
+	"github.com/bjorndonald/golang-backend-template/internal/helpers"
+)
 
 type OTPPurpose string
 
 const (
 	WithdrawalOTP        OTPPurpose = "withdrawal"
 	EmailVerificationOTP OTPPurpose = "email_verification"
+	LoginOTP             OTPPurpose = "login"
+	PasswordResetOTP     OTPPurpose = "password_reset"
+	StepUpOTP            OTPPurpose = "step_up"
+	MagicLinkOTP         OTPPurpose = "magic_link"
+)
+
+const (
+	Digits       = "0123456789"
+	Alphanumeric = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+)
+
+// alphabets are the alphabets SetFormats accepts, by name.
+var alphabets = map[string]string{
+	"digits":       Digits,
+	"alphanumeric": Alphanumeric,
+}
+
+// minLength is the shortest code SetFormats accepts.
+const minLength = 4
+
+// Policy controls the shape, lifetime and attempt limits of the codes issued
+// for one purpose.
+type Policy struct {
+	Length      int
+	Alphabet    string
+	TTL         time.Duration
+	MaxAttempts int
+	Cooldown    time.Duration
+}
+
+var defaultPolicy = Policy{
+	Length:      5,
+	Alphabet:    Alphanumeric,
+	TTL:         10 * time.Minute,
+	MaxAttempts: 5,
+	Cooldown:    time.Minute,
+}
+
+// DefaultPolicies are the policies a new OTPManager starts with. Email
+// verification and magic link codes are only ever sent inside a link, so
+// they are long enough that guessing one is not practical.
+var DefaultPolicies = map[OTPPurpose]Policy{
+	LoginOTP:         defaultPolicy,
+	StepUpOTP:        defaultPolicy,
+	WithdrawalOTP:    defaultPolicy,
+	PasswordResetOTP: defaultPolicy,
+	EmailVerificationOTP: {
+		Length:      32,
+		Alphabet:    Alphanumeric,
+		TTL:         10 * time.Minute,
+		MaxAttempts: 5,
+		Cooldown:    time.Minute,
+	},
+	MagicLinkOTP: {
+		Length:      32,
+		Alphabet:    Alphanumeric,
+		TTL:         15 * time.Minute,
+		MaxAttempts: 1,
+		Cooldown:    time.Minute,
+	},
+}
+
+// Outcome is the result of checking a code.
+type Outcome int
+
+const (
+	// OutcomeOK means the code matched and has been used up.
+	OutcomeOK Outcome = iota
+	// OutcomeWrong means the code did not match. The attempt was counted.
+	OutcomeWrong
+	// OutcomeExpired means there is no live code to check against, either
+	// because it expired, was already used or was never issued.
+	OutcomeExpired
+	// OutcomeLocked means the code was burned after too many wrong attempts.
+	OutcomeLocked
 )
 
-// OTP struct represents an OTP with its associated email and expiration time
+func (o Outcome) String() string {
+	switch o {
+	case OutcomeOK:
+		return "ok"
+	case OutcomeWrong:
+		return "wrong"
+	case OutcomeExpired:
+		return "expired"
+	case OutcomeLocked:
+		return "locked"
+	default:
+		return fmt.Sprintf("Outcome(%d)", int(o))
+	}
+}
+
+// ErrResendCooldown is wrapped by the CooldownError returned when a new code
+// is requested too soon after the last one.
+var ErrResendCooldown = errors.New("otp was sent too recently")
+
+// CooldownError tells the caller how long to wait before asking for a new
+// code.
+type CooldownError struct {
+	RetryAfter time.Duration
+}
+
+func (e *CooldownError) Error() string {
+	return fmt.Sprintf("%s, retry in %s", ErrResendCooldown, e.RetryAfter.Round(time.Second))
+}
+
+func (e *CooldownError) Unwrap() error {
+	return ErrResendCooldown
+}
+
+// OTP struct represents an OTP issued to a subject for one purpose. Token
+// is the SHA-256 hash of the code; the code itself is only ever sent.
 type OTP struct {
-	Email     string
+	Subject   string
+	Purpose   OTPPurpose
 	Token     string
+	Attempts  int
+	IssuedAt  time.Time
 	ExpiresAt time.Time
-	Purpose   OTPPurpose
 }
 
 // OTPManager manages the generation and verification of OTPs
 type OTPManager struct {
-	Otps map[string]OTP
-	mu   sync.Mutex
+	store    OTPStore
+	policies map[OTPPurpose]Policy
+}
+
+// NewOTPManager creates a new OTPManager that keeps its codes in store
+func NewOTPManager(store OTPStore) *OTPManager {
+	policies := make(map[OTPPurpose]Policy, len(DefaultPolicies))
+	for purpose, policy := range DefaultPolicies {
+		policies[purpose] = policy
+	}
+
+	return &OTPManager{
+		store:    store,
+		policies: policies,
+	}
+}
+
+// SetPolicy replaces the policy used for purpose.
+func (m *OTPManager) SetPolicy(purpose OTPPurpose, policy Policy) {
+	m.policies[purpose] = policy
 }
 
-// NewOTPManager creates a new OTPManager instance
-func NewOTPManager() *OTPManager {
-	OTPManage = &OTPManager{
-		Otps: make(map[string]OTP),
+// SetFormats changes the length and alphabet of the codes for the purposes
+// in a list such as "login=6:digits,step_up=8:alphanumeric". Purposes that
+// are not listed keep their format. Nothing changes unless the whole list
+// is valid.
+func (m *OTPManager) SetFormats(spec string) error {
+	policies := map[OTPPurpose]Policy{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, format, found := strings.Cut(entry, "=")
+		length, alphabetName, hasAlphabet := strings.Cut(format, ":")
+		purpose := OTPPurpose(strings.TrimSpace(name))
+		policy, known := m.policies[purpose]
+		if !found || !hasAlphabet || !known {
+			return fmt.Errorf("invalid OTP format %q", entry)
+		}
+
+		n, err := strconv.Atoi(strings.TrimSpace(length))
+		alphabet, ok := alphabets[strings.TrimSpace(alphabetName)]
+		if err != nil || n < minLength || !ok {
+			return fmt.Errorf("invalid OTP format %q", entry)
+		}
+
+		policy.Length, policy.Alphabet = n, alphabet
+		policies[purpose] = policy
 	}
-	return OTPManage
+
+	for purpose, policy := range policies {
+		m.policies[purpose] = policy
+	}
+	return nil
 }
 
-// GenerateOTP generates a unique OTP for the given email with a specified expiration time
-func (m *OTPManager) GenerateOTP(email string, expiration time.Duration) (string, error) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	// Check if the OTP exists and has not expired
-	if otp, ok := m.Otps[email]; ok && time.Now().Before(otp.ExpiresAt) {
-		return otp.Token, nil
+// Policy returns the policy used for purpose.
+func (m *OTPManager) Policy(purpose OTPPurpose) Policy {
+	if policy, ok := m.policies[purpose]; ok {
+		return policy
 	}
-	const otpLength = 5
-	// Create a character set consisting of alphanumeric capital letters
-	charSet := "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	charSetLength := len(charSet)
+	return defaultPolicy
+}
+
+// key scopes a code to its purpose so that a code sent for one flow cannot
+// be redeemed in another.
+func key(subject string, purpose OTPPurpose) string {
+	return string(purpose) + ":" + subject
+}
+
+// GenerateOTP issues a new code for subject and purpose, replacing any
+// previous one. It returns a *CooldownError if the last code was issued less
+// than the policy's cooldown ago.
+func (m *OTPManager) GenerateOTP(subject string, purpose OTPPurpose) (string, error) {
+	policy := m.Policy(purpose)
+	k := key(subject, purpose)
 
-	// Generate a random token of fixed length
-	tokenBytes := make([]byte, otpLength)
-	_, err := rand.Read(tokenBytes)
+	existing, ok, err := m.store.Get(k)
 	if err != nil {
-		return "", fmt.Errorf("failed to generate OTP: %w", err)
+		return "", fmt.Errorf("failed to load OTP: %w", err)
 	}
-
-	// Map the random bytes to the character set
-	for i := 0; i < otpLength; i++ {
-		tokenBytes[i] = charSet[tokenBytes[i]%byte(charSetLength)]
+	if ok {
+		if wait := time.Until(existing.IssuedAt.Add(policy.Cooldown)); wait > 0 {
+			return "", &CooldownError{RetryAfter: wait}
+		}
 	}
 
-	token := string(tokenBytes)
+	token, err := randomToken(policy.Length, policy.Alphabet)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate OTP: %w", err)
+	}
 
-	// Calculate the expiration time
-	expiresAt := time.Now().Add(expiration)
-	// Store the OTP in the manager
-	m.Otps[email] = OTP{
-		Email:     email,
-		Token:     token,
-		ExpiresAt: expiresAt,
+	now := time.Now()
+	err = m.store.Set(k, OTP{
+		Subject:   subject,
+		Purpose:   purpose,
+		Token:     helpers.HashToken(token),
+		IssuedAt:  now,
+		ExpiresAt: now.Add(policy.TTL),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to store OTP: %w", err)
 	}
 	return token, nil
 }
 
-// VerifyOTP verifies the provided OTP for the given email
-func (m *OTPManager) VerifyOTP(email, token string) bool {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	// Retrieve the stored OTP for the email
-	otp, ok := m.Otps[email]
-	if !ok {
-		return false
+// VerifyOTP checks token against the code issued to subject for purpose. A
+// matching code is used up. Every check counts towards the policy's attempt
+// limit, and the attempt is counted before the code is compared, so
+// parallel guesses get no more tries than sequential ones.
+func (m *OTPManager) VerifyOTP(subject string, purpose OTPPurpose, token string) (Outcome, error) {
+	policy := m.Policy(purpose)
+	k := key(subject, purpose)
+
+	otp, ok, err := m.store.Get(k)
+	if err != nil {
+		return OutcomeExpired, fmt.Errorf("failed to load OTP: %w", err)
+	}
+	if !ok || time.Now().After(otp.ExpiresAt) {
+		return OutcomeExpired, nil
+	}
+
+	attempts, counted, err := m.store.IncrementAttempts(k, policy.MaxAttempts)
+	if err != nil {
+		return OutcomeExpired, fmt.Errorf("failed to count OTP attempt: %w", err)
 	}
-	// Check if the OTP is expired
-	if time.Now().After(otp.ExpiresAt) {
-		return false
+	if !counted {
+		return OutcomeLocked, nil
+	}
+
+	if subtle.ConstantTimeCompare([]byte(otp.Token), []byte(helpers.HashToken(strings.TrimSpace(token)))) != 1 {
+		if attempts >= policy.MaxAttempts {
+			return OutcomeLocked, nil
+		}
+		return OutcomeWrong, nil
 	}
 
-	// delete the OTP
-	if otp.Token == token {
-		delete(m.Otps, email)
-		return true
+	// Only the request that deletes the code may use it.
+	deleted, err := m.store.Delete(k, otp.Token)
+	if err != nil {
+		return OutcomeExpired, fmt.Errorf("failed to delete OTP: %w", err)
+	}
+	if !deleted {
+		return OutcomeExpired, nil
 	}
+	return OutcomeOK, nil
+}
 
-	return false
+func randomToken(length int, alphabet string) (string, error) {
+	max := big.NewInt(int64(len(alphabet)))
+	token := make([]byte, length)
+	for i := range token {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		token[i] = alphabet[n.Int64()]
+	}
+	return string(token), nil
 }
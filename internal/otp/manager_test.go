@@ -1,6 +1,9 @@
 package otp
 
 import (
+	"errors"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -10,81 +13,102 @@ func TestOTPManager_GenerateOTP(t *testing.T) {
 		name       string
 		email      string
 		expiration time.Duration
+		outcome    Outcome
 	}{
 		{
 			name:       "Valid OTP",
 			email:      "test@example.com",
 			expiration: time.Minute,
+			outcome:    OutcomeOK,
 		},
 		{
 			name:       "Expired OTP",
 			email:      "expired@example.com",
 			expiration: time.Nanosecond,
+			outcome:    OutcomeExpired,
 		},
 	}
 
-	otpManager := NewOTPManager()
-
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			otpToken, err := otpManager.GenerateOTP(test.email, test.expiration)
+			otpManager := NewOTPManager(NewMemoryStore())
+			policy := otpManager.Policy(LoginOTP)
+			policy.TTL = test.expiration
+			otpManager.SetPolicy(LoginOTP, policy)
+
+			otpToken, err := otpManager.GenerateOTP(test.email, LoginOTP)
 			if err != nil {
 				t.Errorf("Failed to generate OTP: %v", err)
 			}
 
-			valid := otpManager.VerifyOTP(test.email, otpToken)
+			time.Sleep(time.Millisecond)
 
-			if test.name == "Valid OTP" && !valid {
-				t.Errorf("OTP verification failed for a valid OTP")
+			outcome, err := otpManager.VerifyOTP(test.email, LoginOTP, otpToken)
+			if err != nil {
+				t.Fatalf("Failed to verify OTP: %v", err)
 			}
 
-			if test.name == "Expired OTP" && valid {
-				t.Errorf("OTP verification succeeded for an expired OTP")
+			if outcome != test.outcome {
+				t.Errorf("Expected %s, got %s", test.outcome, outcome)
 			}
 		})
 	}
 }
+
 func TestGenerateOTP(t *testing.T) {
 	t.Log("Generating random OTP")
 
-	otp := NewOTPManager()
+	otp := NewOTPManager(NewMemoryStore())
 
-	code, _ := otp.GenerateOTP("bjorndonaldb@gmail.com", time.Millisecond*1000)
+	code, _ := otp.GenerateOTP("bjorndonaldb@gmail.com", LoginOTP)
 
-	good := otp.VerifyOTP("bjorndonaldb@gmail.com", code)
+	outcome, _ := otp.VerifyOTP("bjorndonaldb@gmail.com", LoginOTP, code)
 
-	if !good {
+	if outcome != OutcomeOK {
 		t.Errorf("Invalid OTP %s", code)
 	}
 }
 
+func TestOTPManager_GenerateOTP_Policy(t *testing.T) {
+	otpManager := NewOTPManager(NewMemoryStore())
+	otpManager.SetPolicy(StepUpOTP, Policy{Length: 8, Alphabet: Digits, TTL: time.Minute, MaxAttempts: 3})
+
+	code, err := otpManager.GenerateOTP("test@example.com", StepUpOTP)
+	if err != nil {
+		t.Fatalf("Failed to generate OTP: %v", err)
+	}
+
+	if len(code) != 8 || strings.Trim(code, Digits) != "" {
+		t.Errorf("Expected an 8 digit code, got %s", code)
+	}
+}
+
 func TestOTPManager_VerifyOTP_Invalid(t *testing.T) {
-	otpManager := NewOTPManager()
+	otpManager := NewOTPManager(NewMemoryStore())
 
 	email := "test@example.com"
-	expiration := time.Minute
 
 	// Generate OTP
-	_, err := otpManager.GenerateOTP(email, expiration)
+	_, err := otpManager.GenerateOTP(email, LoginOTP)
 	if err != nil {
 		t.Errorf("Failed to generate OTP: %v", err)
 	}
 
 	// Verify OTP with an invalid token
-	valid := otpManager.VerifyOTP(email, "invalid-token")
-	if valid {
-		t.Errorf("OTP verification succeeded for an invalid OTP token")
+	outcome, _ := otpManager.VerifyOTP(email, LoginOTP, "invalid-token")
+	if outcome != OutcomeWrong {
+		t.Errorf("Expected %s for an invalid OTP token, got %s", OutcomeWrong, outcome)
 	}
 }
 
 func TestOTPManager_VerifyOTP_Expired(t *testing.T) {
-	otpManager := NewOTPManager()
+	otpManager := NewOTPManager(NewMemoryStore())
+	otpManager.SetPolicy(LoginOTP, Policy{Length: 5, Alphabet: Alphanumeric, TTL: time.Second, MaxAttempts: 5})
 
 	email := "test@example.com"
-	expiration := time.Second // Set a very short expiration time for testing
 
 	// Generate OTP
-	otpToken, err := otpManager.GenerateOTP(email, expiration)
+	otpToken, err := otpManager.GenerateOTP(email, LoginOTP)
 	if err != nil {
 		t.Errorf("Failed to generate OTP: %v", err)
 	}
@@ -93,8 +117,201 @@ func TestOTPManager_VerifyOTP_Expired(t *testing.T) {
 	time.Sleep(time.Second)
 
 	// Verify OTP
-	valid := otpManager.VerifyOTP(email, otpToken)
-	if valid {
-		t.Errorf("OTP verification succeeded for an expired OTP")
+	outcome, _ := otpManager.VerifyOTP(email, LoginOTP, otpToken)
+	if outcome != OutcomeExpired {
+		t.Errorf("Expected %s for an expired OTP, got %s", OutcomeExpired, outcome)
+	}
+}
+
+func TestOTPManager_VerifyOTP_SingleUse(t *testing.T) {
+	otpManager := NewOTPManager(NewMemoryStore())
+
+	email := "test@example.com"
+
+	otpToken, err := otpManager.GenerateOTP(email, LoginOTP)
+	if err != nil {
+		t.Errorf("Failed to generate OTP: %v", err)
+	}
+
+	if outcome, _ := otpManager.VerifyOTP(email, LoginOTP, otpToken); outcome != OutcomeOK {
+		t.Errorf("OTP verification failed for a valid OTP")
+	}
+
+	if outcome, _ := otpManager.VerifyOTP(email, LoginOTP, otpToken); outcome == OutcomeOK {
+		t.Errorf("OTP verification succeeded for an OTP that was already used")
+	}
+}
+
+func TestOTPManager_VerifyOTP_WrongPurpose(t *testing.T) {
+	otpManager := NewOTPManager(NewMemoryStore())
+
+	email := "test@example.com"
+
+	otpToken, err := otpManager.GenerateOTP(email, PasswordResetOTP)
+	if err != nil {
+		t.Errorf("Failed to generate OTP: %v", err)
+	}
+
+	if outcome, _ := otpManager.VerifyOTP(email, LoginOTP, otpToken); outcome == OutcomeOK {
+		t.Errorf("Password reset OTP was accepted as a login OTP")
+	}
+
+	if outcome, _ := otpManager.VerifyOTP(email, PasswordResetOTP, otpToken); outcome != OutcomeOK {
+		t.Errorf("Expected %s, got %s", OutcomeOK, outcome)
+	}
+}
+
+func TestOTPManager_VerifyOTP_Locked(t *testing.T) {
+	otpManager := NewOTPManager(NewMemoryStore())
+	otpManager.SetPolicy(LoginOTP, Policy{Length: 5, Alphabet: Alphanumeric, TTL: time.Minute, MaxAttempts: 3})
+
+	email := "test@example.com"
+
+	otpToken, err := otpManager.GenerateOTP(email, LoginOTP)
+	if err != nil {
+		t.Errorf("Failed to generate OTP: %v", err)
+	}
+
+	expected := []Outcome{OutcomeWrong, OutcomeWrong, OutcomeLocked}
+	for i, want := range expected {
+		if outcome, _ := otpManager.VerifyOTP(email, LoginOTP, "wrong"); outcome != want {
+			t.Errorf("Attempt %d: expected %s, got %s", i+1, want, outcome)
+		}
+	}
+
+	if outcome, _ := otpManager.VerifyOTP(email, LoginOTP, otpToken); outcome != OutcomeLocked {
+		t.Errorf("Expected the correct code to be refused once locked, got %s", outcome)
+	}
+}
+
+func TestOTPManager_StoresHash(t *testing.T) {
+	store := NewMemoryStore()
+	otpManager := NewOTPManager(store)
+
+	email := "test@example.com"
+
+	otpToken, err := otpManager.GenerateOTP(email, MagicLinkOTP)
+	if err != nil {
+		t.Fatalf("Failed to generate OTP: %v", err)
+	}
+
+	stored, ok, _ := store.Get(key(email, MagicLinkOTP))
+	if !ok || stored.Token == otpToken || strings.Contains(stored.Token, otpToken) {
+		t.Fatalf("Expected only a hash of the code to be stored, got %+v", stored)
+	}
+
+	if outcome, _ := otpManager.VerifyOTP(email, MagicLinkOTP, stored.Token); outcome == OutcomeOK {
+		t.Errorf("The stored hash must not be accepted as the code")
+	}
+}
+
+func TestOTPManager_GenerateOTP_Cooldown(t *testing.T) {
+	otpManager := NewOTPManager(NewMemoryStore())
+
+	email := "test@example.com"
+
+	if _, err := otpManager.GenerateOTP(email, LoginOTP); err != nil {
+		t.Fatalf("Failed to generate OTP: %v", err)
+	}
+
+	_, err := otpManager.GenerateOTP(email, LoginOTP)
+	var cooldown *CooldownError
+	if !errors.As(err, &cooldown) || !errors.Is(err, ErrResendCooldown) {
+		t.Fatalf("Expected a cooldown error, got %v", err)
+	}
+
+	if cooldown.RetryAfter <= 0 || cooldown.RetryAfter > time.Minute {
+		t.Errorf("Unexpected retry after %s", cooldown.RetryAfter)
+	}
+
+	if _, err := otpManager.GenerateOTP(email, PasswordResetOTP); err != nil {
+		t.Errorf("Cooldown should not apply to other purposes: %v", err)
+	}
+}
+
+func TestMemoryStore_DeleteExpired(t *testing.T) {
+	store := NewMemoryStore()
+
+	_ = store.Set("expired@example.com", OTP{Token: "AAAAA", ExpiresAt: time.Now().Add(-time.Minute)})
+	_ = store.Set("valid@example.com", OTP{Token: "BBBBB", ExpiresAt: time.Now().Add(time.Minute)})
+
+	deleted, err := store.DeleteExpired(time.Now())
+	if err != nil {
+		t.Fatalf("Failed to purge OTPs: %v", err)
+	}
+
+	if deleted != 1 {
+		t.Errorf("Expected 1 expired OTP to be purged, got %d", deleted)
+	}
+
+	if _, ok, _ := store.Get("valid@example.com"); !ok {
+		t.Errorf("Valid OTP was purged")
+	}
+}
+
+func TestOTPManager_VerifyOTP_ParallelGuesses(t *testing.T) {
+	otpManager := NewOTPManager(NewMemoryStore())
+	otpManager.SetPolicy(LoginOTP, Policy{Length: 5, Alphabet: Alphanumeric, TTL: time.Minute, MaxAttempts: 3})
+
+	email := "test@example.com"
+
+	otpToken, err := otpManager.GenerateOTP(email, LoginOTP)
+	if err != nil {
+		t.Fatalf("Failed to generate OTP: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	outcomes := make(chan Outcome, 20)
+	for i := 0; i < cap(outcomes); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			outcome, _ := otpManager.VerifyOTP(email, LoginOTP, "wrong")
+			outcomes <- outcome
+		}()
+	}
+	wg.Wait()
+	close(outcomes)
+
+	wrong := 0
+	for outcome := range outcomes {
+		if outcome == OutcomeWrong {
+			wrong++
+		}
+	}
+	if wrong != 2 {
+		t.Errorf("Expected 2 wrong guesses before the code locks, got %d", wrong)
+	}
+
+	if outcome, _ := otpManager.VerifyOTP(email, LoginOTP, otpToken); outcome != OutcomeLocked {
+		t.Errorf("Expected the correct code to be refused once locked, got %s", outcome)
+	}
+}
+
+func TestOTPManager_SetFormats(t *testing.T) {
+	otpManager := NewOTPManager(NewMemoryStore())
+
+	if err := otpManager.SetFormats("login=6:digits, step_up=8:alphanumeric"); err != nil {
+		t.Fatalf("Failed to set formats: %v", err)
+	}
+
+	if policy := otpManager.Policy(LoginOTP); policy.Length != 6 || policy.Alphabet != Digits || policy.MaxAttempts != defaultPolicy.MaxAttempts {
+		t.Errorf("Expected 6 digit login codes with the default limits, got %+v", policy)
+	}
+	if policy := otpManager.Policy(StepUpOTP); policy.Length != 8 || policy.Alphabet != Alphanumeric {
+		t.Errorf("Expected 8 character step up codes, got %+v", policy)
+	}
+}
+
+func TestOTPManager_SetFormats_Invalid(t *testing.T) {
+	for _, spec := range []string{"login=6", "login=3:digits", "login=6:hex", "unknown=6:digits", "login=6:digits,step_up=x:digits"} {
+		otpManager := NewOTPManager(NewMemoryStore())
+
+		if err := otpManager.SetFormats(spec); err == nil {
+			t.Errorf("Expected %q to be refused", spec)
+		}
+		if policy := otpManager.Policy(LoginOTP); policy != defaultPolicy {
+			t.Errorf("Expected %q to leave the login policy alone, got %+v", spec, policy)
+		}
 	}
 }
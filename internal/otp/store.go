@@ -0,0 +1,118 @@
+package otp
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// OTPStore persists issued codes. Implementations must make Delete atomic so
+// that a code can only be redeemed once, even when several replicas share
+// the store.
+type OTPStore interface {
+	// Get returns the code stored under key.
+	Get(key string) (*OTP, bool, error)
+	// Set stores otp under key, replacing any previous code.
+	Set(key string, otp OTP) error
+	// Delete removes the code under key if it still holds the token hash
+	// and reports whether it did.
+	Delete(key, token string) (bool, error)
+	// IncrementAttempts records an attempt against the code under key
+	// unless max have already been made, and returns the new count and
+	// whether it was recorded. The check and the increment must be atomic,
+	// so parallel guesses cannot all get in under the limit.
+	IncrementAttempts(key string, max int) (int, bool, error)
+	// DeleteExpired removes every code that expired before the given time.
+	DeleteExpired(before time.Time) (int64, error)
+}
+
+// MemoryStore keeps codes in process. Codes are lost on restart and are not
+// shared between replicas, so it is only suitable for development and tests.
+type MemoryStore struct {
+	otps map[string]OTP
+	mu   sync.Mutex
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		otps: make(map[string]OTP),
+	}
+}
+
+func (s *MemoryStore) Get(key string) (*OTP, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	otp, ok := s.otps[key]
+	if !ok {
+		return nil, false, nil
+	}
+	return &otp, true, nil
+}
+
+func (s *MemoryStore) Set(key string, otp OTP) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.otps[key] = otp
+	return nil
+}
+
+func (s *MemoryStore) Delete(key, token string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	otp, ok := s.otps[key]
+	if !ok || otp.Token != token {
+		return false, nil
+	}
+
+	delete(s.otps, key)
+	return true, nil
+}
+
+func (s *MemoryStore) IncrementAttempts(key string, max int) (int, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	otp, ok := s.otps[key]
+	if !ok || otp.Attempts >= max {
+		return 0, false, nil
+	}
+
+	otp.Attempts++
+	s.otps[key] = otp
+	return otp.Attempts, true, nil
+}
+
+func (s *MemoryStore) DeleteExpired(before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for key, otp := range s.otps {
+		if otp.ExpiresAt.Before(before) {
+			delete(s.otps, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// Sweep purges expired codes from store every interval until ctx is done.
+func Sweep(ctx context.Context, store OTPStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if _, err := store.DeleteExpired(now); err != nil {
+				log.Printf("could not purge expired otps: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package passkey
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/bjorndonald/golang-backend-template/constants"
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/gofrs/uuid"
+)
+
+// New returns the relying party described by the WEBAUTHN_* settings. Passkeys
+// are created as discoverable credentials when the authenticator supports it
+// so that login does not need an email address.
+func New(cfg *constants.Config) (*webauthn.WebAuthn, error) {
+	var origins []string
+	for _, origin := range strings.Split(cfg.WebAuthnRPOrigins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+
+	return webauthn.New(&webauthn.Config{
+		RPID:          cfg.WebAuthnRPID,
+		RPDisplayName: cfg.WebAuthnRPName,
+		RPOrigins:     origins,
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementPreferred,
+			UserVerification: protocol.VerificationPreferred,
+		},
+	})
+}
+
+// User adapts an account and its stored passkeys to webauthn.User.
+type User struct {
+	account     *models.User
+	credentials []*models.WebAuthnCredential
+}
+
+func NewUser(account *models.User, credentials []*models.WebAuthnCredential) *User {
+	return &User{
+		account:     account,
+		credentials: credentials,
+	}
+}
+
+// Account returns the wrapped user.
+func (u *User) Account() *models.User {
+	return u.account
+}
+
+// WebAuthnID is the user handle stored on the authenticator. It is the raw
+// bytes of the user ID so that a discoverable login can be mapped back to the
+// account.
+func (u *User) WebAuthnID() []byte {
+	return u.account.ID.Bytes()
+}
+
+func (u *User) WebAuthnName() string {
+	return u.account.Email
+}
+
+func (u *User) WebAuthnDisplayName() string {
+	return strings.TrimSpace(u.account.FirstName + " " + u.account.LastName)
+}
+
+func (u *User) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, 0, len(u.credentials))
+	for _, record := range u.credentials {
+		credentials = append(credentials, Credential(record))
+	}
+	return credentials
+}
+
+// Exclusions lists the passkeys the user already has so that an authenticator
+// is not registered twice.
+func (u *User) Exclusions() []protocol.CredentialDescriptor {
+	descriptors := make([]protocol.CredentialDescriptor, 0, len(u.credentials))
+	for _, record := range u.credentials {
+		descriptors = append(descriptors, Credential(record).Descriptor())
+	}
+	return descriptors
+}
+
+// Lookup returns the stored passkey with the given credential ID.
+func (u *User) Lookup(credentialID []byte) (*models.WebAuthnCredential, bool) {
+	for _, record := range u.credentials {
+		if bytes.Equal(record.CredentialID, credentialID) {
+			return record, true
+		}
+	}
+	return nil, false
+}
+
+// UserID maps a user handle returned by the authenticator back to a user ID.
+func UserID(userHandle []byte) (uuid.UUID, error) {
+	return uuid.FromBytes(userHandle)
+}
+
+// Credential converts a stored passkey to the form the webauthn library
+// verifies assertions against.
+func Credential(record *models.WebAuthnCredential) webauthn.Credential {
+	var transports []protocol.AuthenticatorTransport
+	for _, transport := range strings.Split(record.Transports, ",") {
+		if transport != "" {
+			transports = append(transports, protocol.AuthenticatorTransport(transport))
+		}
+	}
+
+	return webauthn.Credential{
+		ID:              record.CredentialID,
+		PublicKey:       record.PublicKey,
+		AttestationType: record.AttestationType,
+		Transport:       transports,
+		Flags: webauthn.CredentialFlags{
+			BackupEligible: record.BackupEligible,
+			BackupState:    record.BackupState,
+		},
+		Authenticator: webauthn.Authenticator{
+			AAGUID:    record.AAGUID,
+			SignCount: record.SignCount,
+		},
+	}
+}
+
+// NewRecord converts a freshly registered credential to the stored form.
+func NewRecord(id, userID uuid.UUID, name string, credential *webauthn.Credential) *models.WebAuthnCredential {
+	transports := make([]string, 0, len(credential.Transport))
+	for _, transport := range credential.Transport {
+		transports = append(transports, string(transport))
+	}
+
+	return &models.WebAuthnCredential{
+		ID:              id,
+		UserID:          userID,
+		Name:            name,
+		CredentialID:    credential.ID,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		AAGUID:          credential.Authenticator.AAGUID,
+		Transports:      strings.Join(transports, ","),
+		SignCount:       credential.Authenticator.SignCount,
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+	}
+}
@@ -0,0 +1,76 @@
+package passkey
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bjorndonald/golang-backend-template/constants"
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/gofrs/uuid"
+)
+
+func TestNew(t *testing.T) {
+	relyingParty, err := New(&constants.Config{
+		WebAuthnRPID:      "example.com",
+		WebAuthnRPName:    "Example",
+		WebAuthnRPOrigins: "https://example.com, https://app.example.com",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create relying party: %v", err)
+	}
+
+	if len(relyingParty.Config.RPOrigins) != 2 || relyingParty.Config.RPOrigins[1] != "https://app.example.com" {
+		t.Errorf("Unexpected origins %v", relyingParty.Config.RPOrigins)
+	}
+}
+
+func TestRecordRoundTrip(t *testing.T) {
+	userID := uuid.Must(uuid.NewV7())
+	credential := &webauthn.Credential{
+		ID:              []byte{1, 2, 3},
+		PublicKey:       []byte{4, 5, 6},
+		AttestationType: "none",
+		Transport:       []protocol.AuthenticatorTransport{protocol.USB, protocol.Internal},
+		Flags:           webauthn.CredentialFlags{BackupEligible: true, BackupState: true},
+		Authenticator:   webauthn.Authenticator{AAGUID: []byte{7}, SignCount: 42},
+	}
+
+	record := NewRecord(uuid.Must(uuid.NewV7()), userID, "Laptop", credential)
+	if record.Transports != "usb,internal" {
+		t.Errorf("Unexpected transports %q", record.Transports)
+	}
+
+	converted := Credential(record)
+	if !bytes.Equal(converted.ID, credential.ID) || !bytes.Equal(converted.PublicKey, credential.PublicKey) {
+		t.Error("Credential ID or public key changed")
+	}
+
+	if converted.Authenticator.SignCount != 42 || !converted.Flags.BackupEligible || len(converted.Transport) != 2 {
+		t.Errorf("Unexpected credential %+v", converted)
+	}
+}
+
+func TestUserHandle(t *testing.T) {
+	account := &models.User{ID: uuid.Must(uuid.NewV7()), Email: "ada@example.com", FirstName: "Ada", LastName: "Lovelace"}
+	record := &models.WebAuthnCredential{CredentialID: []byte{9, 9}}
+	user := NewUser(account, []*models.WebAuthnCredential{record})
+
+	id, err := UserID(user.WebAuthnID())
+	if err != nil || id != account.ID {
+		t.Fatalf("Expected user handle to map back to %s, got %s (%v)", account.ID, id, err)
+	}
+
+	if user.WebAuthnDisplayName() != "Ada Lovelace" {
+		t.Errorf("Unexpected display name %q", user.WebAuthnDisplayName())
+	}
+
+	if _, found := user.Lookup([]byte{9, 9}); !found {
+		t.Error("Expected stored passkey to be found")
+	}
+
+	if _, found := user.Lookup([]byte{1}); found {
+		t.Error("Expected unknown passkey not to be found")
+	}
+}
@@ -0,0 +1,91 @@
+package passwords
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// prefixLength is the length of the hash prefix entries are grouped by, as
+// in the k-anonymity range API of Have I Been Pwned.
+const prefixLength = 5
+
+// BreachedList holds the SHA-1 hashes of passwords known from data
+// breaches, grouped by hash prefix.
+type BreachedList struct {
+	ranges map[string][]string
+}
+
+// LoadBreachedFile reads a breached password list from path.
+func LoadBreachedFile(path string) (*BreachedList, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return LoadBreached(file)
+}
+
+// LoadBreached reads one uppercase or lowercase hex SHA-1 hash per line,
+// optionally followed by ":count" as in the Have I Been Pwned downloads.
+// Blank lines and lines starting with # are skipped.
+func LoadBreached(r io.Reader) (*BreachedList, error) {
+	list := &BreachedList{ranges: map[string][]string{}}
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		hash, _, _ := strings.Cut(text, ":")
+		hash = strings.ToUpper(hash)
+		if len(hash) != sha1.Size*2 {
+			return nil, fmt.Errorf("line %d: not a SHA-1 hash", line)
+		}
+		if _, err := hex.DecodeString(hash); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		prefix := hash[:prefixLength]
+		list.ranges[prefix] = append(list.ranges[prefix], hash[prefixLength:])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, suffixes := range list.ranges {
+		sort.Strings(suffixes)
+	}
+
+	return list, nil
+}
+
+// Contains reports whether password is on the list.
+func (l *BreachedList) Contains(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	suffixes := l.ranges[hash[:prefixLength]]
+	suffix := hash[prefixLength:]
+	i := sort.SearchStrings(suffixes, suffix)
+	return i < len(suffixes) && suffixes[i] == suffix
+}
+
+// Len returns the number of hashes on the list.
+func (l *BreachedList) Len() int {
+	n := 0
+	for _, suffixes := range l.ranges {
+		n += len(suffixes)
+	}
+	return n
+}
@@ -0,0 +1,52 @@
+package passwords
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBreachedList(t *testing.T) {
+	// SHA-1 of "password" with a count, and of "123456" in lowercase.
+	input := `# test list
+5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:3861493
+
+7c4a8d09ca3762af61e59520943dc26494f8941b
+`
+
+	list, err := LoadBreached(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("LoadBreached: %v", err)
+	}
+
+	if list.Len() != 2 {
+		t.Fatalf("expected 2 hashes, got %d", list.Len())
+	}
+
+	for _, password := range []string{"password", "123456"} {
+		if !list.Contains(password) {
+			t.Errorf("expected %q to be on the list", password)
+		}
+	}
+
+	if list.Contains("Velvet-Harbor-91-Quill") {
+		t.Error("expected an unlisted password not to be on the list")
+	}
+}
+
+func TestLoadBreachedRejectsBadLines(t *testing.T) {
+	if _, err := LoadBreached(strings.NewReader("not-a-hash\n")); err == nil {
+		t.Fatal("expected an error for a line that is not a hash")
+	}
+}
+
+func TestCheckBreached(t *testing.T) {
+	list, err := LoadBreached(strings.NewReader("5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8\n"))
+	if err != nil {
+		t.Fatalf("LoadBreached: %v", err)
+	}
+
+	result := Policy{Breached: list}.Check("password")
+	if !result.Breached {
+		t.Fatalf("expected the password to be reported as breached, got %+v", result)
+	}
+}
@@ -0,0 +1,188 @@
+package passwords
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Algorithms a Hasher can produce.
+const (
+	Argon2id = "argon2id"
+	Bcrypt   = "bcrypt"
+)
+
+var (
+	ErrUnknownHash   = errors.New("unrecognised password hash")
+	ErrMalformedHash = errors.New("malformed password hash")
+)
+
+// Argon2Params are the argon2id settings. Memory is in KiB.
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// Hasher hashes new passwords with Algorithm and verifies hashes made by
+// either algorithm. argon2id hashes are stored in the PHC string format,
+// $argon2id$v=19$m=...,t=...,p=...$salt$key, and bcrypt hashes in their
+// usual $2a$ form, so the parameters a hash was made with travel with it.
+type Hasher struct {
+	Algorithm  string
+	Argon2     Argon2Params
+	BcryptCost int
+}
+
+// DefaultHasher is used until SetHasher is called.
+var DefaultHasher = Hasher{
+	Algorithm: Argon2id,
+	Argon2: Argon2Params{
+		Memory:      64 * 1024,
+		Iterations:  3,
+		Parallelism: 2,
+		SaltLength:  16,
+		KeyLength:   32,
+	},
+	BcryptCost: 12,
+}
+
+var (
+	hasherMu sync.RWMutex
+	hasher   = DefaultHasher
+)
+
+// SetHasher replaces the hasher returned by CurrentHasher.
+func SetHasher(h Hasher) {
+	hasherMu.Lock()
+	defer hasherMu.Unlock()
+	hasher = h
+}
+
+// CurrentHasher returns the hasher the application was configured with.
+func CurrentHasher() Hasher {
+	hasherMu.RLock()
+	defer hasherMu.RUnlock()
+	return hasher
+}
+
+// Validate reports settings that would produce weak or unusable hashes.
+func (h Hasher) Validate() error {
+	switch h.Algorithm {
+	case Argon2id:
+		p := h.Argon2
+		if p.Memory < 8*uint32(p.Parallelism) || p.Iterations < 1 || p.Parallelism < 1 || p.SaltLength < 16 || p.KeyLength < 16 {
+			return fmt.Errorf("argon2id parameters too low: %+v", p)
+		}
+	case Bcrypt:
+		if h.BcryptCost < 10 || h.BcryptCost > bcrypt.MaxCost {
+			return fmt.Errorf("bcrypt cost must be between 10 and %d, got %d", bcrypt.MaxCost, h.BcryptCost)
+		}
+	default:
+		return fmt.Errorf("unknown password hash algorithm %q", h.Algorithm)
+	}
+	return nil
+}
+
+// Hash returns the encoded hash of password.
+func (h Hasher) Hash(password string) (string, error) {
+	if h.Algorithm == Bcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.BcryptCost)
+		return string(hash), err
+	}
+
+	p := h.Argon2
+	salt := make([]byte, p.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify reports whether password matches encoded. When it does, rehash
+// reports whether encoded was made with another algorithm or other
+// parameters than h would use now, so the caller can store a new hash.
+func (h Hasher) Verify(password, encoded string) (match, rehash bool, err error) {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		p, salt, key, err := decodeArgon2(encoded)
+		if err != nil {
+			return false, false, err
+		}
+
+		other := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+		if subtle.ConstantTimeCompare(key, other) != 1 {
+			return false, false, nil
+		}
+		return true, h.Algorithm != Argon2id || p != h.Argon2, nil
+
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, false, nil
+		}
+		if err != nil {
+			return false, false, err
+		}
+
+		cost, err := bcrypt.Cost([]byte(encoded))
+		if err != nil {
+			return false, false, err
+		}
+		return true, h.Algorithm != Bcrypt || cost != h.BcryptCost, nil
+
+	default:
+		return false, false, ErrUnknownHash
+	}
+}
+
+func decodeArgon2(encoded string) (Argon2Params, []byte, []byte, error) {
+	var p Argon2Params
+
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return p, nil, nil, ErrMalformedHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return p, nil, nil, ErrMalformedHash
+	}
+	if version != argon2.Version {
+		return p, nil, nil, fmt.Errorf("unsupported argon2 version %d", version)
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return p, nil, nil, ErrMalformedHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, ErrMalformedHash
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return p, nil, nil, ErrMalformedHash
+	}
+
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(key))
+	return p, salt, key, nil
+}
@@ -0,0 +1,117 @@
+package passwords
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Small parameters keep the tests fast.
+var testArgon2 = Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestArgon2idRoundTrip(t *testing.T) {
+	h := Hasher{Algorithm: Argon2id, Argon2: testArgon2}
+
+	hash, err := h.Hash("correct horse battery staple")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Fatalf("expected a PHC argon2id string, got %q", hash)
+	}
+
+	match, rehash, err := h.Verify("correct horse battery staple", hash)
+	if err != nil || !match || rehash {
+		t.Fatalf("expected a match without rehash, got match=%v rehash=%v err=%v", match, rehash, err)
+	}
+
+	match, _, err = h.Verify("wrong", hash)
+	if err != nil || match {
+		t.Fatalf("expected no match, got match=%v err=%v", match, err)
+	}
+}
+
+func TestHashesAreSalted(t *testing.T) {
+	h := Hasher{Algorithm: Argon2id, Argon2: testArgon2}
+
+	first, _ := h.Hash("same password")
+	second, _ := h.Hash("same password")
+	if first == second {
+		t.Fatal("expected two hashes of the same password to differ")
+	}
+}
+
+func TestRehash(t *testing.T) {
+	legacy, err := bcrypt.GenerateFromPassword([]byte("secret"), 4)
+	if err != nil {
+		t.Fatalf("bcrypt: %v", err)
+	}
+
+	current, err := Hasher{Algorithm: Argon2id, Argon2: testArgon2}.Hash("secret")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+
+	stronger := testArgon2
+	stronger.Iterations = 2
+
+	tests := []struct {
+		name   string
+		hasher Hasher
+		hash   string
+		rehash bool
+	}{
+		{name: "low cost bcrypt to argon2id", hasher: Hasher{Algorithm: Argon2id, Argon2: testArgon2}, hash: string(legacy), rehash: true},
+		{name: "low cost bcrypt to higher cost", hasher: Hasher{Algorithm: Bcrypt, BcryptCost: 5}, hash: string(legacy), rehash: true},
+		{name: "bcrypt at current cost", hasher: Hasher{Algorithm: Bcrypt, BcryptCost: 4}, hash: string(legacy)},
+		{name: "argon2id at current parameters", hasher: Hasher{Algorithm: Argon2id, Argon2: testArgon2}, hash: current},
+		{name: "argon2id with new parameters", hasher: Hasher{Algorithm: Argon2id, Argon2: stronger}, hash: current, rehash: true},
+		{name: "argon2id to bcrypt", hasher: Hasher{Algorithm: Bcrypt, BcryptCost: 4}, hash: current, rehash: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, rehash, err := tt.hasher.Verify("secret", tt.hash)
+			if err != nil || !match {
+				t.Fatalf("expected a match, got match=%v err=%v", match, err)
+			}
+			if rehash != tt.rehash {
+				t.Fatalf("expected rehash=%v, got %v", tt.rehash, rehash)
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsUnknownHashes(t *testing.T) {
+	h := DefaultHasher
+
+	for _, encoded := range []string{"", "plaintext", "$argon2id$v=19$m=1024$bad"} {
+		match, _, err := h.Verify("secret", encoded)
+		if match || err == nil {
+			t.Errorf("expected an error for %q, got match=%v err=%v", encoded, match, err)
+		}
+	}
+
+	if _, _, err := h.Verify("secret", "plaintext"); !errors.Is(err, ErrUnknownHash) {
+		t.Errorf("expected ErrUnknownHash, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := DefaultHasher.Validate(); err != nil {
+		t.Fatalf("expected the default hasher to be valid, got %v", err)
+	}
+
+	for _, h := range []Hasher{
+		{Algorithm: Bcrypt, BcryptCost: 4},
+		{Algorithm: Argon2id, Argon2: Argon2Params{Memory: 1024, Iterations: 0, Parallelism: 1, SaltLength: 16, KeyLength: 32}},
+		{Algorithm: "md5"},
+	} {
+		if err := h.Validate(); err == nil {
+			t.Errorf("expected %+v to be rejected", h)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package passwords
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/nbutton23/zxcvbn-go"
+)
+
+// MaxLength is the longest password accepted, in bytes. Longer ones are
+// truncated by bcrypt without warning, and scoring them is slow.
+const MaxLength = 72
+
+// Policy describes what makes a password acceptable. MinClasses counts the
+// kinds of character used out of lowercase letters, uppercase letters,
+// digits and symbols. MinScore is a zxcvbn score from 0, too guessable, to
+// 4, very unguessable. A nil Breached list skips the breach check.
+type Policy struct {
+	MinLength  int
+	MinClasses int
+	MinScore   int
+	Breached   *BreachedList
+}
+
+// DefaultPolicy is used until SetDefault is called.
+var DefaultPolicy = Policy{
+	MinLength: 8,
+	MinScore:  2,
+}
+
+var (
+	mu      sync.RWMutex
+	current = DefaultPolicy
+)
+
+// SetDefault replaces the policy returned by Default.
+func SetDefault(p Policy) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = p
+}
+
+// Default returns the policy the application was configured with.
+func Default() Policy {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// Result is the outcome of checking a password. Problems are phrased to
+// follow the field name, as in "Password is too easy to guess".
+type Result struct {
+	Score    int      `json:"score"`
+	Breached bool     `json:"breached"`
+	Problems []string `json:"problems"`
+}
+
+// OK reports whether the password met the policy.
+func (r Result) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// Check measures password against the policy. userInputs are the user's
+// own details, such as their email and name, which the password may not
+// contain and which make it easier to guess.
+func (p Policy) Check(password string, userInputs ...string) Result {
+	result := Result{Problems: []string{}}
+
+	if n := utf8.RuneCountInString(password); n < p.MinLength {
+		result.Problems = append(result.Problems, fmt.Sprintf("must be at least %d characters long", p.MinLength))
+	}
+
+	if len(password) > MaxLength {
+		result.Problems = append(result.Problems, fmt.Sprintf("must be at most %d bytes long", MaxLength))
+		return result
+	}
+
+	if classes(password) < p.MinClasses {
+		result.Problems = append(result.Problems, fmt.Sprintf("must use at least %d of lowercase letters, uppercase letters, digits and symbols", p.MinClasses))
+	}
+
+	inputs := personalTerms(userInputs)
+	if containsAny(password, inputs) {
+		result.Problems = append(result.Problems, "must not contain your name or email")
+	}
+
+	if password != "" {
+		result.Score = zxcvbn.PasswordStrength(password, inputs).Score
+	}
+	if result.Score < p.MinScore {
+		result.Problems = append(result.Problems, "is too easy to guess")
+	}
+
+	if p.Breached != nil && p.Breached.Contains(password) {
+		result.Breached = true
+		result.Problems = append(result.Problems, "has appeared in a data breach")
+	}
+
+	return result
+}
+
+func classes(password string) int {
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	count := 0
+	for _, used := range []bool{lower, upper, digit, symbol} {
+		if used {
+			count++
+		}
+	}
+	return count
+}
+
+// personalTerms lowercases the user's details and splits the local part
+// out of email addresses. Terms shorter than three characters match too
+// much to be useful.
+func personalTerms(userInputs []string) []string {
+	terms := make([]string, 0, len(userInputs)*2)
+	for _, input := range userInputs {
+		input = strings.ToLower(strings.TrimSpace(input))
+		if local, _, found := strings.Cut(input, "@"); found {
+			terms = append(terms, local)
+		}
+		terms = append(terms, input)
+	}
+
+	kept := terms[:0]
+	for _, term := range terms {
+		if len(term) >= 3 {
+			kept = append(kept, term)
+		}
+	}
+	return kept
+}
+
+func containsAny(password string, terms []string) bool {
+	password = strings.ToLower(password)
+	for _, term := range terms {
+		if strings.Contains(password, term) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package passwords
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	policy := Policy{MinLength: 8, MinClasses: 3, MinScore: 3}
+
+	tests := []struct {
+		name     string
+		password string
+		inputs   []string
+		problem  string
+	}{
+		{name: "strong", password: "Velvet-Harbor-91-Quill"},
+		{name: "too short", password: "aB3$", problem: "at least 8 characters"},
+		{name: "too long", password: strings.Repeat("aB3$", 20), problem: "at most 72 bytes"},
+		{name: "too few classes", password: "velvetharborquillmoss", problem: "at least 3 of"},
+		{name: "common", password: "Password123", problem: "too easy to guess"},
+		{name: "contains name", password: "Velvet-Jonathan-91", inputs: []string{"Jonathan"}, problem: "your name or email"},
+		{name: "contains email", password: "Quill-Jdoe-Harbor-77", inputs: []string{"jdoe@example.com"}, problem: "your name or email"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := policy.Check(tt.password, tt.inputs...)
+
+			if tt.problem == "" {
+				if !result.OK() {
+					t.Fatalf("expected password to pass, got %v", result.Problems)
+				}
+				return
+			}
+
+			for _, problem := range result.Problems {
+				if strings.Contains(problem, tt.problem) {
+					return
+				}
+			}
+			t.Fatalf("expected a problem containing %q, got %v", tt.problem, result.Problems)
+		})
+	}
+}
+
+func TestCheckShortInputsIgnored(t *testing.T) {
+	result := Policy{}.Check("Velvet-Harbor-91-Quill", "Al", "")
+	if !result.OK() {
+		t.Fatalf("expected short user inputs to be ignored, got %v", result.Problems)
+	}
+}
+
+func TestSetDefault(t *testing.T) {
+	defer SetDefault(DefaultPolicy)
+
+	SetDefault(Policy{MinLength: 30})
+	if Default().MinLength != 30 {
+		t.Fatalf("expected the configured policy, got %+v", Default())
+	}
+}
@@ -0,0 +1,128 @@
+package rbac
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Permissions are written resource:action. A * in either half matches
+// anything, and * on its own grants everything.
+const (
+	All = "*"
+
+	UsersRead        = "users:read"
+	UsersWrite       = "users:write"
+	UsersImpersonate = "users:impersonate"
+	RolesRead        = "roles:read"
+	RolesWrite       = "roles:write"
+	APIKeysWrite     = "api_keys:write"
+	AuditRead        = "audit:read"
+)
+
+// Names of the roles every install starts with. They match the values of
+// models.AccountRole so users that only have the old role column keep their
+// access.
+const (
+	UserRoleName  = "user"
+	AdminRoleName = "admin"
+)
+
+// DefaultRoles are seeded when the database is migrated.
+var DefaultRoles = map[string][]string{
+	UserRoleName:  {},
+	AdminRoleName: {All},
+}
+
+var permissionPattern = regexp.MustCompile(`^(\*|(\*|[a-z_]+):(\*|[a-z_]+))$`)
+
+// Valid reports whether permission is well formed.
+func Valid(permission string) bool {
+	return permissionPattern.MatchString(permission)
+}
+
+// Allows reports whether any of granted covers required.
+func Allows(granted []string, required string) bool {
+	resource, action, _ := strings.Cut(required, ":")
+
+	for _, g := range granted {
+		if g == All || g == required {
+			return true
+		}
+
+		gResource, gAction, ok := strings.Cut(g, ":")
+		if !ok {
+			continue
+		}
+		if (gResource == "*" || gResource == resource) && (gAction == "*" || gAction == action) {
+			return true
+		}
+	}
+	return false
+}
+
+// Merge returns the sorted union of several permission sets.
+func Merge(sets ...[]string) []string {
+	seen := map[string]bool{}
+	merged := []string{}
+	for _, set := range sets {
+		for _, permission := range set {
+			if !seen[permission] {
+				seen[permission] = true
+				merged = append(merged, permission)
+			}
+		}
+	}
+	sort.Strings(merged)
+	return merged
+}
+
+// Cache keeps the resolved permissions of users for a short while so that
+// every request does not have to join through their roles.
+type Cache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	permissions []string
+	expiresAt   time.Time
+}
+
+// NewCache creates a Cache that keeps entries for ttl.
+func NewCache(ttl time.Duration) *Cache {
+	return &Cache{ttl: ttl, entries: map[string]cacheEntry{}}
+}
+
+func (c *Cache) Get(userID string) ([]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[userID]
+	if !ok || time.Now().After(entry.expiresAt) {
+		delete(c.entries, userID)
+		return nil, false
+	}
+	return entry.permissions, true
+}
+
+func (c *Cache) Set(userID string, permissions []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[userID] = cacheEntry{permissions: permissions, expiresAt: time.Now().Add(c.ttl)}
+}
+
+// Invalidate forgets one user, or everyone when userID is empty.
+func (c *Cache) Invalidate(userID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if userID == "" {
+		c.entries = map[string]cacheEntry{}
+		return
+	}
+	delete(c.entries, userID)
+}
@@ -0,0 +1,85 @@
+package rbac
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAllows(t *testing.T) {
+	tests := []struct {
+		granted  []string
+		required string
+		allowed  bool
+	}{
+		{granted: []string{All}, required: UsersWrite, allowed: true},
+		{granted: []string{UsersWrite}, required: UsersWrite, allowed: true},
+		{granted: []string{UsersRead}, required: UsersWrite, allowed: false},
+		{granted: []string{"users:*"}, required: UsersWrite, allowed: true},
+		{granted: []string{"users:*"}, required: RolesWrite, allowed: false},
+		{granted: []string{"*:read"}, required: RolesRead, allowed: true},
+		{granted: []string{"*:read"}, required: RolesWrite, allowed: false},
+		{granted: []string{UsersWrite}, required: All, allowed: false},
+		{granted: nil, required: UsersRead, allowed: false},
+	}
+
+	for _, test := range tests {
+		if allowed := Allows(test.granted, test.required); allowed != test.allowed {
+			t.Errorf("Allows(%v, %q) = %v, expected %v", test.granted, test.required, allowed, test.allowed)
+		}
+	}
+}
+
+func TestValid(t *testing.T) {
+	for _, permission := range []string{"*", "users:read", "api_keys:write", "users:*", "*:read"} {
+		if !Valid(permission) {
+			t.Errorf("Expected %q to be valid", permission)
+		}
+	}
+	for _, permission := range []string{"", "users", "Users:read", "users:read:all", "users: read"} {
+		if Valid(permission) {
+			t.Errorf("Expected %q to be invalid", permission)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	merged := Merge([]string{UsersWrite, UsersRead}, nil, []string{UsersRead, RolesRead})
+	expected := []string{RolesRead, UsersRead, UsersWrite}
+	if !reflect.DeepEqual(merged, expected) {
+		t.Errorf("Merge = %v, expected %v", merged, expected)
+	}
+}
+
+func TestCache(t *testing.T) {
+	cache := NewCache(time.Minute)
+
+	if _, ok := cache.Get("a"); ok {
+		t.Error("Expected an empty cache to miss")
+	}
+
+	cache.Set("a", []string{UsersRead})
+	cache.Set("b", []string{All})
+	if permissions, ok := cache.Get("a"); !ok || !reflect.DeepEqual(permissions, []string{UsersRead}) {
+		t.Errorf("Get(a) = %v, %v", permissions, ok)
+	}
+
+	cache.Invalidate("a")
+	if _, ok := cache.Get("a"); ok {
+		t.Error("Expected a to be invalidated")
+	}
+	if _, ok := cache.Get("b"); !ok {
+		t.Error("Expected b to stay cached")
+	}
+
+	cache.Invalidate("")
+	if _, ok := cache.Get("b"); ok {
+		t.Error("Expected the whole cache to be cleared")
+	}
+
+	expired := NewCache(-time.Second)
+	expired.Set("a", []string{UsersRead})
+	if _, ok := expired.Get("a"); ok {
+		t.Error("Expected an expired entry to miss")
+	}
+}
@@ -0,0 +1,57 @@
+package recovery
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+const (
+	// Count is the number of codes issued in one set.
+	Count = 10
+
+	groupLength = 5
+	// Ambiguous characters such as 0/O and 1/I are left out so codes can be
+	// copied from paper.
+	charSet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+)
+
+// Generate returns n codes formatted as XXXXX-XXXXX.
+func Generate(n int) ([]string, error) {
+	codes := make([]string, 0, n)
+
+	for i := 0; i < n; i++ {
+		raw := make([]byte, groupLength*2)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+
+		for j := range raw {
+			raw[j] = charSet[int(raw[j])%len(charSet)]
+		}
+
+		codes = append(codes, string(raw[:groupLength])+"-"+string(raw[groupLength:]))
+	}
+
+	return codes, nil
+}
+
+// Normalize strips separators and whitespace and upper-cases the code so the
+// user can type it in any form.
+func Normalize(code string) string {
+	code = strings.ToUpper(code)
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' || r == '\t' {
+			return -1
+		}
+		return r
+	}, code)
+}
+
+// Hash returns the stored form of a code. The user ID is mixed in so equal
+// codes of different users do not share a hash.
+func Hash(userID, code string) string {
+	sum := sha256.Sum256([]byte(userID + ":" + Normalize(code)))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,45 @@
+package recovery
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	codes, err := Generate(Count)
+	if err != nil {
+		t.Fatalf("Failed to generate codes: %v", err)
+	}
+
+	if len(codes) != Count {
+		t.Fatalf("Expected %d codes, got %d", Count, len(codes))
+	}
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != groupLength*2+1 || code[groupLength] != '-' {
+			t.Errorf("Unexpected code format %s", code)
+		}
+
+		for _, r := range strings.ReplaceAll(code, "-", "") {
+			if !strings.ContainsRune(charSet, r) {
+				t.Errorf("Unexpected character %q in %s", r, code)
+			}
+		}
+
+		if seen[code] {
+			t.Errorf("Duplicate code %s", code)
+		}
+		seen[code] = true
+	}
+}
+
+func TestHash(t *testing.T) {
+	if Hash("user-1", "ABCDE-FGHJK") != Hash("user-1", " abcde fghjk") {
+		t.Errorf("Expected hash to ignore case and separators")
+	}
+
+	if Hash("user-1", "ABCDE-FGHJK") == Hash("user-2", "ABCDE-FGHJK") {
+		t.Errorf("Expected hash to depend on the user")
+	}
+}
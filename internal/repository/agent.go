@@ -2,6 +2,7 @@ package repository
 
 import (
 	"errors"
+	"time"
 
 	"github.com/bjorndonald/golang-backend-template/internal/models"
 	"github.com/gofrs/uuid"
@@ -14,7 +15,11 @@ type AgentRepositoryInterface interface {
 	Exists(id uuid.UUID) (bool, error)
 	FindByCondition(condition, value string) (*models.UserAgent, bool, error)
 	FindByUserID(value uuid.UUID) ([]*models.UserAgent, bool, error)
+	FindByFingerprint(userID uuid.UUID, fingerprint string) (*models.UserAgent, bool, error)
 	Save(loc *models.UserAgent) (*models.UserAgent, error)
+	UpdateColumns(agent *models.UserAgent, columns ...string) error
+	Touch(id uuid.UUID, ip, city, country string) error
+	Delete(id uuid.UUID) error
 }
 
 type AgentRepository struct {
@@ -55,24 +60,37 @@ func (a *AgentRepository) FindByCondition(condition, value string) (*models.User
 	if err != nil {
 		return nil, false, err
 	}
-	if loc.UserID.String() != "" {
+	if loc != nil && loc.UserID != uuid.Nil {
 		return loc, true, nil
 	}
 	return nil, false, nil
 }
 
+// FindByUserID returns the user's devices, most recently seen first.
 func (a *AgentRepository) FindByUserID(user_id uuid.UUID) ([]*models.UserAgent, bool, error) {
 	var loc []*models.UserAgent
-	err := a.database.Raw(`SELECT * FROM user_Agens WHERE user_id = ?`, user_id).Scan(&loc).Error
+	err := a.database.Where("user_id = ?", user_id).Order("last_seen_at DESC").Find(&loc).Error
 	if err != nil {
 		return nil, false, err
 	}
-	if loc != nil {
+	if len(loc) > 0 {
 		return loc, true, nil
 	}
 	return nil, false, nil
 }
 
+func (a *AgentRepository) FindByFingerprint(userID uuid.UUID, fingerprint string) (*models.UserAgent, bool, error) {
+	var agents []*models.UserAgent
+	err := a.database.Where("user_id = ? AND fingerprint = ?", userID, fingerprint).Limit(1).Find(&agents).Error
+	if err != nil {
+		return nil, false, err
+	}
+	if len(agents) == 0 {
+		return nil, false, nil
+	}
+	return agents[0], true, nil
+}
+
 func (a *AgentRepository) Create(agent *models.UserAgent) error {
 	return a.database.Model(&agent).Create(agent).Error
 }
@@ -91,3 +109,23 @@ func (a *AgentRepository) Save(agent *models.UserAgent) (*models.UserAgent, erro
 
 	return agent, nil
 }
+
+func (a *AgentRepository) UpdateColumns(agent *models.UserAgent, columns ...string) error {
+	return a.database.Model(agent).Select(columns).Updates(agent).Error
+}
+
+// Touch records that the device was seen again, and from where.
+func (a *AgentRepository) Touch(id uuid.UUID, ip, city, country string) error {
+	return a.database.Model(&models.UserAgent{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"ip":           ip,
+			"city":         city,
+			"country":      country,
+			"last_seen_at": time.Now(),
+		}).Error
+}
+
+func (a *AgentRepository) Delete(id uuid.UUID) error {
+	return a.database.Where("id = ?", id).Delete(&models.UserAgent{}).Error
+}
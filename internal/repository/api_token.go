@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+type APITokenRepositoryInterface interface {
+	Create(token *models.APIToken) error
+	Find(id uuid.UUID) (*models.APIToken, bool, error)
+	FindByHash(hash string) (*models.APIToken, bool, error)
+	FindByUserID(userID uuid.UUID, kind string) ([]*models.APIToken, error)
+	FindByKind(kind string) ([]*models.APIToken, error)
+	RecordUse(id uuid.UUID, ip string) error
+	Revoke(id uuid.UUID) error
+	RevokeAllForUser(userID uuid.UUID, kind string) error
+}
+
+type APITokenRepository struct {
+	database *gorm.DB
+}
+
+func NewAPITokenRepository(db *gorm.DB) APITokenRepositoryInterface {
+	return &APITokenRepository{
+		database: db,
+	}
+}
+
+func (a *APITokenRepository) Create(token *models.APIToken) error {
+	return a.database.Create(token).Error
+}
+
+func (a *APITokenRepository) Find(id uuid.UUID) (*models.APIToken, bool, error) {
+	var tokens []*models.APIToken
+	err := a.database.Where("id = ?", id).Limit(1).Find(&tokens).Error
+	if err != nil {
+		return nil, false, err
+	}
+	if len(tokens) == 0 {
+		return nil, false, nil
+	}
+	return tokens[0], true, nil
+}
+
+func (a *APITokenRepository) FindByHash(hash string) (*models.APIToken, bool, error) {
+	var tokens []*models.APIToken
+	err := a.database.Where("token_hash = ?", hash).Limit(1).Find(&tokens).Error
+	if err != nil {
+		return nil, false, err
+	}
+	if len(tokens) == 0 {
+		return nil, false, nil
+	}
+	return tokens[0], true, nil
+}
+
+// FindByUserID lists the tokens of one kind a user owns that have not been
+// revoked. Expired tokens are included so the owner can see why they fail.
+func (a *APITokenRepository) FindByUserID(userID uuid.UUID, kind string) ([]*models.APIToken, error) {
+	var tokens []*models.APIToken
+	err := a.database.
+		Where("user_id = ? AND kind = ? AND revoked_at IS NULL", userID, kind).
+		Order("created_at DESC").
+		Find(&tokens).Error
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (a *APITokenRepository) FindByKind(kind string) ([]*models.APIToken, error) {
+	var tokens []*models.APIToken
+	err := a.database.
+		Where("kind = ? AND revoked_at IS NULL", kind).
+		Order("created_at DESC").
+		Find(&tokens).Error
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (a *APITokenRepository) RecordUse(id uuid.UUID, ip string) error {
+	return a.database.Model(&models.APIToken{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{"last_used_at": time.Now(), "last_used_ip": ip}).Error
+}
+
+func (a *APITokenRepository) Revoke(id uuid.UUID) error {
+	return a.database.Model(&models.APIToken{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (a *APITokenRepository) RevokeAllForUser(userID uuid.UUID, kind string) error {
+	return a.database.Model(&models.APIToken{}).
+		Where("user_id = ? AND kind = ? AND revoked_at IS NULL", userID, kind).
+		Update("revoked_at", time.Now()).Error
+}
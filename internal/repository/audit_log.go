@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+	"gorm.io/gorm"
+)
+
+// AuditLogFilter narrows List. Empty fields match every entry.
+type AuditLogFilter struct {
+	ActorID  string
+	TargetID string
+	Action   string
+	Offset   int
+	Limit    int
+}
+
+type AuditLogRepositoryInterface interface {
+	Create(entry *models.AuditLog) error
+	List(filter AuditLogFilter) ([]*models.AuditLog, int64, error)
+}
+
+type AuditLogRepository struct {
+	database *gorm.DB
+}
+
+func NewAuditLogRepository(db *gorm.DB) AuditLogRepositoryInterface {
+	return &AuditLogRepository{
+		database: db,
+	}
+}
+
+func (a *AuditLogRepository) Create(entry *models.AuditLog) error {
+	return a.database.Create(entry).Error
+}
+
+// List returns one page of entries, newest first, and how many match in
+// total.
+func (a *AuditLogRepository) List(filter AuditLogFilter) ([]*models.AuditLog, int64, error) {
+	query := a.database.Model(&models.AuditLog{})
+	if filter.ActorID != "" {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.TargetID != "" {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var entries []*models.AuditLog
+	err := query.Order("created_at DESC").Offset(filter.Offset).Limit(filter.Limit).Find(&entries).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+type DeviceApprovalRepositoryInterface interface {
+	Create(approval *models.DeviceApproval) error
+	Find(id uuid.UUID) (*models.DeviceApproval, bool, error)
+	FindPending(userID uuid.UUID, fingerprint string) (*models.DeviceApproval, bool, error)
+	MarkApproved(id uuid.UUID) (bool, error)
+	ConsumeApproved(userID uuid.UUID, fingerprint string, since time.Time) (bool, error)
+}
+
+type DeviceApprovalRepository struct {
+	database *gorm.DB
+}
+
+func NewDeviceApprovalRepository(db *gorm.DB) DeviceApprovalRepositoryInterface {
+	return &DeviceApprovalRepository{
+		database: db,
+	}
+}
+
+func (a *DeviceApprovalRepository) Create(approval *models.DeviceApproval) error {
+	return a.database.Create(approval).Error
+}
+
+func (a *DeviceApprovalRepository) Find(id uuid.UUID) (*models.DeviceApproval, bool, error) {
+	var approvals []*models.DeviceApproval
+	err := a.database.Where("id = ?", id).Limit(1).Find(&approvals).Error
+	if err != nil {
+		return nil, false, err
+	}
+	if len(approvals) == 0 {
+		return nil, false, nil
+	}
+	return approvals[0], true, nil
+}
+
+// FindPending returns the newest approval for the device that is still
+// waiting for the user.
+func (a *DeviceApprovalRepository) FindPending(userID uuid.UUID, fingerprint string) (*models.DeviceApproval, bool, error) {
+	var approvals []*models.DeviceApproval
+	err := a.database.
+		Where("user_id = ? AND fingerprint = ? AND approved_at IS NULL AND expires_at > ?", userID, fingerprint, time.Now()).
+		Order("created_at DESC").
+		Limit(1).
+		Find(&approvals).Error
+	if err != nil {
+		return nil, false, err
+	}
+	if len(approvals) == 0 {
+		return nil, false, nil
+	}
+	return approvals[0], true, nil
+}
+
+// MarkApproved approves a pending device. It reports false if the approval
+// was already given or has expired.
+func (a *DeviceApprovalRepository) MarkApproved(id uuid.UUID) (bool, error) {
+	result := a.database.Model(&models.DeviceApproval{}).
+		Where("id = ? AND approved_at IS NULL AND expires_at > ?", id, time.Now()).
+		Update("approved_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+// ConsumeApproved uses up an approval given to the device after since. It
+// reports false if there is none, or if another login used it first.
+func (a *DeviceApprovalRepository) ConsumeApproved(userID uuid.UUID, fingerprint string, since time.Time) (bool, error) {
+	var approvals []*models.DeviceApproval
+	err := a.database.
+		Where("user_id = ? AND fingerprint = ? AND approved_at > ? AND used_at IS NULL", userID, fingerprint, since).
+		Order("approved_at DESC").
+		Limit(1).
+		Find(&approvals).Error
+	if err != nil || len(approvals) == 0 {
+		return false, err
+	}
+
+	result := a.database.Model(&models.DeviceApproval{}).
+		Where("id = ? AND used_at IS NULL", approvals[0].ID).
+		Update("used_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
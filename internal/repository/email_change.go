@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+type EmailChangeRepositoryInterface interface {
+	Create(change *models.EmailChange) error
+	Find(id uuid.UUID) (*models.EmailChange, bool, error)
+	CancelPending(userID uuid.UUID) error
+	MarkConfirmed(id uuid.UUID) (bool, error)
+	MarkReverted(id uuid.UUID) (bool, error)
+}
+
+type EmailChangeRepository struct {
+	database *gorm.DB
+}
+
+func NewEmailChangeRepository(db *gorm.DB) EmailChangeRepositoryInterface {
+	return &EmailChangeRepository{
+		database: db,
+	}
+}
+
+func (a *EmailChangeRepository) Create(change *models.EmailChange) error {
+	return a.database.Create(change).Error
+}
+
+func (a *EmailChangeRepository) Find(id uuid.UUID) (*models.EmailChange, bool, error) {
+	var changes []*models.EmailChange
+	err := a.database.Where("id = ?", id).Limit(1).Find(&changes).Error
+	if err != nil {
+		return nil, false, err
+	}
+	if len(changes) == 0 {
+		return nil, false, nil
+	}
+	return changes[0], true, nil
+}
+
+// CancelPending reverts every change of the user that has not been
+// confirmed, so only the latest request can be confirmed.
+func (a *EmailChangeRepository) CancelPending(userID uuid.UUID) error {
+	return a.database.Model(&models.EmailChange{}).
+		Where("user_id = ? AND confirmed_at IS NULL AND reverted_at IS NULL", userID).
+		Update("reverted_at", time.Now()).Error
+}
+
+// MarkConfirmed confirms a pending change. It reports false if the change
+// was confirmed or reverted by another request first.
+func (a *EmailChangeRepository) MarkConfirmed(id uuid.UUID) (bool, error) {
+	result := a.database.Model(&models.EmailChange{}).
+		Where("id = ? AND confirmed_at IS NULL AND reverted_at IS NULL", id).
+		Update("confirmed_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+// MarkReverted reverts a change, confirmed or not. It reports false if it
+// had already been reverted.
+func (a *EmailChangeRepository) MarkReverted(id uuid.UUID) (bool, error) {
+	result := a.database.Model(&models.EmailChange{}).
+		Where("id = ? AND reverted_at IS NULL", id).
+		Update("reverted_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+type IdentityRepositoryInterface interface {
+	Create(identity *models.Identity) error
+	FindByProvider(provider, providerUserID string) (*models.Identity, bool, error)
+	FindByUserID(userID uuid.UUID) ([]*models.Identity, error)
+}
+
+type IdentityRepository struct {
+	database *gorm.DB
+}
+
+func NewIdentityRepository(db *gorm.DB) IdentityRepositoryInterface {
+	return &IdentityRepository{
+		database: db,
+	}
+}
+
+func (a *IdentityRepository) Create(identity *models.Identity) error {
+	return a.database.Model(&identity).Create(identity).Error
+}
+
+func (a *IdentityRepository) FindByProvider(provider, providerUserID string) (*models.Identity, bool, error) {
+	var identities []*models.Identity
+	err := a.database.Where("provider = ? AND provider_user_id = ?", provider, providerUserID).Limit(1).Find(&identities).Error
+	if err != nil {
+		return nil, false, err
+	}
+	if len(identities) == 0 {
+		return nil, false, nil
+	}
+	return identities[0], true, nil
+}
+
+func (a *IdentityRepository) FindByUserID(userID uuid.UUID) ([]*models.Identity, error) {
+	var identities []*models.Identity
+	err := a.database.Where("user_id = ?", userID).Find(&identities).Error
+	if err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
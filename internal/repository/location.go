@@ -2,6 +2,7 @@ package repository
 
 import (
 	"errors"
+	"time"
 
 	"github.com/bjorndonald/golang-backend-template/internal/models"
 	"github.com/gofrs/uuid"
@@ -14,7 +15,9 @@ type LocationRepositoryInterface interface {
 	Exists(id uuid.UUID) (bool, error)
 	FindByCondition(condition, value string) (*models.GeoLocation, bool, error)
 	FindByUserID(value uuid.UUID) ([]*models.GeoLocation, bool, error)
+	FindByPlace(userID uuid.UUID, place models.GeoLocation) (*models.GeoLocation, bool, error)
 	Save(loc *models.GeoLocation) (*models.GeoLocation, error)
+	Touch(id uuid.UUID, ip string) error
 }
 
 type LocationRepository struct {
@@ -55,24 +58,43 @@ func (a *LocationRepository) FindByCondition(condition, value string) (*models.G
 	if err != nil {
 		return nil, false, err
 	}
-	if loc.UserID.String() != "" {
+	if loc != nil && loc.UserID != uuid.Nil {
 		return loc, true, nil
 	}
 	return nil, false, nil
 }
 
+// FindByUserID returns the places the user logged in from, most recently
+// seen first.
 func (a *LocationRepository) FindByUserID(user_id uuid.UUID) ([]*models.GeoLocation, bool, error) {
 	var loc []*models.GeoLocation
-	err := a.database.Raw(`SELECT * FROM user_locations WHERE user_id = ?`, user_id).Scan(&loc).Error
+	err := a.database.Where("user_id = ?", user_id).Order("last_seen_at DESC").Find(&loc).Error
 	if err != nil {
 		return nil, false, err
 	}
-	if loc != nil {
+	if len(loc) > 0 {
 		return loc, true, nil
 	}
 	return nil, false, nil
 }
 
+// FindByPlace returns the user's location with the city, region and country
+// of place.
+func (a *LocationRepository) FindByPlace(userID uuid.UUID, place models.GeoLocation) (*models.GeoLocation, bool, error) {
+	var locations []*models.GeoLocation
+	err := a.database.
+		Where("user_id = ? AND city = ? AND region = ? AND country = ?", userID, place.City, place.Region, place.Country).
+		Limit(1).
+		Find(&locations).Error
+	if err != nil {
+		return nil, false, err
+	}
+	if len(locations) == 0 {
+		return nil, false, nil
+	}
+	return locations[0], true, nil
+}
+
 func (a *LocationRepository) Create(loc *models.GeoLocation) error {
 	return a.database.Model(&loc).Create(loc).Error
 }
@@ -91,3 +113,10 @@ func (a *LocationRepository) Save(loc *models.GeoLocation) (*models.GeoLocation,
 
 	return loc, nil
 }
+
+// Touch records that the user was seen at the location again.
+func (a *LocationRepository) Touch(id uuid.UUID, ip string) error {
+	return a.database.Model(&models.GeoLocation{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{"ip": ip, "last_seen_at": time.Now()}).Error
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+// LoginEventFilter narrows List. Empty fields match every event. Before is
+// the cursor: only events older than it are listed.
+type LoginEventFilter struct {
+	UserID string
+	Email  string
+	Type   string
+	IP     string
+	Before uuid.UUID
+	Limit  int
+}
+
+type LoginEventRepositoryInterface interface {
+	Create(event *models.LoginEvent) error
+	List(filter LoginEventFilter) ([]*models.LoginEvent, error)
+}
+
+type LoginEventRepository struct {
+	database *gorm.DB
+}
+
+func NewLoginEventRepository(db *gorm.DB) LoginEventRepositoryInterface {
+	return &LoginEventRepository{
+		database: db,
+	}
+}
+
+func (a *LoginEventRepository) Create(event *models.LoginEvent) error {
+	return a.database.Create(event).Error
+}
+
+// List returns up to Limit events, newest first. IDs are UUIDv7, so ordering
+// by ID orders by time and makes a stable cursor.
+func (a *LoginEventRepository) List(filter LoginEventFilter) ([]*models.LoginEvent, error) {
+	query := a.database.Model(&models.LoginEvent{})
+	if filter.UserID != "" {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.Email != "" {
+		query = query.Where("email = ?", filter.Email)
+	}
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.IP != "" {
+		query = query.Where("ip = ?", filter.IP)
+	}
+	if filter.Before != uuid.Nil {
+		query = query.Where("id < ?", filter.Before)
+	}
+
+	var events []*models.LoginEvent
+	err := query.Order("id DESC").Limit(filter.Limit).Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
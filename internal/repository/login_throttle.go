@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type LoginThrottleRepositoryInterface interface {
+	Find(key string) (*models.LoginThrottle, bool, error)
+	RecordFailure(key string, window time.Duration) (*models.LoginThrottle, error)
+	Block(key string, until time.Time) error
+	Reset(key string) error
+}
+
+type LoginThrottleRepository struct {
+	database *gorm.DB
+}
+
+func NewLoginThrottleRepository(db *gorm.DB) LoginThrottleRepositoryInterface {
+	return &LoginThrottleRepository{
+		database: db,
+	}
+}
+
+func (a *LoginThrottleRepository) Find(key string) (*models.LoginThrottle, bool, error) {
+	var throttles []*models.LoginThrottle
+	err := a.database.Where("key = ?", key).Limit(1).Find(&throttles).Error
+	if err != nil {
+		return nil, false, err
+	}
+	if len(throttles) == 0 {
+		return nil, false, nil
+	}
+	return throttles[0], true, nil
+}
+
+// RecordFailure counts a failed attempt against key and returns the updated
+// counter. The count starts again when the last failure is older than window.
+func (a *LoginThrottleRepository) RecordFailure(key string, window time.Duration) (*models.LoginThrottle, error) {
+	now := time.Now()
+	throttle := &models.LoginThrottle{
+		Key:           key,
+		Failures:      1,
+		LastFailureAt: now,
+	}
+
+	err := a.database.Clauses(
+		clause.OnConflict{
+			Columns: []clause.Column{{Name: "key"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"failures":        gorm.Expr("CASE WHEN login_throttles.last_failure_at < ? THEN 1 ELSE login_throttles.failures + 1 END", now.Add(-window)),
+				"last_failure_at": now,
+			}),
+		},
+		clause.Returning{},
+	).Create(throttle).Error
+	if err != nil {
+		return nil, err
+	}
+	return throttle, nil
+}
+
+func (a *LoginThrottleRepository) Block(key string, until time.Time) error {
+	return a.database.Model(&models.LoginThrottle{}).
+		Where("key = ?", key).
+		Update("blocked_until", until).Error
+}
+
+func (a *LoginThrottleRepository) Reset(key string) error {
+	return a.database.Where("key = ?", key).Delete(&models.LoginThrottle{}).Error
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+	"github.com/bjorndonald/golang-backend-template/internal/otp"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OTPRepository is the Postgres backed otp.OTPStore. It lets a code issued by
+// one replica be redeemed on another and survives restarts.
+type OTPRepository struct {
+	database *gorm.DB
+}
+
+func NewOTPRepository(db *gorm.DB) otp.OTPStore {
+	return &OTPRepository{
+		database: db,
+	}
+}
+
+func (a *OTPRepository) Get(key string) (*otp.OTP, bool, error) {
+	var records []*models.OneTimePassword
+	err := a.database.Where("key = ?", key).Limit(1).Find(&records).Error
+	if err != nil {
+		return nil, false, err
+	}
+	if len(records) == 0 {
+		return nil, false, nil
+	}
+	return &otp.OTP{
+		Subject:   records[0].Subject,
+		Purpose:   otp.OTPPurpose(records[0].Purpose),
+		Token:     records[0].Token,
+		Attempts:  records[0].Attempts,
+		IssuedAt:  records[0].IssuedAt,
+		ExpiresAt: records[0].ExpiresAt,
+	}, true, nil
+}
+
+func (a *OTPRepository) Set(key string, code otp.OTP) error {
+	record := &models.OneTimePassword{
+		Key:       key,
+		Subject:   code.Subject,
+		Purpose:   string(code.Purpose),
+		Token:     code.Token,
+		Attempts:  code.Attempts,
+		IssuedAt:  code.IssuedAt,
+		ExpiresAt: code.ExpiresAt,
+	}
+	return a.database.Clauses(clause.OnConflict{UpdateAll: true}).Create(record).Error
+}
+
+func (a *OTPRepository) Delete(key, token string) (bool, error) {
+	result := a.database.Where("key = ? AND token = ?", key, token).Delete(&models.OneTimePassword{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (a *OTPRepository) IncrementAttempts(key string, max int) (int, bool, error) {
+	var record models.OneTimePassword
+	result := a.database.Model(&record).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "attempts"}}}).
+		Where("key = ? AND attempts < ?", key, max).
+		UpdateColumn("attempts", gorm.Expr("attempts + 1"))
+	if result.Error != nil {
+		return 0, false, result.Error
+	}
+	return record.Attempts, result.RowsAffected == 1, nil
+}
+
+func (a *OTPRepository) DeleteExpired(before time.Time) (int64, error) {
+	result := a.database.Where("expires_at < ?", before).Delete(&models.OneTimePassword{})
+	return result.RowsAffected, result.Error
+}
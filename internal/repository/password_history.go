@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+type PasswordHistoryRepositoryInterface interface {
+	Add(userID uuid.UUID, hash string) error
+	Recent(userID uuid.UUID) ([]*models.PasswordHistory, error)
+}
+
+// PasswordHistoryRepository keeps the last depth password hashes of each
+// user. A depth of zero keeps none.
+type PasswordHistoryRepository struct {
+	database *gorm.DB
+	depth    int
+}
+
+func NewPasswordHistoryRepository(db *gorm.DB, depth int) PasswordHistoryRepositoryInterface {
+	return &PasswordHistoryRepository{
+		database: db,
+		depth:    depth,
+	}
+}
+
+// Add records a replaced password hash and drops the user's entries that
+// fall outside the kept depth.
+func (a *PasswordHistoryRepository) Add(userID uuid.UUID, hash string) error {
+	if a.depth <= 0 || hash == "" {
+		return nil
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return err
+	}
+
+	return a.database.Transaction(func(tx *gorm.DB) error {
+		entry := &models.PasswordHistory{
+			ID:           id,
+			UserID:       userID,
+			PasswordHash: hash,
+			CreatedAt:    time.Now(),
+		}
+		if err := tx.Create(entry).Error; err != nil {
+			return err
+		}
+
+		kept := tx.Model(&models.PasswordHistory{}).
+			Select("id").
+			Where("user_id = ?", userID).
+			Order("created_at DESC, id DESC").
+			Limit(a.depth)
+
+		return tx.Where("user_id = ? AND id NOT IN (?)", userID, kept).
+			Delete(&models.PasswordHistory{}).Error
+	})
+}
+
+// Recent returns the user's kept password hashes, newest first.
+func (a *PasswordHistoryRepository) Recent(userID uuid.UUID) ([]*models.PasswordHistory, error) {
+	var entries []*models.PasswordHistory
+	if a.depth <= 0 {
+		return entries, nil
+	}
+
+	err := a.database.Where("user_id = ?", userID).
+		Order("created_at DESC, id DESC").
+		Limit(a.depth).
+		Find(&entries).Error
+	return entries, err
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+type RecoveryCodeRepositoryInterface interface {
+	Replace(userID uuid.UUID, codes []*models.RecoveryCode) error
+	Consume(userID uuid.UUID, hash string) (bool, error)
+	CountUnused(userID uuid.UUID) (int64, error)
+	Issued(userID uuid.UUID) (bool, error)
+}
+
+type RecoveryCodeRepository struct {
+	database *gorm.DB
+}
+
+func NewRecoveryCodeRepository(db *gorm.DB) RecoveryCodeRepositoryInterface {
+	return &RecoveryCodeRepository{
+		database: db,
+	}
+}
+
+// Replace deletes the user's previous codes and stores the new set.
+func (a *RecoveryCodeRepository) Replace(userID uuid.UUID, codes []*models.RecoveryCode) error {
+	return a.database.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(codes).Error
+	})
+}
+
+// Consume marks the matching unused code as used. It reports false when no
+// such code exists.
+func (a *RecoveryCodeRepository) Consume(userID uuid.UUID, hash string) (bool, error) {
+	txn := a.database.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", time.Now())
+	if txn.Error != nil {
+		return false, txn.Error
+	}
+	return txn.RowsAffected == 1, nil
+}
+
+func (a *RecoveryCodeRepository) CountUnused(userID uuid.UUID) (int64, error) {
+	var count int64
+	err := a.database.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
+
+// Issued reports whether the user has ever been given recovery codes. Used
+// codes are kept until the set is replaced, so they count too.
+func (a *RecoveryCodeRepository) Issued(userID uuid.UUID) (bool, error) {
+	var count int64
+	err := a.database.Model(&models.RecoveryCode{}).
+		Where("user_id = ?", userID).
+		Count(&count).Error
+	return count > 0, err
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+type RefreshTokenRepositoryInterface interface {
+	Create(token *models.RefreshToken) error
+	FindByHash(hash string) (*models.RefreshToken, bool, error)
+	MarkUsed(id uuid.UUID) (bool, error)
+	RevokeFamily(familyID uuid.UUID) error
+	RevokeAllForUser(userID, keepFamily uuid.UUID) error
+}
+
+type RefreshTokenRepository struct {
+	database *gorm.DB
+}
+
+func NewRefreshTokenRepository(db *gorm.DB) RefreshTokenRepositoryInterface {
+	return &RefreshTokenRepository{
+		database: db,
+	}
+}
+
+func (a *RefreshTokenRepository) Create(token *models.RefreshToken) error {
+	return a.database.Model(&token).Create(token).Error
+}
+
+func (a *RefreshTokenRepository) FindByHash(hash string) (*models.RefreshToken, bool, error) {
+	var tokens []*models.RefreshToken
+	err := a.database.Where("token_hash = ?", hash).Limit(1).Find(&tokens).Error
+	if err != nil {
+		return nil, false, err
+	}
+	if len(tokens) == 0 {
+		return nil, false, nil
+	}
+	return tokens[0], true, nil
+}
+
+// MarkUsed flags the token as consumed. It reports false when the token had
+// already been used, which callers treat as a replay.
+func (a *RefreshTokenRepository) MarkUsed(id uuid.UUID) (bool, error) {
+	txn := a.database.Model(&models.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	if txn.Error != nil {
+		return false, txn.Error
+	}
+	return txn.RowsAffected == 1, nil
+}
+
+func (a *RefreshTokenRepository) RevokeFamily(familyID uuid.UUID) error {
+	return a.database.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeAllForUser revokes every refresh token of the user except those in
+// keepFamily. Pass uuid.Nil to revoke all of them.
+func (a *RefreshTokenRepository) RevokeAllForUser(userID, keepFamily uuid.UUID) error {
+	return a.database.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND family_id <> ? AND revoked_at IS NULL", userID, keepFamily).
+		Update("revoked_at", time.Now()).Error
+}
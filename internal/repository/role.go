@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+	"github.com/bjorndonald/golang-backend-template/internal/rbac"
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// permissionCache is shared by every RoleRepository so that changes made
+// through one are seen by the others on this instance. Other instances pick
+// them up when the entries expire.
+var permissionCache = rbac.NewCache(time.Minute)
+
+type RoleRepositoryInterface interface {
+	Create(role *models.Role) error
+	Find(id uuid.UUID) (*models.Role, bool, error)
+	FindByName(name string) (*models.Role, bool, error)
+	FindAll() ([]*models.Role, error)
+	Update(role *models.Role) error
+	Delete(id uuid.UUID) error
+	FindByUserID(userID uuid.UUID) ([]*models.Role, error)
+	Assign(userID, roleID uuid.UUID) error
+	Unassign(userID, roleID uuid.UUID) error
+	PermissionsForUser(user *models.User) ([]string, error)
+	Forget(userID uuid.UUID)
+}
+
+type RoleRepository struct {
+	database *gorm.DB
+}
+
+func NewRoleRepository(db *gorm.DB) RoleRepositoryInterface {
+	return &RoleRepository{
+		database: db,
+	}
+}
+
+func (a *RoleRepository) Create(role *models.Role) error {
+	return a.database.Create(role).Error
+}
+
+func (a *RoleRepository) Find(id uuid.UUID) (*models.Role, bool, error) {
+	var roles []*models.Role
+	err := a.database.Where("id = ?", id).Limit(1).Find(&roles).Error
+	if err != nil {
+		return nil, false, err
+	}
+	if len(roles) == 0 {
+		return nil, false, nil
+	}
+	return roles[0], true, nil
+}
+
+func (a *RoleRepository) FindByName(name string) (*models.Role, bool, error) {
+	var roles []*models.Role
+	err := a.database.Where("name = ?", name).Limit(1).Find(&roles).Error
+	if err != nil {
+		return nil, false, err
+	}
+	if len(roles) == 0 {
+		return nil, false, nil
+	}
+	return roles[0], true, nil
+}
+
+func (a *RoleRepository) FindAll() ([]*models.Role, error) {
+	var roles []*models.Role
+	err := a.database.Order("name").Find(&roles).Error
+	if err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (a *RoleRepository) Update(role *models.Role) error {
+	err := a.database.Model(role).
+		Select("Description", "Permissions", "UpdatedAt").
+		Updates(role).Error
+	if err != nil {
+		return err
+	}
+
+	permissionCache.Invalidate("")
+	return nil
+}
+
+// Delete removes a role and every assignment of it.
+func (a *RoleRepository) Delete(id uuid.UUID) error {
+	err := a.database.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role_id = ?", id).Delete(&models.RoleAssignment{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", id).Delete(&models.Role{}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	permissionCache.Invalidate("")
+	return nil
+}
+
+// FindByUserID lists the roles assigned to a user, not counting the one
+// named by their Role column.
+func (a *RoleRepository) FindByUserID(userID uuid.UUID) ([]*models.Role, error) {
+	var roles []*models.Role
+	err := a.database.
+		Joins("JOIN role_assignments ON role_assignments.role_id = roles.id").
+		Where("role_assignments.user_id = ?", userID).
+		Order("roles.name").
+		Find(&roles).Error
+	if err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (a *RoleRepository) Assign(userID, roleID uuid.UUID) error {
+	err := a.database.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.RoleAssignment{
+		UserID:    userID,
+		RoleID:    roleID,
+		CreatedAt: time.Now(),
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	permissionCache.Invalidate(userID.String())
+	return nil
+}
+
+func (a *RoleRepository) Unassign(userID, roleID uuid.UUID) error {
+	err := a.database.Where("user_id = ? AND role_id = ?", userID, roleID).Delete(&models.RoleAssignment{}).Error
+	if err != nil {
+		return err
+	}
+
+	permissionCache.Invalidate(userID.String())
+	return nil
+}
+
+// PermissionsForUser returns the union of the permissions of the user's
+// assigned roles and of the role named by their Role column.
+func (a *RoleRepository) PermissionsForUser(user *models.User) ([]string, error) {
+	key := user.ID.String()
+	if permissions, ok := permissionCache.Get(key); ok {
+		return permissions, nil
+	}
+
+	var roles []*models.Role
+	err := a.database.
+		Distinct("roles.*").
+		Joins("LEFT JOIN role_assignments ON role_assignments.role_id = roles.id AND role_assignments.user_id = ?", user.ID).
+		Where("role_assignments.user_id IS NOT NULL OR roles.name = ?", string(user.Role)).
+		Find(&roles).Error
+	if err != nil {
+		return nil, err
+	}
+
+	sets := make([][]string, 0, len(roles))
+	for _, role := range roles {
+		sets = append(sets, role.Permissions)
+	}
+
+	permissions := rbac.Merge(sets...)
+	permissionCache.Set(key, permissions)
+	return permissions, nil
+}
+
+// Forget drops the cached permissions of a user. It has to be called when
+// their Role column changes.
+func (a *RoleRepository) Forget(userID uuid.UUID) {
+	permissionCache.Invalidate(userID.String())
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+type SessionRepositoryInterface interface {
+	Create(session *models.Session) error
+	Find(id uuid.UUID) (*models.Session, bool, error)
+	FindActiveByUserID(userID uuid.UUID) ([]*models.Session, error)
+	Touch(id uuid.UUID) error
+	Extend(id uuid.UUID, expiresAt time.Time) error
+	Revoke(id uuid.UUID) error
+	RevokeAllForUser(userID, keep uuid.UUID) error
+	Latest(userID uuid.UUID) (*models.Session, bool, error)
+	SeenCountry(userID uuid.UUID, country string) (bool, error)
+}
+
+type SessionRepository struct {
+	database *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) SessionRepositoryInterface {
+	return &SessionRepository{
+		database: db,
+	}
+}
+
+func (a *SessionRepository) Create(session *models.Session) error {
+	return a.database.Model(&session).Create(session).Error
+}
+
+func (a *SessionRepository) Find(id uuid.UUID) (*models.Session, bool, error) {
+	var sessions []*models.Session
+	err := a.database.Where("id = ?", id).Limit(1).Find(&sessions).Error
+	if err != nil {
+		return nil, false, err
+	}
+	if len(sessions) == 0 {
+		return nil, false, nil
+	}
+	return sessions[0], true, nil
+}
+
+func (a *SessionRepository) FindActiveByUserID(userID uuid.UUID) ([]*models.Session, error) {
+	var sessions []*models.Session
+	err := a.database.
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (a *SessionRepository) Touch(id uuid.UUID) error {
+	return a.database.Model(&models.Session{}).
+		Where("id = ?", id).
+		Update("last_seen_at", time.Now()).Error
+}
+
+func (a *SessionRepository) Extend(id uuid.UUID, expiresAt time.Time) error {
+	return a.database.Model(&models.Session{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{"last_seen_at": time.Now(), "expires_at": expiresAt}).Error
+}
+
+func (a *SessionRepository) Revoke(id uuid.UUID) error {
+	return a.database.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeAllForUser revokes every session of the user except keep. Pass
+// uuid.Nil to revoke all of them.
+func (a *SessionRepository) RevokeAllForUser(userID, keep uuid.UUID) error {
+	return a.database.Model(&models.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, keep).
+		Update("revoked_at", time.Now()).Error
+}
+
+// Latest returns the user's most recent session, revoked or not.
+func (a *SessionRepository) Latest(userID uuid.UUID) (*models.Session, bool, error) {
+	var sessions []*models.Session
+	err := a.database.Where("user_id = ?", userID).Order("created_at DESC").Limit(1).Find(&sessions).Error
+	if err != nil {
+		return nil, false, err
+	}
+	if len(sessions) == 0 {
+		return nil, false, nil
+	}
+	return sessions[0], true, nil
+}
+
+// SeenCountry reports whether the user ever logged in from country.
+func (a *SessionRepository) SeenCountry(userID uuid.UUID, country string) (bool, error) {
+	var count int64
+	err := a.database.Model(&models.Session{}).
+		Where("user_id = ? AND country = ?", userID, country).
+		Count(&count).Error
+	return count > 0, err
+}
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type UsedTokenRepositoryInterface interface {
+	Consume(id string, expiresAt time.Time) (bool, error)
+}
+
+type UsedTokenRepository struct {
+	database *gorm.DB
+}
+
+func NewUsedTokenRepository(db *gorm.DB) UsedTokenRepositoryInterface {
+	return &UsedTokenRepository{
+		database: db,
+	}
+}
+
+// Consume marks the token with jti id as used. It reports false when the
+// token had already been used, so only one request can ever spend it.
+func (a *UsedTokenRepository) Consume(id string, expiresAt time.Time) (bool, error) {
+	result := a.database.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.UsedToken{
+		ID:        id,
+		UsedAt:    time.Now(),
+		ExpiresAt: expiresAt,
+	})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
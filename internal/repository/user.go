@@ -3,6 +3,7 @@ package repository
 import (
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/bjorndonald/golang-backend-template/internal/models"
 	"github.com/gofrs/uuid"
@@ -19,9 +20,29 @@ type UserRepositoryInterface interface {
 	FindByAccountType(value string) ([]*models.User, bool, error)
 	RawSmartSelect(q string, res interface{}, args ...interface{}) error
 	Save(user *models.User) (*models.User, error)
+	UpdateColumns(user *models.User, columns ...string) error
 	Delete(id string) (*models.User, error)
+	List(filter UserFilter) ([]models.UserInfo, int64, error)
+	FindIncludingDeleted(id uuid.UUID) (*models.User, bool, error)
+	SoftDelete(id uuid.UUID) error
+	HardDelete(id uuid.UUID) error
 }
 
+// UserFilter narrows List. Empty fields match every user.
+type UserFilter struct {
+	Status        string
+	Role          string
+	EmailVerified *bool
+	// Search matches part of the email address or name.
+	Search string
+	// Deleted lists soft deleted users instead of live ones.
+	Deleted bool
+	Offset  int
+	Limit   int
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
 type UserRepository struct {
 	database *gorm.DB
 }
@@ -121,6 +142,12 @@ func (a *UserRepository) Save(user *models.User) (*models.User, error) {
 	return user, nil
 }
 
+// UpdateColumns writes only the named columns, including zero values that
+// Save would skip.
+func (a *UserRepository) UpdateColumns(user *models.User, columns ...string) error {
+	return a.database.Model(user).Select(columns).Updates(user).Error
+}
+
 func (a *UserRepository) Delete(id string) (*models.User, error) {
 	var user *models.User
 	err := a.database.Raw(`DELETE FROM users WHERE id = ?`, id).Scan(&user).Error
@@ -130,6 +157,81 @@ func (a *UserRepository) Delete(id string) (*models.User, error) {
 	return user, nil
 }
 
+// List returns one page of users, newest first, and how many match in total.
+func (a *UserRepository) List(filter UserFilter) ([]models.UserInfo, int64, error) {
+	query := a.database.Model(&models.User{})
+	if filter.Deleted {
+		query = query.Unscoped().Where("deleted_at IS NOT NULL")
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Role != "" {
+		query = query.Where("role = ?", filter.Role)
+	}
+	if filter.EmailVerified != nil {
+		query = query.Where("email_verified = ?", *filter.EmailVerified)
+	}
+	if filter.Search != "" {
+		pattern := "%" + likeEscaper.Replace(strings.ToLower(filter.Search)) + "%"
+		query = query.Where("LOWER(email) LIKE ? OR LOWER(first_name) LIKE ? OR LOWER(last_name) LIKE ?", pattern, pattern, pattern)
+	}
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var users []models.UserInfo
+	err := query.Order("created_at DESC").Offset(filter.Offset).Limit(filter.Limit).Find(&users).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+func (a *UserRepository) FindIncludingDeleted(id uuid.UUID) (*models.User, bool, error) {
+	var users []*models.User
+	err := a.database.Unscoped().Where("id = ?", id).Limit(1).Find(&users).Error
+	if err != nil {
+		return nil, false, err
+	}
+	if len(users) == 0 {
+		return nil, false, nil
+	}
+	return users[0], true, nil
+}
+
+func (a *UserRepository) SoftDelete(id uuid.UUID) error {
+	return a.database.Where("id = ?", id).Delete(&models.User{}).Error
+}
+
+// HardDelete removes a user, soft deleted or not, together with everything
+// stored against their ID. Audit log entries about them are kept.
+func (a *UserRepository) HardDelete(id uuid.UUID) error {
+	return a.database.Transaction(func(tx *gorm.DB) error {
+		owned := []interface{}{
+			&models.Session{},
+			&models.RefreshToken{},
+			&models.Identity{},
+			&models.RecoveryCode{},
+			&models.WebAuthnCredential{},
+			&models.APIToken{},
+			&models.RoleAssignment{},
+			&models.UserAgent{},
+			&models.GeoLocation{},
+		}
+		for _, model := range owned {
+			if err := tx.Where("user_id = ?", id).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Unscoped().Where("id = ?", id).Delete(&models.User{}).Error
+	})
+}
+
 func (a *UserRepository) RawCount(q string, count *int64) error {
 	return a.database.Model(&models.User{}).Raw(q).Count(count).Error
 }
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/bjorndonald/golang-backend-template/internal/models"
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+type WebAuthnCredentialRepositoryInterface interface {
+	Create(credential *models.WebAuthnCredential) error
+	Find(id uuid.UUID) (*models.WebAuthnCredential, bool, error)
+	FindByUserID(userID uuid.UUID) ([]*models.WebAuthnCredential, error)
+	RecordUse(id uuid.UUID, signCount uint32, backupState bool) error
+	Rename(id uuid.UUID, name string) error
+	Delete(id uuid.UUID) error
+}
+
+type WebAuthnCredentialRepository struct {
+	database *gorm.DB
+}
+
+func NewWebAuthnCredentialRepository(db *gorm.DB) WebAuthnCredentialRepositoryInterface {
+	return &WebAuthnCredentialRepository{
+		database: db,
+	}
+}
+
+func (a *WebAuthnCredentialRepository) Create(credential *models.WebAuthnCredential) error {
+	return a.database.Model(&credential).Create(credential).Error
+}
+
+func (a *WebAuthnCredentialRepository) Find(id uuid.UUID) (*models.WebAuthnCredential, bool, error) {
+	var credentials []*models.WebAuthnCredential
+	err := a.database.Where("id = ?", id).Limit(1).Find(&credentials).Error
+	if err != nil {
+		return nil, false, err
+	}
+	if len(credentials) == 0 {
+		return nil, false, nil
+	}
+	return credentials[0], true, nil
+}
+
+func (a *WebAuthnCredentialRepository) FindByUserID(userID uuid.UUID) ([]*models.WebAuthnCredential, error) {
+	var credentials []*models.WebAuthnCredential
+	err := a.database.Where("user_id = ?", userID).Order("created_at").Find(&credentials).Error
+	if err != nil {
+		return nil, err
+	}
+	return credentials, nil
+}
+
+func (a *WebAuthnCredentialRepository) RecordUse(id uuid.UUID, signCount uint32, backupState bool) error {
+	return a.database.Model(&models.WebAuthnCredential{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"sign_count":   signCount,
+			"backup_state": backupState,
+			"last_used_at": time.Now(),
+		}).Error
+}
+
+func (a *WebAuthnCredentialRepository) Rename(id uuid.UUID, name string) error {
+	return a.database.Model(&models.WebAuthnCredential{}).
+		Where("id = ?", id).
+		Update("name", name).Error
+}
+
+func (a *WebAuthnCredentialRepository) Delete(id uuid.UUID) error {
+	return a.database.Where("id = ?", id).Delete(&models.WebAuthnCredential{}).Error
+}
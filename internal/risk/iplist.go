@@ -0,0 +1,86 @@
+package risk
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strings"
+)
+
+// IPList is a reputation list of addresses and networks, such as known
+// proxies, Tor exit nodes or addresses seen in attacks.
+type IPList struct {
+	prefixes []netip.Prefix
+}
+
+// LoadIPListFile reads an IP list from path.
+func LoadIPListFile(path string) (*IPList, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return LoadIPList(file)
+}
+
+// LoadIPList reads one address or CIDR network per line. Blank lines and
+// text after a # are skipped.
+func LoadIPList(r io.Reader) (*IPList, error) {
+	list := &IPList{}
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+
+		if !strings.Contains(text, "/") {
+			addr, err := netip.ParseAddr(text)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			list.prefixes = append(list.prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		list.prefixes = append(list.prefixes, prefix.Masked())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// Contains reports whether ip is on the list. Addresses that cannot be
+// parsed are not.
+func (l *IPList) Contains(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
+	for _, prefix := range l.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns the number of entries on the list.
+func (l *IPList) Len() int {
+	return len(l.prefixes)
+}
@@ -0,0 +1,261 @@
+// Package risk scores login attempts and decides how much proof each one
+// needs.
+package risk
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Decision is what a login has to do before it can go on.
+type Decision string
+
+const (
+	// Allow lets the login go on to the user's usual second factor.
+	Allow Decision = "allow"
+	// Challenge also asks for a code sent to the user's email address.
+	Challenge Decision = "challenge"
+	// Approve stops the login until the device is approved from an
+	// emailed link.
+	Approve Decision = "approve"
+)
+
+// Signals that can add to a login's score.
+const (
+	SignalNewDevice        = "new_device"
+	SignalNewCountry       = "new_country"
+	SignalImpossibleTravel = "impossible_travel"
+	SignalListedIP         = "listed_ip"
+	SignalUnusualHour      = "unusual_hour"
+)
+
+// Policy weighs each signal and sets the scores at which a login is
+// challenged or needs approval. Travel between two logins faster than
+// MaxSpeed km/h is impossible. Logins between QuietStart and QuietEnd
+// o'clock in Location are at an unusual hour; equal hours turn that signal
+// off. A nil Blocklist lists no addresses.
+type Policy struct {
+	NewDevice        int
+	NewCountry       int
+	ImpossibleTravel int
+	ListedIP         int
+	UnusualHour      int
+
+	MaxSpeed   float64
+	QuietStart int
+	QuietEnd   int
+	Location   *time.Location
+	Blocklist  *IPList
+
+	ChallengeAt int
+	ApproveAt   int
+}
+
+// DefaultPolicy challenges a login from a new country, or from a new device
+// at an unusual hour; a new device on its own is allowed. A listed address
+// or impossible travel is challenged on its own and needs approval when it
+// comes with a new device or country, or impossible travel at an unusual
+// hour.
+var DefaultPolicy = Policy{
+	NewDevice:        20,
+	NewCountry:       30,
+	ImpossibleTravel: 60,
+	ListedIP:         50,
+	UnusualHour:      10,
+
+	MaxSpeed:   1000,
+	QuietStart: 1,
+	QuietEnd:   5,
+	Location:   time.UTC,
+
+	ChallengeAt: 30,
+	ApproveAt:   70,
+}
+
+// minTravelDistance is how far apart two logins must be, in km, before the
+// travel between them is judged. GeoIP places addresses only roughly.
+const minTravelDistance = 300
+
+// Sighting is where and when the user was seen. Coordinates are
+// "latitude,longitude", as in models.GeoLocation.Location.
+type Sighting struct {
+	Time        time.Time
+	Coordinates string
+}
+
+// Login is what is known about a login attempt. Previous is the user's
+// last login, if there was one. TrustedDevice is set when the user marked
+// the device the login comes from as trusted.
+type Login struct {
+	Sighting
+	IP            string
+	NewDevice     bool
+	NewCountry    bool
+	TrustedDevice bool
+	Previous      *Sighting
+}
+
+// Assessment is the outcome of scoring a login.
+type Assessment struct {
+	Score    int      `json:"score"`
+	Signals  []string `json:"signals"`
+	Decision Decision `json:"decision"`
+}
+
+// Has reports whether signal contributed to the score.
+func (a Assessment) Has(signal string) bool {
+	for _, s := range a.Signals {
+		if s == signal {
+			return true
+		}
+	}
+	return false
+}
+
+// Assess scores login and decides what it needs.
+func (p Policy) Assess(login Login) Assessment {
+	assessment := Assessment{Signals: []string{}}
+	add := func(signal string, weight int) {
+		assessment.Score += weight
+		assessment.Signals = append(assessment.Signals, signal)
+	}
+
+	if login.NewDevice {
+		add(SignalNewDevice, p.NewDevice)
+	}
+	if login.NewCountry {
+		add(SignalNewCountry, p.NewCountry)
+	}
+	if login.Previous != nil && p.impossibleTravel(*login.Previous, login.Sighting) {
+		add(SignalImpossibleTravel, p.ImpossibleTravel)
+	}
+	if p.Blocklist != nil && p.Blocklist.Contains(login.IP) {
+		add(SignalListedIP, p.ListedIP)
+	}
+	if p.unusualHour(login.Time) {
+		add(SignalUnusualHour, p.UnusualHour)
+	}
+
+	// A trusted device is not asked for an emailed code, but a login risky
+	// enough to need approval still does.
+	switch {
+	case assessment.Score >= p.ApproveAt:
+		assessment.Decision = Approve
+	case assessment.Score >= p.ChallengeAt && !login.TrustedDevice:
+		assessment.Decision = Challenge
+	default:
+		assessment.Decision = Allow
+	}
+	return assessment
+}
+
+func (p Policy) impossibleTravel(from, to Sighting) bool {
+	distance, ok := Distance(from.Coordinates, to.Coordinates)
+	if !ok || distance < minTravelDistance || p.MaxSpeed <= 0 {
+		return false
+	}
+
+	hours := to.Time.Sub(from.Time).Hours()
+	if hours <= 0 {
+		return true
+	}
+	return distance/hours > p.MaxSpeed
+}
+
+func (p Policy) unusualHour(t time.Time) bool {
+	if p.QuietStart == p.QuietEnd {
+		return false
+	}
+
+	location := p.Location
+	if location == nil {
+		location = time.UTC
+	}
+
+	hour := t.In(location).Hour()
+	if p.QuietStart < p.QuietEnd {
+		return hour >= p.QuietStart && hour < p.QuietEnd
+	}
+	return hour >= p.QuietStart || hour < p.QuietEnd
+}
+
+// Distance returns the great circle distance in km between two
+// "latitude,longitude" coordinates. It reports false if either cannot be
+// parsed.
+func Distance(from, to string) (float64, bool) {
+	lat1, lon1, ok := parseCoordinates(from)
+	if !ok {
+		return 0, false
+	}
+	lat2, lon2, ok := parseCoordinates(to)
+	if !ok {
+		return 0, false
+	}
+
+	const earthRadius = 6371.0
+	toRadians := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRadians(lat2 - lat1)
+	dLon := toRadians(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return earthRadius * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a)), true
+}
+
+func parseCoordinates(value string) (float64, float64, bool) {
+	latText, lonText, found := strings.Cut(value, ",")
+	if !found {
+		return 0, 0, false
+	}
+
+	lat, err := strconv.ParseFloat(strings.TrimSpace(latText), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return 0, 0, false
+	}
+	lon, err := strconv.ParseFloat(strings.TrimSpace(lonText), 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return 0, 0, false
+	}
+	return lat, lon, true
+}
+
+// SetWeights changes signal weights from a list like
+// "new_device=20,listed_ip=50". Signals it does not name keep their weight.
+// Nothing changes unless the whole list is valid.
+func (p *Policy) SetWeights(spec string) error {
+	fields := map[string]*int{
+		SignalNewDevice:        &p.NewDevice,
+		SignalNewCountry:       &p.NewCountry,
+		SignalImpossibleTravel: &p.ImpossibleTravel,
+		SignalListedIP:         &p.ListedIP,
+		SignalUnusualHour:      &p.UnusualHour,
+	}
+
+	weights := map[*int]int{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		signal, value, found := strings.Cut(entry, "=")
+		field, ok := fields[strings.TrimSpace(signal)]
+		if !found || !ok {
+			return fmt.Errorf("invalid risk weight %q", entry)
+		}
+
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("invalid risk weight %q", entry)
+		}
+		weights[field] = n
+	}
+
+	for field, n := range weights {
+		*field = n
+	}
+	return nil
+}
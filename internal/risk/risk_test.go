@@ -0,0 +1,183 @@
+package risk
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// Noon UTC, outside the default quiet hours.
+var noon = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+const (
+	london = "51.5142,-0.0931"
+	paris  = "48.8566,2.3522"
+	lagos  = "6.4541,3.3947"
+)
+
+func TestAssess(t *testing.T) {
+	blocklist, err := LoadIPList(strings.NewReader("203.0.113.0/24\n"))
+	if err != nil {
+		t.Fatalf("LoadIPList: %v", err)
+	}
+
+	policy := DefaultPolicy
+	policy.Blocklist = blocklist
+
+	tests := []struct {
+		name     string
+		login    Login
+		signals  []string
+		decision Decision
+	}{
+		{
+			name:     "familiar login",
+			login:    Login{Sighting: Sighting{Time: noon, Coordinates: london}, IP: "81.2.69.1"},
+			decision: Allow,
+		},
+		{
+			name:     "new device",
+			login:    Login{Sighting: Sighting{Time: noon}, IP: "81.2.69.1", NewDevice: true},
+			signals:  []string{SignalNewDevice},
+			decision: Allow,
+		},
+		{
+			name:     "new country",
+			login:    Login{Sighting: Sighting{Time: noon}, IP: "81.2.69.1", NewCountry: true},
+			signals:  []string{SignalNewCountry},
+			decision: Challenge,
+		},
+		{
+			name: "plausible travel",
+			login: Login{
+				Sighting: Sighting{Time: noon, Coordinates: paris},
+				IP:       "81.2.69.1",
+				Previous: &Sighting{Time: noon.Add(-3 * time.Hour), Coordinates: london},
+			},
+			decision: Allow,
+		},
+		{
+			name: "impossible travel",
+			login: Login{
+				Sighting: Sighting{Time: noon, Coordinates: lagos},
+				IP:       "81.2.69.1",
+				Previous: &Sighting{Time: noon.Add(-time.Hour), Coordinates: london},
+			},
+			signals:  []string{SignalImpossibleTravel},
+			decision: Challenge,
+		},
+		{
+			name:     "listed address from a new device",
+			login:    Login{Sighting: Sighting{Time: noon}, IP: "203.0.113.9", NewDevice: true},
+			signals:  []string{SignalNewDevice, SignalListedIP},
+			decision: Approve,
+		},
+		{
+			name:     "new country on a trusted device",
+			login:    Login{Sighting: Sighting{Time: noon}, IP: "81.2.69.1", NewCountry: true, TrustedDevice: true},
+			signals:  []string{SignalNewCountry},
+			decision: Allow,
+		},
+		{
+			name:     "listed address on a trusted device",
+			login:    Login{Sighting: Sighting{Time: noon}, IP: "203.0.113.9", NewCountry: true, TrustedDevice: true},
+			signals:  []string{SignalNewCountry, SignalListedIP},
+			decision: Approve,
+		},
+		{
+			name:     "unusual hour",
+			login:    Login{Sighting: Sighting{Time: noon.Add(-9 * time.Hour)}, IP: "81.2.69.1"},
+			signals:  []string{SignalUnusualHour},
+			decision: Allow,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assessment := policy.Assess(tt.login)
+
+			if assessment.Decision != tt.decision {
+				t.Errorf("expected %s, got %s (score %d, signals %v)", tt.decision, assessment.Decision, assessment.Score, assessment.Signals)
+			}
+			if len(assessment.Signals) != len(tt.signals) {
+				t.Fatalf("expected signals %v, got %v", tt.signals, assessment.Signals)
+			}
+			for _, signal := range tt.signals {
+				if !assessment.Has(signal) {
+					t.Errorf("expected signal %s, got %v", signal, assessment.Signals)
+				}
+			}
+		})
+	}
+}
+
+func TestQuietHoursWrapMidnight(t *testing.T) {
+	policy := Policy{QuietStart: 22, QuietEnd: 6, Location: time.UTC}
+
+	for hour, expected := range map[int]bool{21: false, 22: true, 2: true, 6: false} {
+		at := time.Date(2024, 5, 1, hour, 0, 0, 0, time.UTC)
+		if got := policy.unusualHour(at); got != expected {
+			t.Errorf("hour %d: expected %v, got %v", hour, expected, got)
+		}
+	}
+}
+
+func TestDistance(t *testing.T) {
+	distance, ok := Distance(london, paris)
+	if !ok || distance < 330 || distance > 350 {
+		t.Fatalf("expected London to Paris to be about 340 km, got %.0f (ok=%v)", distance, ok)
+	}
+
+	if _, ok := Distance("", paris); ok {
+		t.Fatal("expected missing coordinates not to parse")
+	}
+}
+
+func TestIPList(t *testing.T) {
+	list, err := LoadIPList(strings.NewReader(`# Tor exit nodes
+185.220.101.4
+2001:67c:6ec::/48 # a whole network
+`))
+	if err != nil {
+		t.Fatalf("LoadIPList: %v", err)
+	}
+
+	for ip, expected := range map[string]bool{
+		"185.220.101.4":        true,
+		"::ffff:185.220.101.4": true,
+		"185.220.101.5":        false,
+		"2001:67c:6ec:203::1":  true,
+		"not an address":       false,
+	} {
+		if got := list.Contains(ip); got != expected {
+			t.Errorf("%s: expected %v, got %v", ip, expected, got)
+		}
+	}
+
+	if _, err := LoadIPList(strings.NewReader("300.1.1.1\n")); err == nil {
+		t.Fatal("expected an error for an invalid address")
+	}
+}
+
+func TestSetWeights(t *testing.T) {
+	policy := DefaultPolicy
+	if err := policy.SetWeights("new_device=40, listed_ip=0"); err != nil {
+		t.Fatalf("SetWeights: %v", err)
+	}
+	if policy.NewDevice != 40 || policy.ListedIP != 0 || policy.NewCountry != DefaultPolicy.NewCountry {
+		t.Errorf("unexpected weights %+v", policy)
+	}
+
+	for _, spec := range []string{"new_device", "tor=10", "new_device=high"} {
+		if err := policy.SetWeights(spec); err == nil {
+			t.Errorf("expected an error for %q", spec)
+		}
+	}
+
+	if err := policy.SetWeights("new_country=5,listed_ip=high"); err == nil {
+		t.Fatalf("expected an error for a partly invalid list")
+	}
+	if policy.NewCountry != DefaultPolicy.NewCountry {
+		t.Errorf("expected a partly invalid list to change nothing, got new_country=%d", policy.NewCountry)
+	}
+}
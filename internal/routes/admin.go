@@ -0,0 +1,50 @@
+package routes
+
+import (
+	"github.com/bjorndonald/golang-backend-template/internal/bootstrap"
+	"github.com/bjorndonald/golang-backend-template/internal/handlers"
+	"github.com/bjorndonald/golang-backend-template/internal/middleware"
+	"github.com/bjorndonald/golang-backend-template/internal/rbac"
+	"github.com/bjorndonald/golang-backend-template/internal/validators"
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterAdminRoutes(router *gin.RouterGroup, d *bootstrap.AppDependencies) {
+	handler := handlers.NewAdminHandler(d)
+
+	adminRouter := router.Group("/admin", middleware.JWTMiddleware(d.DatabaseService))
+
+	usersRead := middleware.RequirePermission(rbac.UsersRead)
+	usersWrite := middleware.RequirePermission(rbac.UsersWrite)
+	rolesRead := middleware.RequirePermission(rbac.RolesRead)
+	rolesWrite := middleware.RequirePermission(rbac.RolesWrite)
+
+	users := adminRouter.Group("/users")
+	users.GET("", usersRead, handler.ListUsers)
+	users.GET("/:id", usersRead, handler.GetUser)
+	users.DELETE("/:id", usersWrite, handler.DeleteUser)
+	users.POST("/:id/unlock", usersWrite, handler.UnlockUser)
+	users.POST("/:id/suspend", usersWrite, handler.SuspendUser)
+	users.POST("/:id/reactivate", usersWrite, handler.ReactivateUser)
+	users.POST("/:id/force-password-reset", usersWrite, handler.ForcePasswordReset)
+	users.POST("/:id/resend-verification", usersWrite, handler.ResendVerification)
+	users.POST("/:id/impersonate", middleware.RequireSession(), middleware.RequirePermission(rbac.UsersImpersonate), handler.ImpersonateUser)
+	users.PUT("/:id/role", usersWrite, rolesWrite, validators.ValidateRoleSchema, handler.ChangeUserRole)
+
+	users.GET("/:id/roles", rolesRead, handler.ListUserRoles)
+	users.POST("/:id/roles", usersWrite, rolesWrite, validators.ValidateRoleSchema, handler.AssignRole)
+	users.DELETE("/:id/roles/:role", usersWrite, rolesWrite, handler.UnassignRole)
+
+	adminRouter.GET("/roles", rolesRead, handler.ListRoles)
+	adminRouter.POST("/roles", rolesWrite, validators.ValidateCreateRoleSchema, handler.CreateRole)
+	adminRouter.PUT("/roles/:id", rolesWrite, validators.ValidateUpdateRolePermissionsSchema, handler.UpdateRole)
+	adminRouter.DELETE("/roles/:id", rolesWrite, handler.DeleteRole)
+
+	adminRouter.GET("/audit-logs", middleware.RequirePermission(rbac.AuditRead), handler.ListAuditLogs)
+	adminRouter.GET("/login-events", middleware.RequirePermission(rbac.AuditRead), handler.ListLoginEvents)
+
+	apiKeys := adminRouter.Group("/api-keys", middleware.RequirePermission(rbac.APIKeysWrite))
+	apiKeys.GET("", handler.ListAPIKeys)
+	apiKeys.POST("", middleware.RequireSession(), validators.ValidateCreateAPITokenSchema, handler.CreateAPIKey)
+	apiKeys.DELETE("/:id", middleware.RequireSession(), handler.RevokeAPIKey)
+}
@@ -3,6 +3,7 @@ package routes
 import (
 	"github.com/bjorndonald/golang-backend-template/internal/bootstrap"
 	"github.com/bjorndonald/golang-backend-template/internal/handlers"
+	"github.com/bjorndonald/golang-backend-template/internal/middleware"
 	"github.com/bjorndonald/golang-backend-template/internal/validators"
 	"github.com/gin-gonic/gin"
 )
@@ -16,14 +17,33 @@ func RegisterAuthRoutes(router *gin.RouterGroup, d *bootstrap.AppDependencies) {
 	authRouter.POST("/register", validators.ValidateRegisterUserSchema, handler.CreateUser)
 	authRouter.POST("/login", validators.ValidateLoginUser, handler.Authenticate)
 	authRouter.POST("/logout", handler.LogOut)
+	authRouter.POST("/logout/all", middleware.JWTMiddleware(d.DatabaseService), middleware.RequireSession(), handler.LogOutAll)
 	authRouter.POST("/refresh-token", handler.RefreshToken)
 	authRouter.GET("/verify/:email/:otp", handler.VerifyEmail)
+	authRouter.GET("/verify/device/:token", handler.ShowDeviceApproval)
+	authRouter.POST("/verify/device/:token", handler.VerifyDevice)
+	authRouter.GET("/email/confirm", handler.ConfirmEmailChange)
+	authRouter.GET("/email/revert", handler.ShowEmailRevert)
+	authRouter.POST("/email/revert", handler.RevertEmailChange)
 
+	authRouter.GET("/oauth/:provider", handler.OAuthLogin)
+	authRouter.GET("/oauth/:provider/callback", handler.OAuthCallback)
+
+	authRouter.POST("/webauthn/register/begin", middleware.JWTMiddleware(d.DatabaseService), middleware.RequireSession(), handler.BeginPasskeyRegistration)
+	authRouter.POST("/webauthn/register/finish", middleware.JWTMiddleware(d.DatabaseService), middleware.RequireSession(), handler.FinishPasskeyRegistration)
+	authRouter.POST("/webauthn/login/begin", handler.BeginPasskeyLogin)
+	authRouter.POST("/webauthn/login/finish", handler.FinishPasskeyLogin)
+
+	authRouter.POST("/magic-link", validators.ValidateMagicLinkSchema, handler.RequestMagicLink)
+	authRouter.GET("/magic-link/callback", handler.MagicLinkCallback)
+
+	authRouter.POST("/password/check", validators.ValidatePasswordCheckSchema, handler.CheckPassword)
 	authRouter.POST("/forgot-password/verify", validators.ValidateOTPVerifySchema, handler.VerifyResetOTP)
 	authRouter.POST("/forgot-password", validators.ValidateResetUserSchema, handler.ForgotPassword)
 	authRouter.POST("/reset-password/confirm/:reset-token", validators.ValidateResetPasswordSchema, handler.ResetPassword)
 
 	authRouter.POST("/2fa/:token", handler.Send2FAEmail)
 	authRouter.POST("/2fa/verify/:token", validators.ValidateOTPSchema, handler.Verify2FAEmail)
+	authRouter.POST("/2fa/verify", validators.ValidateTwoFactorVerifySchema, handler.Verify2FA)
 
 }
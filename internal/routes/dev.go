@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"github.com/bjorndonald/golang-backend-template/internal/bootstrap"
+	"github.com/bjorndonald/golang-backend-template/internal/handlers"
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterDevRoutes registers the development tools at the server root. It
+// is only called outside production when MAIL_SINK_VIEWER is set, and the
+// mailbox only exists when email goes to the sink transport.
+func RegisterDevRoutes(router gin.IRouter, d *bootstrap.AppDependencies) {
+
+	if d.Mailbox == nil {
+		return
+	}
+
+	handler := handlers.NewMailboxHandler(d)
+
+	devRouter := router.Group("/dev")
+	devRouter.GET("/mailbox", handler.ListMail)
+	devRouter.DELETE("/mailbox", handler.ClearMail)
+	devRouter.GET("/mailbox/:id", handler.ShowMail)
+	devRouter.GET("/mailbox/:id/raw", handler.DownloadMail)
+
+}
@@ -15,9 +15,45 @@ func RegisterUserRoutes(router *gin.RouterGroup, d *bootstrap.AppDependencies) {
 	handler := handlers.NewUserHandler(d)
 
 	userRouter.GET("/profile", middleware.JWTMiddleware(d.DatabaseService), handler.UserProfile)
-	userRouter.PUT("/profile", middleware.JWTMiddleware(d.DatabaseService), validators.ValidateUpdateUserProfile, handler.UpdateUserProfile)
+	userRouter.PUT("/profile", middleware.JWTMiddleware(d.DatabaseService), middleware.RequireSession(), validators.ValidateUpdateUserProfile, handler.UpdateUserProfile)
+	userRouter.POST("/email", middleware.JWTMiddleware(d.DatabaseService), middleware.RequireSession(), validators.ValidateChangeEmailSchema, handler.RequestEmailChange)
+	userRouter.PUT("/password", middleware.JWTMiddleware(d.DatabaseService), middleware.RequireSession(), validators.ValidateChangePasswordSchema, handler.ChangePassword)
 	userRouter.PUT("/photo", middleware.JWTMiddleware(d.DatabaseService), middleware.CloudinaryUploadMiddleware(), handler.UpdateUserPhoto)
 
+	// Sessions
+
+	userRouter.GET("/sessions", middleware.JWTMiddleware(d.DatabaseService), handler.ListSessions)
+	userRouter.DELETE("/sessions", middleware.JWTMiddleware(d.DatabaseService), middleware.RequireSession(), handler.RevokeOtherSessions)
+	userRouter.DELETE("/sessions/:id", middleware.JWTMiddleware(d.DatabaseService), middleware.RequireSession(), handler.RevokeSession)
+
+	// Devices
+
+	userRouter.GET("/devices", middleware.JWTMiddleware(d.DatabaseService), handler.ListDevices)
+	userRouter.PATCH("/devices/:id", middleware.JWTMiddleware(d.DatabaseService), middleware.RequireSession(), validators.ValidateUpdateDeviceSchema, handler.UpdateDevice)
+	userRouter.DELETE("/devices/:id", middleware.JWTMiddleware(d.DatabaseService), middleware.RequireSession(), handler.ForgetDevice)
+	userRouter.GET("/security/activity", middleware.JWTMiddleware(d.DatabaseService), handler.SecurityActivity)
+
+	// Two factor
+
+	userRouter.PUT("/2fa", middleware.JWTMiddleware(d.DatabaseService), middleware.RequireSession(), validators.ValidateTwoFactorPreferencesSchema, handler.UpdateTwoFactorPreferences)
+	userRouter.POST("/2fa/totp/setup", middleware.JWTMiddleware(d.DatabaseService), middleware.RequireSession(), handler.SetupTOTP)
+	userRouter.POST("/2fa/totp/confirm", middleware.JWTMiddleware(d.DatabaseService), middleware.RequireSession(), validators.ValidateOTPSchema, handler.ConfirmTOTP)
+	userRouter.DELETE("/2fa/totp", middleware.JWTMiddleware(d.DatabaseService), middleware.RequireSession(), validators.ValidateOTPSchema, handler.DisableTOTP)
+	userRouter.GET("/2fa/recovery-codes", middleware.JWTMiddleware(d.DatabaseService), handler.RecoveryCodesStatus)
+	userRouter.POST("/2fa/recovery-codes", middleware.JWTMiddleware(d.DatabaseService), middleware.RequireSession(), validators.ValidateReauthenticateSchema, handler.RegenerateRecoveryCodes)
+
+	// Passkeys
+
+	userRouter.GET("/passkeys", middleware.JWTMiddleware(d.DatabaseService), handler.ListPasskeys)
+	userRouter.PATCH("/passkeys/:id", middleware.JWTMiddleware(d.DatabaseService), middleware.RequireSession(), validators.ValidateRenamePasskeySchema, handler.RenamePasskey)
+	userRouter.DELETE("/passkeys/:id", middleware.JWTMiddleware(d.DatabaseService), middleware.RequireSession(), handler.DeletePasskey)
+
+	// Personal access tokens
+
+	userRouter.GET("/tokens", middleware.JWTMiddleware(d.DatabaseService), handler.ListAccessTokens)
+	userRouter.POST("/tokens", middleware.JWTMiddleware(d.DatabaseService), middleware.RequireSession(), validators.ValidateCreateAPITokenSchema, handler.CreateAccessToken)
+	userRouter.DELETE("/tokens/:id", middleware.JWTMiddleware(d.DatabaseService), middleware.RequireSession(), handler.RevokeAccessToken)
+
 	// OTP
 
 	userRouter.POST("/otp", middleware.JWTMiddleware(d.DatabaseService), handler.SendOTP)
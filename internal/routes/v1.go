@@ -9,5 +9,6 @@ func Routes(r *gin.RouterGroup, d *bootstrap.AppDependencies) {
 
 	RegisterUserRoutes(r, d)
 	RegisterAuthRoutes(r, d)
+	RegisterAdminRoutes(r, d)
 
 }
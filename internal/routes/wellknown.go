@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"github.com/bjorndonald/golang-backend-template/internal/bootstrap"
+	"github.com/bjorndonald/golang-backend-template/internal/handlers"
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterWellKnownRoutes registers the routes that live at the server root
+// rather than under the API version.
+func RegisterWellKnownRoutes(router gin.IRouter, d *bootstrap.AppDependencies) {
+
+	handler := handlers.NewWellKnownHandler(d)
+
+	router.GET("/.well-known/jwks.json", handler.JWKS)
+
+}
@@ -3,59 +3,60 @@ package service
 import (
 	"fmt"
 	"log"
+	"net/url"
 	"time"
 
 	"github.com/bjorndonald/golang-backend-template/constants"
 	"github.com/bjorndonald/golang-backend-template/internal/helpers"
+	"github.com/bjorndonald/golang-backend-template/internal/mail"
 	"github.com/bjorndonald/golang-backend-template/internal/models"
-	"github.com/bjorndonald/golang-backend-template/internal/otp"
-	"github.com/bjorndonald/golang-backend-template/resend"
 )
 
 type EmailServicer interface {
-	SendNewUserEmail(name, email, url string)
-	SendForgotPasswordEmail(name, email string)
-	SendOTPEmail(name, email string)
+	SendNewUserEmail(name, email, url, otp string)
+	SendForgotPasswordEmail(name, email, otp string)
+	SendOTPEmail(name, email, otp string)
 
 	SendNewDeviceEmail(name, email, url string, agent models.UserAgent)
 	SendNewLocationEmail(name, email, url string, location models.GeoLocation)
+	SendRecoveryCodeUsedEmail(name, email string, remaining int64)
+	SendAccountLockedEmail(name, email, ip string, until time.Time)
+	SendMagicLinkEmail(name, email, url string)
+	SendEmailChangeConfirmationEmail(name, email, url string)
+	SendEmailChangeNoticeEmail(name, email, newEmail, url string)
+	SendPasswordChangedEmail(name, email, ip string)
+	SendDeviceApprovalEmail(name, email, url string, approval models.DeviceApproval)
 }
 
 type EmailService struct {
-	Client *resend.Client
+	Mailer mail.Mailer
 }
 
 var (
 	constant = constants.New()
 )
 
-func NewEmailService() EmailServicer {
-	client := resend.NewClient(constant.ResendApiKey)
-	return &EmailService{Client: client}
+func NewEmailService(mailer mail.Mailer) EmailServicer {
+	return &EmailService{Mailer: mailer}
 }
 
 func (s *EmailService) Send(name, email, subject, content string) error {
-	to := []string{
-		email,
-	}
-
-	_, err := s.Client.Send(to, constant.SendFromEmail, constant.SendFromName, subject, content)
-
-	return err
+	return s.Mailer.Send(mail.Message{
+		From:     constant.SendFromEmail,
+		FromName: constant.SendFromName,
+		To:       []string{email},
+		Subject:  subject,
+		HTML:     content,
+	})
 }
 
-func (s *EmailService) SendOTPEmail(name, email string) {
-	otpToken, err := otp.OTPManage.GenerateOTP(email, time.Minute*10)
+func (s *EmailService) SendOTPEmail(name, email, otpToken string) {
 	type NewUser struct {
 		Email string
 		Name  string
 		OTP   string
 	}
 
-	if err != nil {
-		log.Printf("Error sending email: %v", err.Error())
-	}
-
 	messageBody, err := helpers.ParseTemplateFile("otp.html", NewUser{Email: email, Name: name, OTP: otpToken})
 
 	if err != nil {
@@ -69,9 +70,8 @@ func (s *EmailService) SendOTPEmail(name, email string) {
 	}
 }
 
-func (s *EmailService) SendForgotPasswordEmail(name, email string) {
+func (s *EmailService) SendForgotPasswordEmail(name, email, otpToken string) {
 	c := constants.New()
-	otpToken, err := otp.OTPManage.GenerateOTP(email, time.Minute*10)
 	type NewUser struct {
 		Email string
 		Name  string
@@ -79,11 +79,7 @@ func (s *EmailService) SendForgotPasswordEmail(name, email string) {
 		Url   string
 	}
 
-	if err != nil {
-		log.Printf("Error sending email: %v", err.Error())
-	}
-
-	verificationUrl := fmt.Sprintf("%s/auth/reset-password/%s/%s", c.ClientUrl)
+	verificationUrl := fmt.Sprintf("%s/auth/reset-password?email=%s", c.ClientUrl, url.QueryEscape(email))
 	messageBody, err := helpers.ParseTemplateFile("reset_password.html", NewUser{Email: email, Name: name, Url: verificationUrl, OTP: otpToken})
 
 	if err != nil {
@@ -97,18 +93,13 @@ func (s *EmailService) SendForgotPasswordEmail(name, email string) {
 }
 
 // Sends email to new user
-func (s *EmailService) SendNewUserEmail(name, email, url string) {
-	otpToken, err := otp.OTPManage.GenerateOTP(email, time.Minute*10)
+func (s *EmailService) SendNewUserEmail(name, email, url, otpToken string) {
 	type NewUser struct {
 		Email string
 		Name  string
 		Url   string
 	}
 
-	if err != nil {
-		log.Printf("Error sending email: %v", err.Error())
-	}
-
 	verificationUrl := fmt.Sprintf("%s/api/v1/auth/verify/%s/%s", url, email, otpToken)
 
 	messageBody, err := helpers.ParseTemplateFile("verify_account.html", NewUser{Email: email, Name: name, Url: verificationUrl})
@@ -127,7 +118,7 @@ func (s *EmailService) SendNewUserEmail(name, email, url string) {
 // Sends email to user notifying them of login from new device
 func (s *EmailService) SendNewDeviceEmail(name, email, url string, agent models.UserAgent) {
 	constant := constants.New()
-	// otpToken, err := otp.OTPManage.GenerateOTP(email, time.Minute*10)
+	// otpToken, err := s.OTPs.GenerateOTP(email, time.Minute*10)
 
 	// if err != nil {
 	// 	log.Printf("Error sending email: %v", err.Error())
@@ -175,7 +166,7 @@ func (s *EmailService) SendNewDeviceEmail(name, email, url string, agent models.
 
 // Sends email to user notifying them of login from new location
 func (s *EmailService) SendNewLocationEmail(name, email, url string, location models.GeoLocation) {
-	// otpToken, err := otp.OTPManage.GenerateOTP(email, time.Minute*10)
+	// otpToken, err := s.OTPs.GenerateOTP(email, time.Minute*10)
 	// if err != nil {
 	// 	log.Printf("Error sending email: %v", err.Error())
 	// }
@@ -214,3 +205,221 @@ func (s *EmailService) SendNewLocationEmail(name, email, url string, location mo
 		log.Printf("Error sending email: %v", err.Error())
 	}
 }
+
+// Sends email to user notifying them that a recovery code was used to log in
+func (s *EmailService) SendRecoveryCodeUsedEmail(name, email string, remaining int64) {
+	type RecoveryCodeUsed struct {
+		Email     string
+		Name      string
+		Url       string
+		Remaining int64
+		Time      string
+	}
+
+	forgotPasswordUrl := fmt.Sprintf("%s/auth/forgot-password", constant.ClientUrl)
+
+	messageBody, err := helpers.ParseTemplateFile("recovery_code_used.html",
+		RecoveryCodeUsed{
+			Email:     email,
+			Name:      name,
+			Url:       forgotPasswordUrl,
+			Remaining: remaining,
+			Time:      time.Now().String(),
+		})
+
+	if err != nil {
+		log.Printf("Error sending email: %v", err.Error())
+	}
+
+	err = s.Send(name, email, "Recovery Code Used", messageBody)
+
+	if err != nil {
+		log.Printf("Error sending email: %v", err.Error())
+	}
+}
+
+// Sends email to user notifying them that their account was locked after failed logins
+func (s *EmailService) SendAccountLockedEmail(name, email, ip string, until time.Time) {
+	type AccountLocked struct {
+		Email string
+		Name  string
+		Url   string
+		IP    string
+		Until string
+	}
+
+	forgotPasswordUrl := fmt.Sprintf("%s/auth/forgot-password", constant.ClientUrl)
+
+	messageBody, err := helpers.ParseTemplateFile("account_locked.html",
+		AccountLocked{
+			Email: email,
+			Name:  name,
+			Url:   forgotPasswordUrl,
+			IP:    ip,
+			Until: until.Format(time.RFC1123),
+		})
+
+	if err != nil {
+		log.Printf("Error sending email: %v", err.Error())
+	}
+
+	err = s.Send(name, email, "Account Locked", messageBody)
+
+	if err != nil {
+		log.Printf("Error sending email: %v", err.Error())
+	}
+}
+
+// Sends email to user with a link that signs them in
+func (s *EmailService) SendMagicLinkEmail(name, email, url string) {
+	type MagicLink struct {
+		Email   string
+		Name    string
+		Url     string
+		Minutes int
+	}
+
+	messageBody, err := helpers.ParseTemplateFile("magic_link.html",
+		MagicLink{
+			Email:   email,
+			Name:    name,
+			Url:     url,
+			Minutes: int(helpers.MagicLinkTTL.Minutes()),
+		})
+
+	if err != nil {
+		log.Printf("Error sending email: %v", err.Error())
+	}
+
+	err = s.Send(name, email, "Your sign-in link", messageBody)
+
+	if err != nil {
+		log.Printf("Error sending email: %v", err.Error())
+	}
+}
+
+func (s *EmailService) SendEmailChangeConfirmationEmail(name, email, url string) {
+	type EmailChangeConfirmation struct {
+		Email   string
+		Name    string
+		Url     string
+		Minutes int
+	}
+
+	messageBody, err := helpers.ParseTemplateFile("email_change_confirm.html",
+		EmailChangeConfirmation{
+			Email:   email,
+			Name:    name,
+			Url:     url,
+			Minutes: int(helpers.EmailChangeTTL.Minutes()),
+		})
+
+	if err != nil {
+		log.Printf("Error sending email: %v", err.Error())
+	}
+
+	err = s.Send(name, email, "Confirm your new email address", messageBody)
+
+	if err != nil {
+		log.Printf("Error sending email: %v", err.Error())
+	}
+}
+
+func (s *EmailService) SendEmailChangeNoticeEmail(name, email, newEmail, url string) {
+	type EmailChangeNotice struct {
+		Email    string
+		Name     string
+		NewEmail string
+		Url      string
+		Days     int
+	}
+
+	messageBody, err := helpers.ParseTemplateFile("email_change_notice.html",
+		EmailChangeNotice{
+			Email:    email,
+			Name:     name,
+			NewEmail: newEmail,
+			Url:      url,
+			Days:     int(helpers.EmailRevertTTL.Hours() / 24),
+		})
+
+	if err != nil {
+		log.Printf("Error sending email: %v", err.Error())
+	}
+
+	err = s.Send(name, email, "Your email address is being changed", messageBody)
+
+	if err != nil {
+		log.Printf("Error sending email: %v", err.Error())
+	}
+}
+
+// Sends email to user telling them their password was changed while signed in
+func (s *EmailService) SendPasswordChangedEmail(name, email, ip string) {
+	type PasswordChanged struct {
+		Email string
+		Name  string
+		Url   string
+		IP    string
+		When  string
+	}
+
+	forgotPasswordUrl := fmt.Sprintf("%s/auth/forgot-password", constant.ClientUrl)
+
+	messageBody, err := helpers.ParseTemplateFile("password_changed.html",
+		PasswordChanged{
+			Email: email,
+			Name:  name,
+			Url:   forgotPasswordUrl,
+			IP:    ip,
+			When:  time.Now().Format(time.RFC1123),
+		})
+
+	if err != nil {
+		log.Printf("Error sending email: %v", err.Error())
+	}
+
+	err = s.Send(name, email, "Your password was changed", messageBody)
+
+	if err != nil {
+		log.Printf("Error sending email: %v", err.Error())
+	}
+}
+
+// Sends email to user with a link that approves a device a risky login came from
+func (s *EmailService) SendDeviceApprovalEmail(name, email, url string, approval models.DeviceApproval) {
+	type DeviceApproval struct {
+		Email     string
+		Name      string
+		Url       string
+		IP        string
+		UserAgent string
+		City      string
+		Country   string
+		When      string
+		Minutes   int
+	}
+
+	messageBody, err := helpers.ParseTemplateFile("device_approval.html",
+		DeviceApproval{
+			Email:     email,
+			Name:      name,
+			Url:       url,
+			IP:        approval.IP,
+			UserAgent: approval.UserAgent,
+			City:      approval.City,
+			Country:   approval.Country,
+			When:      approval.CreatedAt.Format(time.RFC1123),
+			Minutes:   int(helpers.DeviceTTL.Minutes()),
+		})
+
+	if err != nil {
+		log.Printf("Error sending email: %v", err.Error())
+	}
+
+	err = s.Send(name, email, "Approve sign in from a new device", messageBody)
+
+	if err != nil {
+		log.Printf("Error sending email: %v", err.Error())
+	}
+}
@@ -0,0 +1,86 @@
+package totp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image/png"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	period = 30
+	skew   = 1
+	qrSize = 256
+)
+
+// Enrollment holds what the user needs to add the account to an
+// authenticator app.
+type Enrollment struct {
+	Secret string `json:"secret"`
+	URL    string `json:"otpauth_url"`
+	QRCode string `json:"qr_code"`
+}
+
+// Generate creates a new RFC 6238 secret for the account and renders its
+// otpauth:// URI as a PNG QR code.
+func Generate(issuer, account string) (*Enrollment, error) {
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      issuer,
+		AccountName: account,
+		Period:      period,
+		Digits:      otp.DigitsSix,
+		Algorithm:   otp.AlgorithmSHA1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := key.Image(qrSize, qrSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+
+	return &Enrollment{
+		Secret: key.Secret(),
+		URL:    key.URL(),
+		QRCode: "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()),
+	}, nil
+}
+
+// Validate checks the code against the secret at time t, allowing one period
+// of clock drift either way. It returns the time step the code belongs to so
+// callers can refuse a step that was already used. Steps at or before
+// lastStep are rejected.
+func Validate(secret, code string, t time.Time, lastStep int64) (int64, bool) {
+	current := t.Unix() / period
+
+	for i := -skew; i <= skew; i++ {
+		step := current + int64(i)
+		if step <= lastStep {
+			continue
+		}
+
+		expected, err := totp.GenerateCodeCustom(secret, time.Unix(step*period, 0), totp.ValidateOpts{
+			Period:    period,
+			Digits:    otp.DigitsSix,
+			Algorithm: otp.AlgorithmSHA1,
+		})
+		if err != nil {
+			return 0, false
+		}
+
+		if expected == code {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,76 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+func codeAt(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+
+	code, err := totp.GenerateCodeCustom(secret, at, totp.ValidateOpts{
+		Period:    period,
+		Digits:    otp.DigitsSix,
+		Algorithm: otp.AlgorithmSHA1,
+	})
+	if err != nil {
+		t.Fatalf("Failed to generate code: %v", err)
+	}
+	return code
+}
+
+func TestGenerate(t *testing.T) {
+	enrollment, err := Generate("Golang Template", "test@example.com")
+	if err != nil {
+		t.Fatalf("Failed to generate enrollment: %v", err)
+	}
+
+	if !strings.HasPrefix(enrollment.URL, "otpauth://totp/") {
+		t.Errorf("Expected otpauth URL, got %s", enrollment.URL)
+	}
+
+	if !strings.Contains(enrollment.URL, enrollment.Secret) {
+		t.Errorf("Expected URL to contain the secret")
+	}
+
+	if !strings.HasPrefix(enrollment.QRCode, "data:image/png;base64,") {
+		t.Errorf("Expected PNG data URI for QR code")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	enrollment, err := Generate("Golang Template", "test@example.com")
+	if err != nil {
+		t.Fatalf("Failed to generate enrollment: %v", err)
+	}
+
+	now := time.Now()
+	code := codeAt(t, enrollment.Secret, now)
+
+	step, ok := Validate(enrollment.Secret, code, now, 0)
+	if !ok {
+		t.Fatalf("Expected current code to be valid")
+	}
+
+	if _, ok := Validate(enrollment.Secret, code, now, step); ok {
+		t.Errorf("Expected a code from an already used step to be rejected")
+	}
+
+	drifted := codeAt(t, enrollment.Secret, now.Add(-period*time.Second))
+	if _, ok := Validate(enrollment.Secret, drifted, now, 0); !ok {
+		t.Errorf("Expected code from the previous step to be accepted")
+	}
+
+	old := codeAt(t, enrollment.Secret, now.Add(-5*period*time.Second))
+	if _, ok := Validate(enrollment.Secret, old, now, 0); ok && old != code {
+		t.Errorf("Expected code from an old step to be rejected")
+	}
+
+	if _, ok := Validate(enrollment.Secret, "000000x", now, 0); ok {
+		t.Errorf("Expected malformed code to be rejected")
+	}
+}
@@ -58,6 +58,13 @@ func ValidateResetUserSchema(c *gin.Context) {
 	c.Next()
 }
 
+func ValidateMagicLinkSchema(c *gin.Context) {
+	var body handlers.EmailInput
+	bindAndValidate(c, &body)
+	c.Set("validatedRequestBody", body)
+	c.Next()
+}
+
 func ValidateResetPasswordSchema(c *gin.Context) {
 	var body handlers.ResetPasswordInput
 	bindAndValidate(c, &body)
@@ -72,6 +79,55 @@ func ValidateRoleSchema(c *gin.Context) {
 	c.Next()
 }
 
+func ValidateChangeEmailSchema(c *gin.Context) {
+	var body handlers.ChangeEmailInput
+	bindAndValidate(c, &body)
+	c.Set("validatedRequestBody", body)
+	c.Next()
+}
+
+func ValidateCreateRoleSchema(c *gin.Context) {
+	var body handlers.CreateRoleInput
+	bindAndValidate(c, &body)
+	c.Set("validatedRequestBody", body)
+	c.Next()
+}
+
+func ValidateUpdateRolePermissionsSchema(c *gin.Context) {
+	var body handlers.UpdateRolePermissionsInput
+	bindAndValidate(c, &body)
+	c.Set("validatedRequestBody", body)
+	c.Next()
+}
+
+func ValidateTwoFactorVerifySchema(c *gin.Context) {
+	var body handlers.TwoFactorVerifyInput
+	bindAndValidate(c, &body)
+	c.Set("validatedRequestBody", body)
+	c.Next()
+}
+
+func ValidateTwoFactorPreferencesSchema(c *gin.Context) {
+	var body handlers.TwoFactorPreferencesInput
+	bindAndValidate(c, &body)
+	c.Set("validatedRequestBody", body)
+	c.Next()
+}
+
+func ValidateReauthenticateSchema(c *gin.Context) {
+	var body handlers.ReauthenticateInput
+	bindAndValidate(c, &body)
+	c.Set("validatedRequestBody", body)
+	c.Next()
+}
+
+func ValidateRenamePasskeySchema(c *gin.Context) {
+	var body handlers.RenamePasskeyInput
+	bindAndValidate(c, &body)
+	c.Set("validatedRequestBody", body)
+	c.Next()
+}
+
 func bindAndValidate(c *gin.Context, body interface{}) {
 	if err := c.ShouldBindJSON(body); err != nil {
 		helpers.ReturnError(c, "Error validating input", err, http.StatusBadRequest)
@@ -85,3 +141,31 @@ func bindAndValidate(c *gin.Context, body interface{}) {
 		return
 	}
 }
+
+func ValidateCreateAPITokenSchema(c *gin.Context) {
+	var body handlers.CreateAPITokenInput
+	bindAndValidate(c, &body)
+	c.Set("validatedRequestBody", body)
+	c.Next()
+}
+
+func ValidateChangePasswordSchema(c *gin.Context) {
+	var body handlers.ChangePasswordInput
+	bindAndValidate(c, &body)
+	c.Set("validatedRequestBody", body)
+	c.Next()
+}
+
+func ValidatePasswordCheckSchema(c *gin.Context) {
+	var body handlers.PasswordCheckInput
+	bindAndValidate(c, &body)
+	c.Set("validatedRequestBody", body)
+	c.Next()
+}
+
+func ValidateUpdateDeviceSchema(c *gin.Context) {
+	var body handlers.UpdateDeviceInput
+	bindAndValidate(c, &body)
+	c.Set("validatedRequestBody", body)
+	c.Next()
+}
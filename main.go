@@ -10,6 +10,8 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/bjorndonald/golang-backend-template/constants"
@@ -19,6 +21,7 @@ import (
 	"github.com/bjorndonald/golang-backend-template/internal/handlers"
 	"github.com/bjorndonald/golang-backend-template/internal/helpers"
 	"github.com/bjorndonald/golang-backend-template/internal/otp"
+	"github.com/bjorndonald/golang-backend-template/internal/passwords"
 	"github.com/bjorndonald/golang-backend-template/internal/routes"
 	"github.com/bjorndonald/golang-backend-template/internal/service/streaming"
 	"github.com/gin-contrib/cors"
@@ -46,7 +49,6 @@ func main() {
 	docs.SwaggerInfo.BasePath = "/api/v1"
 
 	constant := constants.New()
-	_ = otp.NewOTPManager()
 
 	ctx := context.Background()
 
@@ -101,6 +103,20 @@ func main() {
 	}))
 	g.MaxMultipartMemory = 8 << 20
 
+	// c.ClientIP() only believes X-Forwarded-For and X-Real-IP when the
+	// request comes through one of these proxies, so clients cannot pick
+	// the address their logins are placed at.
+	var proxies []string
+	for _, proxy := range strings.Split(v.TrustedProxies, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	if err := g.SetTrustedProxies(proxies); err != nil {
+		log.Fatalf("invalid TRUSTED_PROXIES: %v", err)
+	}
+	g.TrustedPlatform = v.TrustedPlatform
+
 	// g.Use(apitoolkit.GinMiddleware(apitoolkitClient))
 
 	dbConfig := database.Config{
@@ -147,6 +163,24 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	sweepInterval, err := time.ParseDuration(v.OTPSweepInterval)
+	if err != nil {
+		log.Fatalf("invalid OTP_SWEEP_INTERVAL: %v", err)
+	}
+	go otp.Sweep(ctx, dependencies.OTPStore, sweepInterval)
+
+	policy, err := passwordPolicy(v)
+	if err != nil {
+		log.Fatalf("invalid password policy: %v", err)
+	}
+	passwords.SetDefault(policy)
+
+	hasher, err := passwordHasher(v)
+	if err != nil {
+		log.Fatalf("invalid password hashing settings: %v", err)
+	}
+	passwords.SetHasher(hasher)
+
 	consumerClient, err := streaming.NewConsumer(&streaming.Config{
 		Verbose:  true,
 		Version:  "3.8.0",
@@ -174,6 +208,12 @@ func main() {
 	// /////////////////////////////
 
 	routes.Routes(v1, dependencies)
+	routes.RegisterWellKnownRoutes(g, dependencies)
+	// The mailbox shows every code and sign-in link sent, so it has to be
+	// asked for explicitly.
+	if viewer, _ := strconv.ParseBool(v.MailSinkViewer); viewer && v.Env != "production" {
+		routes.RegisterDevRoutes(g, dependencies)
+	}
 
 	g.NoRoute(func(c *gin.Context) {
 		helpers.ReturnError(c, "Something went wrong", fmt.Errorf("route not found"), http.StatusNotFound)
@@ -207,3 +247,74 @@ func main() {
 	// provider.Clear()
 	// cancel()
 }
+
+// passwordPolicy builds the password policy from the PASSWORD_* settings and
+// loads the breached password list, if one is configured.
+func passwordPolicy(v *constants.Config) (passwords.Policy, error) {
+	policy := passwords.DefaultPolicy
+
+	settings := []struct {
+		name  string
+		value string
+		field *int
+	}{
+		{"PASSWORD_MIN_LENGTH", v.PasswordMinLength, &policy.MinLength},
+		{"PASSWORD_MIN_CLASSES", v.PasswordMinClasses, &policy.MinClasses},
+		{"PASSWORD_MIN_SCORE", v.PasswordMinScore, &policy.MinScore},
+	}
+	for _, setting := range settings {
+		n, err := strconv.Atoi(setting.value)
+		if err != nil || n < 0 {
+			return policy, fmt.Errorf("%s must be a whole number, got %q", setting.name, setting.value)
+		}
+		*setting.field = n
+	}
+
+	if v.BreachedPasswordsFile != "" {
+		list, err := passwords.LoadBreachedFile(v.BreachedPasswordsFile)
+		if err != nil {
+			return policy, fmt.Errorf("BREACHED_PASSWORDS_FILE: %w", err)
+		}
+		log.Printf("loaded %d breached password hashes", list.Len())
+		policy.Breached = list
+	}
+
+	return policy, nil
+}
+
+// passwordHasher builds the hasher new passwords are stored with from the
+// PASSWORD_HASH_* settings. Existing hashes made another way are upgraded
+// when their owners next log in.
+func passwordHasher(v *constants.Config) (passwords.Hasher, error) {
+	hasher := passwords.DefaultHasher
+	hasher.Algorithm = v.PasswordHasher
+
+	var memory, iterations, parallelism int
+	settings := []struct {
+		name  string
+		value string
+		field *int
+	}{
+		{"PASSWORD_BCRYPT_COST", v.BcryptCost, &hasher.BcryptCost},
+		{"PASSWORD_ARGON2_MEMORY", v.Argon2Memory, &memory},
+		{"PASSWORD_ARGON2_ITERATIONS", v.Argon2Iterations, &iterations},
+		{"PASSWORD_ARGON2_PARALLELISM", v.Argon2Threads, &parallelism},
+	}
+	for _, setting := range settings {
+		n, err := strconv.Atoi(setting.value)
+		if err != nil || n < 0 {
+			return hasher, fmt.Errorf("%s must be a whole number, got %q", setting.name, setting.value)
+		}
+		*setting.field = n
+	}
+
+	if parallelism > 255 {
+		return hasher, fmt.Errorf("PASSWORD_ARGON2_PARALLELISM must be at most 255, got %d", parallelism)
+	}
+
+	hasher.Argon2.Memory = uint32(memory)
+	hasher.Argon2.Iterations = uint32(iterations)
+	hasher.Argon2.Parallelism = uint8(parallelism)
+
+	return hasher, hasher.Validate()
+}
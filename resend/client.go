@@ -1,6 +1,8 @@
 package resend
 
 import (
+	"net/mail"
+
 	"github.com/resend/resend-go/v2"
 )
 
@@ -22,6 +24,10 @@ func NewClient(apiKey string) *Client {
 
 func (c *Client) Send(emails []string, from, fromName, subject, content string) (string, error) {
 
+	if fromName != "" {
+		from = (&mail.Address{Name: fromName, Address: from}).String()
+	}
+
 	params := &resend.SendEmailRequest{
 		From:    from,
 		To:      emails,
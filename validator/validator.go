@@ -2,11 +2,14 @@ package validator
 
 import (
 	"fmt"
+	"reflect"
 
 	"github.com/go-playground/locales/en"
 	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
 	en_translations "github.com/go-playground/validator/v10/translations/en"
+
+	"github.com/bjorndonald/golang-backend-template/internal/passwords"
 )
 
 // use a single instance , it caches struct info
@@ -56,6 +59,54 @@ func init() {
 		panic("unable to register translation: alpha")
 	}
 
+	err = validate.RegisterValidation("password", validatePassword)
+	if err != nil {
+		panic("unable to register validation: password")
+	}
+
+	err = validate.RegisterTranslation("password", *Translator, func(ut ut.Translator) error {
+		return ut.Add("password", "{0} {1}", true)
+	}, func(ut ut.Translator, fe validator.FieldError) string {
+		t, _ := ut.T("password", fe.Field(), passwordProblem(fe))
+
+		return t
+	})
+	if err != nil {
+		panic("unable to register translation: password")
+	}
+
+}
+
+// passwordUserFields are the fields of the validated struct a password may
+// not contain.
+var passwordUserFields = []string{"Email", "FirstName", "LastName", "Name"}
+
+// validatePassword checks a field tagged password against the configured
+// password policy, using the user details found next to it in the struct.
+func validatePassword(fl validator.FieldLevel) bool {
+	var inputs []string
+	parent := reflect.Indirect(fl.Parent())
+	if parent.Kind() == reflect.Struct {
+		for _, name := range passwordUserFields {
+			if field := parent.FieldByName(name); field.IsValid() && field.Kind() == reflect.String {
+				inputs = append(inputs, field.String())
+			}
+		}
+	}
+
+	return passwords.Default().Check(fl.Field().String(), inputs...).OK()
+}
+
+// passwordProblem explains why a password failed. The user details are not
+// available here, so a password that only failed on them is reported as
+// containing them.
+func passwordProblem(fe validator.FieldError) string {
+	value, _ := fe.Value().(string)
+	result := passwords.Default().Check(value)
+	if result.OK() {
+		return "must not contain your name or email"
+	}
+	return result.Problems[0]
 }
 
 func Validate(s interface{}) error {
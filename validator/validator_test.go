@@ -31,3 +31,48 @@ func TestValidate(t *testing.T) {
 		}
 	})
 }
+
+type SignUp struct {
+	Email     string `validate:"required,email"`
+	FirstName string
+	Password  string `validate:"required,password"`
+}
+
+func TestValidatePassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    SignUp
+		expected string
+	}{
+		{
+			name:  "strong password",
+			input: SignUp{Email: "jane@example.com", FirstName: "Jane", Password: "Velvet-Harbor-91-Quill"},
+		},
+		{
+			name:     "too short",
+			input:    SignUp{Email: "jane@example.com", FirstName: "Jane", Password: "aB3$"},
+			expected: "Password must be at least 8 characters long",
+		},
+		{
+			name:     "contains the user's name",
+			input:    SignUp{Email: "jane@example.com", FirstName: "Marguerite", Password: "Velvet-Marguerite-91"},
+			expected: "Password must not contain your name or email",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.input)
+			if tt.expected == "" {
+				if err != nil {
+					t.Errorf("Expected no error, got: %v", err)
+				}
+				return
+			}
+
+			if err == nil || err.Error() != tt.expected {
+				t.Errorf("Expected error message '%s', got '%v'", tt.expected, err)
+			}
+		})
+	}
+}